@@ -2,6 +2,7 @@ module github.com/delta/terrabound/backend
 
 go 1.25.0
 
-require github.com/heroiclabs/nakama-common v1.43.1
-
-require google.golang.org/protobuf v1.36.8 // indirect
+require (
+	github.com/heroiclabs/nakama-common v1.43.1
+	google.golang.org/protobuf v1.36.8
+)
@@ -0,0 +1,167 @@
+package game
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Difficulty selects one of the built-in bot strategies.
+type Difficulty string
+
+const (
+	DifficultyEasy   Difficulty = "easy"
+	DifficultyNormal Difficulty = "normal"
+	DifficultyHard   Difficulty = "hard"
+)
+
+// Strategy plans a bot's orders for one turn. It is handed the bot's own
+// fogged view of the world, never the real one, and whatever it returns is
+// validated exactly like a human's orders, so a strategy cannot cheat.
+type Strategy interface {
+	Plan(view *World, self PlayerID, rng *RNG) []Order
+}
+
+// NewStrategy returns the built-in strategy for a difficulty.
+func NewStrategy(d Difficulty) (Strategy, error) {
+	switch d {
+	case DifficultyEasy:
+		return Heuristic{AttackRatio: 2, Hesitation: 50}, nil
+	case DifficultyNormal:
+		return Heuristic{AttackRatio: 1.5, Economy: true, Hesitation: 15}, nil
+	case DifficultyHard:
+		return Heuristic{AttackRatio: 1.2, Economy: true, Consolidate: true}, nil
+	default:
+		return nil, fmt.Errorf("game: unknown difficulty %q", d)
+	}
+}
+
+// GoldReserve is what an economic bot keeps back instead of recruiting.
+const GoldReserve = 5
+
+// Heuristic is a rule-based strategy whose knobs set its difficulty.
+type Heuristic struct {
+	// AttackRatio is how many times the defenders a bot wants before attacking.
+	AttackRatio float64
+	// Economy lets the bot build barracks at its capital and recruit there.
+	Economy bool
+	// Consolidate moves idle units from safe regions towards the front.
+	Consolidate bool
+	// Hesitation is the percent chance of skipping an attack it could make.
+	Hesitation int
+}
+
+func (h Heuristic) Plan(view *World, self PlayerID, rng *RNG) []Order {
+	player := view.Player(self)
+	if player == nil || player.Eliminated {
+		return nil
+	}
+
+	var orders []Order
+	if h.Economy {
+		orders = append(orders, h.planEconomy(view, player)...)
+	}
+
+	front := frontDistances(view, self)
+	for _, r := range view.OwnedBy(self) {
+		spare := r.Garrison - MinGarrison
+		if spare <= 0 {
+			continue
+		}
+
+		if target := weakestTarget(view, r, self); target != nil {
+			needed := int(float64(target.Garrison)*h.AttackRatio) + 1
+			if spare >= needed && rng.Intn(100) >= h.Hesitation {
+				orders = append(orders, Order{PlayerID: self, Action: ActionAttack, From: r.ID, Target: target.ID, Units: spare})
+			}
+			continue
+		}
+
+		if h.Consolidate {
+			if next := towardsFront(view, r, front); next != "" {
+				orders = append(orders, Order{PlayerID: self, Action: ActionMove, From: r.ID, Target: next, Units: spare})
+			}
+		}
+	}
+	return orders
+}
+
+// planEconomy builds barracks at the capital first, then spends spare gold on recruits there.
+func (h Heuristic) planEconomy(view *World, player *Player) []Order {
+	capital := view.CapitalOf(player.ID)
+	if capital == nil {
+		return nil
+	}
+	if !capital.Has(BuildingBarracks) {
+		if player.Treasury.Covers(BuildingCosts[BuildingBarracks]) {
+			return []Order{{PlayerID: player.ID, Action: ActionBuild, Target: capital.ID, Building: BuildingBarracks}}
+		}
+		return nil
+	}
+	if RecruitCost.Gold <= 0 {
+		return nil
+	}
+	if n := (player.Treasury.Gold - GoldReserve) / RecruitCost.Gold; n > 0 {
+		return []Order{{PlayerID: player.ID, Action: ActionRecruit, Target: capital.ID, Units: n}}
+	}
+	return nil
+}
+
+// weakestTarget is the visible hostile neighbour with the smallest garrison.
+func weakestTarget(view *World, from *Region, self PlayerID) *Region {
+	var best *Region
+	neighbors := append([]RegionID(nil), from.Neighbors...)
+	sort.Slice(neighbors, func(i, j int) bool { return neighbors[i] < neighbors[j] })
+	for _, id := range neighbors {
+		r := view.Region(id)
+		if r == nil || r.Hidden || view.Allied(self, r.Owner) {
+			continue
+		}
+		if best == nil || r.Garrison < best.Garrison {
+			best = r
+		}
+	}
+	return best
+}
+
+// frontDistances is each region's hop count to the nearest region not held by
+// the bot's side.
+func frontDistances(view *World, self PlayerID) map[RegionID]int {
+	dist := make(map[RegionID]int)
+	var queue []RegionID
+	for _, id := range view.RegionIDs() {
+		if !view.Allied(self, view.Regions[id].Owner) {
+			dist[id] = 0
+			queue = append(queue, id)
+		}
+	}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, n := range view.Regions[id].Neighbors {
+			if _, ok := dist[n]; !ok {
+				dist[n] = dist[id] + 1
+				queue = append(queue, n)
+			}
+		}
+	}
+	return dist
+}
+
+// towardsFront picks the friendly neighbour one step closer to the front.
+func towardsFront(view *World, from *Region, front map[RegionID]int) RegionID {
+	here, ok := front[from.ID]
+	if !ok {
+		return ""
+	}
+	var best RegionID
+	for _, id := range from.Neighbors {
+		r := view.Region(id)
+		if r == nil || !view.Allied(from.Owner, r.Owner) {
+			continue
+		}
+		if d, ok := front[id]; ok && d < here && (best == "" || id < best) {
+			best = id
+		}
+	}
+	return best
+}
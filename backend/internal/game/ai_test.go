@@ -0,0 +1,111 @@
+package game
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestHeuristicPlan(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		strategy Heuristic
+		setup    func(w *World)
+		want     []Order
+	}{
+		{
+			name:     "attacks the weakest neighbour it can beat",
+			strategy: Heuristic{AttackRatio: 1.5},
+			want: []Order{
+				{PlayerID: "user-1", Action: ActionAttack, From: "capital-1", Target: "forest", Units: 9},
+			},
+		},
+		{
+			name:     "holds back against a neighbour too strong",
+			strategy: Heuristic{AttackRatio: 5},
+		},
+		{
+			name:     "always hesitates",
+			strategy: Heuristic{AttackRatio: 1.5, Hesitation: 100},
+		},
+		{
+			name:     "builds barracks first",
+			strategy: Heuristic{AttackRatio: 5, Economy: true},
+			want: []Order{
+				{PlayerID: "user-1", Action: ActionBuild, Target: "capital-1", Building: BuildingBarracks},
+			},
+		},
+		{
+			name:     "recruits with gold to spare",
+			strategy: Heuristic{AttackRatio: 5, Economy: true},
+			setup: func(w *World) {
+				w.Regions["capital-1"].Buildings = []Building{BuildingBarracks}
+				w.Players["user-1"].Treasury.Gold = 20
+			},
+			want: []Order{
+				{PlayerID: "user-1", Action: ActionRecruit, Target: "capital-1", Units: 5},
+			},
+		},
+		{
+			name:     "moves idle units towards the front",
+			strategy: Heuristic{AttackRatio: 1.5, Consolidate: true},
+			setup: func(w *World) {
+				w.Regions["forest"].Owner = "user-1"
+			},
+			want: []Order{
+				{PlayerID: "user-1", Action: ActionMove, From: "capital-1", Target: "forest", Units: 9},
+			},
+		},
+		{
+			name:     "eliminated",
+			strategy: Heuristic{AttackRatio: 1.5},
+			setup: func(w *World) {
+				w.Players["user-1"].Eliminated = true
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			w := SampleWorld()
+			if tc.setup != nil {
+				tc.setup(w)
+			}
+			got := tc.strategy.Plan(ViewFor(w, "user-1"), "user-1", NewRNG(1))
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("orders %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestStrategiesPlayByTheRules(t *testing.T) {
+	if _, err := NewStrategy("insane"); err == nil {
+		t.Error("unknown difficulty accepted")
+	}
+	for _, d := range []Difficulty{DifficultyEasy, DifficultyNormal, DifficultyHard} {
+		strategy, err := NewStrategy(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w, err := GenerateMap(MapParams{Seed: 7, Players: 4})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 1; i <= 4; i++ {
+			if _, err := w.Seat(Player{ID: PlayerID("bot-" + strconv.Itoa(i))}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		engine := NewEngine(7)
+		for turn := 1; turn <= 10; turn++ {
+			var orders []Order
+			for _, id := range w.PlayerIDs() {
+				rng := NewRNG(DeriveSeed(7, string(d), string(id), strconv.Itoa(turn)))
+				orders = append(orders, strategy.Plan(ViewFor(w, id), id, rng)...)
+			}
+			if _, rejected := ValidateOrders(w, orders); len(rejected) > 0 {
+				t.Fatalf("%s, turn %d: orders rejected: %+v", d, turn, rejected)
+			}
+			engine.Resolve(w, turn, orders)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package game
+
+import (
+	"sort"
+	"strconv"
+)
+
+// Combat tuning.
+const (
+	DieSides       = 6
+	MaxAttackDice  = 3
+	MaxDefenceDice = 2
+	CapitalDefence = 1
+)
+
+// Modifier is a bonus added to every die one side rolls.
+type Modifier struct {
+	Source string   `json:"source"`
+	Player PlayerID `json:"player"`
+	Value  int      `json:"value"`
+}
+
+type CombatRound struct {
+	Attacker       PlayerID `json:"attacker"`
+	Defender       PlayerID `json:"defender"`
+	AttackerRolls  []int    `json:"attackerRolls"`
+	DefenderRolls  []int    `json:"defenderRolls"`
+	AttackerLosses int      `json:"attackerLosses"`
+	DefenderLosses int      `json:"defenderLosses"`
+}
+
+// DiceResolver fights battles as rounds of opposed dice. The holder takes on
+// attackers one at a time, largest first, and whoever survives a fight
+// defends against the next one. Every battle draws from its own stream
+// derived from the match seed, turn and region, so a single report can be
+// replayed without re-running the rest of the match.
+type DiceResolver struct {
+	Seed uint64
+}
+
+func (d DiceResolver) Fight(w *World, b Battle) BattleReport {
+	report := newReport(b)
+	report.Seed = DeriveSeed(d.Seed, strconv.Itoa(b.Turn), string(b.Region))
+	rng := NewRNG(report.Seed)
+
+	holder := b.Forces[0]
+	attackers := append([]Force(nil), b.Forces[1:]...)
+	sort.SliceStable(attackers, func(i, j int) bool {
+		if attackers[i].Units != attackers[j].Units {
+			return attackers[i].Units > attackers[j].Units
+		}
+		return attackers[i].Player < attackers[j].Player
+	})
+
+	for _, atk := range attackers {
+		if holder.Units == 0 {
+			holder = atk
+			continue
+		}
+
+		bonus := 0
+		for _, m := range defenceModifiers(w, b.Region, holder.Player) {
+			bonus += m.Value
+			report.Modifiers = appendModifier(report.Modifiers, m)
+		}
+
+		for holder.Units > 0 && atk.Units > 0 {
+			round := rollRound(rng, atk, holder, bonus)
+			atk.Units -= round.AttackerLosses
+			holder.Units -= round.DefenderLosses
+			report.Rounds = append(report.Rounds, round)
+		}
+		if atk.Units > 0 {
+			holder = atk
+		}
+	}
+
+	report.Winner = holder.Player
+	report.Survivors = holder.Units
+	for _, f := range b.Forces {
+		report.Losses[f.Player] = f.Units
+	}
+	report.Losses[holder.Player] -= holder.Units
+	return report
+}
+
+// defenceModifiers lists the bonuses whoever holds a region gets when defending it.
+func defenceModifiers(w *World, id RegionID, defender PlayerID) []Modifier {
+	r := w.Region(id)
+	if r == nil {
+		return nil
+	}
+	var mods []Modifier
+	if r.Capital {
+		mods = append(mods, Modifier{Source: "capital", Player: defender, Value: CapitalDefence})
+	}
+	if r.Has(BuildingFort) {
+		mods = append(mods, Modifier{Source: string(BuildingFort), Player: defender, Value: FortDefence})
+	}
+	return mods
+}
+
+// appendModifier records a modifier once per source and player.
+func appendModifier(mods []Modifier, m Modifier) []Modifier {
+	for _, existing := range mods {
+		if existing.Source == m.Source && existing.Player == m.Player {
+			return mods
+		}
+	}
+	return append(mods, m)
+}
+
+// rollRound compares the highest dice pairwise; the defender wins ties.
+func rollRound(rng *RNG, atk, def Force, defBonus int) CombatRound {
+	round := CombatRound{
+		Attacker:      atk.Player,
+		Defender:      def.Player,
+		AttackerRolls: rollDice(rng, min(atk.Units, MaxAttackDice), 0),
+		DefenderRolls: rollDice(rng, min(def.Units, MaxDefenceDice), defBonus),
+	}
+	for i := 0; i < len(round.AttackerRolls) && i < len(round.DefenderRolls); i++ {
+		if round.AttackerRolls[i] > round.DefenderRolls[i] {
+			round.DefenderLosses++
+		} else {
+			round.AttackerLosses++
+		}
+	}
+	return round
+}
+
+// rollDice returns n modified rolls, highest first.
+func rollDice(rng *RNG, n, bonus int) []int {
+	rolls := make([]int, n)
+	for i := range rolls {
+		rolls[i] = rng.Intn(DieSides) + 1 + bonus
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(rolls)))
+	return rolls
+}
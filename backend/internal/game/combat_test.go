@@ -0,0 +1,87 @@
+package game
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiceResolverPinnedSeed(t *testing.T) {
+	battle := Battle{Turn: 4, Region: "capital-2", Forces: []Force{{Player: "user-2", Units: 4}, {Player: "user-1", Units: 6}}}
+	report := DiceResolver{Seed: 42}.Fight(SampleWorld(), battle)
+
+	// Changing any of these changes every replay stored so far.
+	want := BattleReport{
+		Turn:      4,
+		Region:    "capital-2",
+		Seed:      0x34247569eda95ccf,
+		Forces:    battle.Forces,
+		Modifiers: []Modifier{{Source: "capital", Player: "user-2", Value: CapitalDefence}},
+		Rounds: []CombatRound{
+			{Attacker: "user-1", Defender: "user-2", AttackerRolls: []int{4, 2, 1}, DefenderRolls: []int{6, 3}, AttackerLosses: 2},
+			{Attacker: "user-1", Defender: "user-2", AttackerRolls: []int{5, 5, 1}, DefenderRolls: []int{4, 2}, DefenderLosses: 2},
+			{Attacker: "user-1", Defender: "user-2", AttackerRolls: []int{6, 2, 2}, DefenderRolls: []int{7, 2}, AttackerLosses: 2},
+			{Attacker: "user-1", Defender: "user-2", AttackerRolls: []int{1, 1}, DefenderRolls: []int{7, 5}, AttackerLosses: 2},
+		},
+		Winner:    "user-2",
+		Survivors: 2,
+		Losses:    map[PlayerID]int{"user-1": 6, "user-2": 2},
+	}
+	if !reflect.DeepEqual(report, want) {
+		t.Fatalf("seed 42 gave\n%+v\nwant\n%+v", report, want)
+	}
+}
+
+func TestDiceResolverBattlesIndependent(t *testing.T) {
+	w := SampleWorld()
+	d := DiceResolver{Seed: 7}
+	river := Battle{Turn: 2, Region: "river", Forces: []Force{{Player: Neutral, Units: 2}, {Player: "user-1", Units: 3}, {Player: "user-2", Units: 3}}}
+	mountain := Battle{Turn: 2, Region: "mountain", Forces: []Force{{Player: Neutral, Units: 4}, {Player: "user-2", Units: 6}}}
+
+	// Fighting one battle first must not change the other's dice.
+	riverFirst := d.Fight(w, river)
+	mountainSecond := d.Fight(w, mountain)
+	mountainFirst := d.Fight(w, mountain)
+	riverSecond := d.Fight(w, river)
+	if !reflect.DeepEqual(riverFirst, riverSecond) || !reflect.DeepEqual(mountainFirst, mountainSecond) {
+		t.Fatal("a battle's outcome depends on which battles were resolved before it")
+	}
+
+	seeds := map[uint64]string{}
+	for _, b := range []Battle{river, mountain, {Turn: 3, Region: "river"}, {Turn: 2, Region: "forest"}} {
+		seed := d.Fight(w, Battle{Turn: b.Turn, Region: b.Region, Forces: []Force{{Units: 1}}}).Seed
+		if prev, ok := seeds[seed]; ok {
+			t.Errorf("turn %d %s shares its seed with %s", b.Turn, b.Region, prev)
+		}
+		seeds[seed] = string(b.Region)
+	}
+	if (DiceResolver{Seed: 8}).Fight(w, river).Seed == riverFirst.Seed {
+		t.Error("battle seed does not depend on the match seed")
+	}
+}
+
+func TestAlliesAttackTogether(t *testing.T) {
+	w := SampleWorld()
+	if err := w.AddFaction(Faction{ID: "red", Name: "Red"}); err != nil {
+		t.Fatal(err)
+	}
+	w.Players["user-1"].Faction = "red"
+	w.Players["user-2"].Faction = "red"
+
+	e := NewEngine(1)
+	result := e.Resolve(w, 1, []Order{
+		{PlayerID: "user-1", Action: ActionAttack, From: "plains-1", Target: "river", Units: 2},
+		{PlayerID: "user-2", Action: ActionAttack, From: "plains-2", Target: "river", Units: 2},
+	})
+	if len(result.Battles) != 1 {
+		t.Fatalf("%d battles, want 1", len(result.Battles))
+	}
+	b := result.Battles[0]
+	if len(b.Forces) != 2 || b.Forces[1].Units != 4 {
+		t.Fatalf("forces %+v, want the neutral garrison against one allied stack of 4", b.Forces)
+	}
+	for _, r := range b.Rounds {
+		if w.Allied(r.Attacker, r.Defender) {
+			t.Fatalf("allies fought each other: %+v", r)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package game
+
+import (
+	"fmt"
+	"sort"
+)
+
+type Resources struct {
+	Gold int `json:"gold"`
+	Food int `json:"food"`
+}
+
+func (r Resources) Add(o Resources) Resources {
+	return Resources{Gold: r.Gold + o.Gold, Food: r.Food + o.Food}
+}
+
+func (r Resources) Sub(o Resources) Resources {
+	return Resources{Gold: r.Gold - o.Gold, Food: r.Food - o.Food}
+}
+
+func (r Resources) Times(n int) Resources {
+	return Resources{Gold: r.Gold * n, Food: r.Food * n}
+}
+
+// Covers reports whether r can pay for cost without going negative.
+func (r Resources) Covers(cost Resources) bool {
+	return r.Gold >= cost.Gold && r.Food >= cost.Food
+}
+
+func (r Resources) Negative() bool {
+	return r.Gold < 0 || r.Food < 0
+}
+
+func (r Resources) String() string {
+	return fmt.Sprintf("%d gold, %d food", r.Gold, r.Food)
+}
+
+type Building string
+
+const (
+	// BuildingBarracks allows recruiting and trains a free unit every turn.
+	BuildingBarracks Building = "barracks"
+	// BuildingFort adds FortDefence to every defending die.
+	BuildingFort Building = "fort"
+	// BuildingMarket adds MarketGold to the region's yield.
+	BuildingMarket Building = "market"
+)
+
+// Economy tuning.
+var (
+	BuildingCosts = map[Building]Resources{
+		BuildingBarracks: {Gold: 10},
+		BuildingFort:     {Gold: 12},
+		BuildingMarket:   {Gold: 8},
+	}
+	RecruitCost      = Resources{Gold: 3}
+	UnitUpkeep       = Resources{Food: 1}
+	StartingTreasury = Resources{Gold: 10, Food: 10}
+)
+
+const (
+	// FreeUnits is how many units each player supports without upkeep.
+	FreeUnits = 10
+	// BarracksUnitsPerTurn is trained for free in every region with barracks.
+	BarracksUnitsPerTurn = 1
+	MarketGold           = 3
+	FortDefence          = 1
+	// BankruptcyLossPercent of a bankrupt player's units desert each turn.
+	BankruptcyLossPercent = 25
+)
+
+func (r *Region) Has(b Building) bool {
+	return containsBuilding(r.Buildings, b)
+}
+
+func containsBuilding(bs []Building, b Building) bool {
+	for _, have := range bs {
+		if have == b {
+			return true
+		}
+	}
+	return false
+}
+
+// Income is what a region pays its holder each turn.
+func (r *Region) Income() Resources {
+	income := r.Yield
+	if r.Has(BuildingMarket) {
+		income.Gold += MarketGold
+	}
+	return income
+}
+
+// Upkeep is what a player owes each turn for their units.
+func Upkeep(w *World, p PlayerID) Resources {
+	over := w.Units(p) - FreeUnits
+	if over <= 0 {
+		return Resources{}
+	}
+	return UnitUpkeep.Times(over)
+}
+
+type EconomyReport struct {
+	Income    Resources `json:"income"`
+	Upkeep    Resources `json:"upkeep"`
+	Treasury  Resources `json:"treasury"`
+	Bankrupt  bool      `json:"bankrupt,omitempty"`
+	Disbanded int       `json:"disbanded,omitempty"`
+}
+
+// collectIncome pays every surviving player, charges upkeep and disbands
+// units of anyone who ends the turn in debt. The debt itself is written off.
+func collectIncome(w *World) map[PlayerID]EconomyReport {
+	reports := make(map[PlayerID]EconomyReport)
+	for _, id := range w.PlayerIDs() {
+		p := w.Players[id]
+		if p.Eliminated {
+			continue
+		}
+
+		var rep EconomyReport
+		for _, r := range w.OwnedBy(id) {
+			rep.Income = rep.Income.Add(r.Income())
+		}
+		rep.Upkeep = Upkeep(w, id)
+		p.Treasury = p.Treasury.Add(rep.Income).Sub(rep.Upkeep)
+
+		if p.Treasury.Negative() {
+			rep.Bankrupt = true
+			rep.Disbanded = disband(w, id, max(1, w.Units(id)*BankruptcyLossPercent/100))
+			p.Treasury.Gold = max(p.Treasury.Gold, 0)
+			p.Treasury.Food = max(p.Treasury.Food, 0)
+		}
+		rep.Treasury = p.Treasury
+		reports[id] = rep
+	}
+	return reports
+}
+
+// disband removes up to n units from a player's largest garrisons without
+// emptying any region, and returns how many actually went.
+func disband(w *World, p PlayerID, n int) int {
+	regions := w.OwnedBy(p)
+	sort.SliceStable(regions, func(i, j int) bool { return regions[i].Garrison > regions[j].Garrison })
+
+	removed := 0
+	for _, r := range regions {
+		take := min(n-removed, r.Garrison-MinGarrison)
+		if take <= 0 {
+			continue
+		}
+		r.Garrison -= take
+		removed += take
+	}
+	return removed
+}
+
+// completeConstruction finishes this turn's builds and recruits in regions the
+// player still holds after battles, then lets every barracks train. Anything
+// ordered in a region that was lost is forfeited along with its cost.
+func completeConstruction(w *World, orders []Order) {
+	for _, o := range orders {
+		r := w.Regions[o.Target]
+		if r.Owner != o.PlayerID {
+			continue
+		}
+		switch o.Action {
+		case ActionBuild:
+			if !r.Has(o.Building) {
+				r.Buildings = append(r.Buildings, o.Building)
+			}
+		case ActionRecruit:
+			r.Garrison += o.Units
+		}
+	}
+
+	for _, id := range w.RegionIDs() {
+		if r := w.Regions[id]; r.Owner != Neutral && r.Has(BuildingBarracks) {
+			r.Garrison += BarracksUnitsPerTurn
+		}
+	}
+}
+
+// pay charges the cost of a build or recruit order up front.
+func pay(w *World, o Order) {
+	p := w.Players[o.PlayerID]
+	switch o.Action {
+	case ActionBuild:
+		p.Treasury = p.Treasury.Sub(BuildingCosts[o.Building])
+	case ActionRecruit:
+		p.Treasury = p.Treasury.Sub(RecruitCost.Times(o.Units))
+	}
+}
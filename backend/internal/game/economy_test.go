@@ -0,0 +1,120 @@
+package game
+
+import "testing"
+
+func TestCollectIncome(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		setup func(w *World)
+		want  EconomyReport
+		// units is what user-1 holds after the turn.
+		units int
+	}{
+		{
+			// Capital 5 gold 5 food and plains 1 gold 4 food, 13 units of which 10 are free.
+			name: "income less upkeep",
+			want: EconomyReport{
+				Income:   Resources{Gold: 6, Food: 9},
+				Upkeep:   Resources{Food: 3},
+				Treasury: Resources{Gold: 16, Food: 16},
+			},
+			units: 13,
+		},
+		{
+			name: "market adds gold",
+			setup: func(w *World) {
+				w.Regions["plains-1"].Buildings = []Building{BuildingMarket}
+			},
+			want: EconomyReport{
+				Income:   Resources{Gold: 6 + MarketGold, Food: 9},
+				Upkeep:   Resources{Food: 3},
+				Treasury: Resources{Gold: 16 + MarketGold, Food: 16},
+			},
+			units: 13,
+		},
+		{
+			name: "no upkeep within free units",
+			setup: func(w *World) {
+				w.Regions["capital-1"].Garrison = FreeUnits - 3
+			},
+			want: EconomyReport{
+				Income:   Resources{Gold: 6, Food: 9},
+				Treasury: Resources{Gold: 16, Food: 19},
+			},
+			units: FreeUnits,
+		},
+		{
+			// 40 units eat 30 food against 19 in hand; a quarter of them desert.
+			name: "bankruptcy disbands units",
+			setup: func(w *World) {
+				w.Regions["capital-1"].Garrison = 37
+			},
+			want: EconomyReport{
+				Income:    Resources{Gold: 6, Food: 9},
+				Upkeep:    Resources{Food: 30},
+				Treasury:  Resources{Gold: 16},
+				Bankrupt:  true,
+				Disbanded: 10,
+			},
+			units: 30,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			w := SampleWorld()
+			if tc.setup != nil {
+				tc.setup(w)
+			}
+			got := collectIncome(w)["user-1"]
+			if got != tc.want {
+				t.Errorf("report %+v, want %+v", got, tc.want)
+			}
+			if w.Players["user-1"].Treasury != tc.want.Treasury {
+				t.Errorf("treasury %v, want %v", w.Players["user-1"].Treasury, tc.want.Treasury)
+			}
+			if got := w.Units("user-1"); got != tc.units {
+				t.Errorf("units %d, want %d", got, tc.units)
+			}
+		})
+	}
+}
+
+func TestDisbandKeepsGarrisons(t *testing.T) {
+	w := SampleWorld()
+	if got := disband(w, "user-1", 100); got != 11 {
+		t.Fatalf("disbanded %d, want 11", got)
+	}
+	for _, r := range w.OwnedBy("user-1") {
+		if r.Garrison != MinGarrison {
+			t.Errorf("%s left with %d units, want %d", r.ID, r.Garrison, MinGarrison)
+		}
+	}
+}
+
+func TestConstructionForfeitedWhenRegionLost(t *testing.T) {
+	w := SampleWorld()
+	w.Regions["plains-1"].Garrison = 1
+	w.Regions["river"].Owner = "user-2"
+	w.Regions["river"].Garrison = 8
+	w.Players["user-1"].Treasury = Resources{Gold: 20, Food: 20}
+
+	e := &Engine{Battles: StrengthResolver{}}
+	result := e.Resolve(w, 1, []Order{
+		{PlayerID: "user-1", Action: ActionBuild, Target: "plains-1", Building: BuildingMarket},
+		{PlayerID: "user-1", Action: ActionBuild, Target: "capital-1", Building: BuildingBarracks},
+		{PlayerID: "user-2", Action: ActionAttack, From: "river", Target: "plains-1", Units: 7},
+	})
+	if w.Regions["plains-1"].Has(BuildingMarket) {
+		t.Error("market completed in a region lost this turn")
+	}
+	if !w.Regions["capital-1"].Has(BuildingBarracks) {
+		t.Error("barracks not completed")
+	}
+	// The barracks trains its first unit the turn it is built.
+	if got := w.Regions["capital-1"].Garrison; got != 11 {
+		t.Errorf("capital garrison %d, want 11", got)
+	}
+	// Both builds were paid for; only the capital yields.
+	if got, want := result.Economy["user-1"].Treasury.Gold, 20-8-10+5; got != want {
+		t.Errorf("gold %d, want %d", got, want)
+	}
+}
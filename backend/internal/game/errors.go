@@ -0,0 +1,14 @@
+package game
+
+import "errors"
+
+var (
+	ErrUnknownRegion    = errors.New("game: unknown region")
+	ErrUnknownPlayer    = errors.New("game: unknown player")
+	ErrUnknownFaction   = errors.New("game: unknown faction")
+	ErrDuplicateRegion  = errors.New("game: region already exists")
+	ErrDuplicatePlayer  = errors.New("game: player already exists")
+	ErrDuplicateFaction = errors.New("game: faction already exists")
+	ErrSelfAdjacency    = errors.New("game: region cannot neighbour itself")
+	ErrNoFreeSpawn      = errors.New("game: no free spawn left on the map")
+)
@@ -0,0 +1,279 @@
+package game
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// MapSchemaVersion is the newest map file version this build understands.
+const MapSchemaVersion = 1
+
+// MaxSpawnDistanceSpread is how much closer one player's nearest rival may be
+// than another's before a map counts as unbalanced.
+const MaxSpawnDistanceSpread = 1
+
+var (
+	ErrUnknownMap   = errors.New("game: unknown map")
+	ErrDuplicateMap = errors.New("game: map already registered")
+)
+
+// MapDefinition is the on-disk format designers write maps in.
+type MapDefinition struct {
+	Version     int                `json:"version"`
+	ID          string             `json:"id"`
+	Name        string             `json:"name"`
+	Description string             `json:"description,omitempty"`
+	Author      string             `json:"author,omitempty"`
+	Regions     []RegionDefinition `json:"regions"`
+	// Spawns are seated in order, so the first N suit an N-player match.
+	Spawns []RegionID `json:"spawns"`
+}
+
+type RegionDefinition struct {
+	ID        RegionID   `json:"id"`
+	Name      string     `json:"name"`
+	Neighbors []RegionID `json:"neighbors"`
+	Garrison  int        `json:"garrison"`
+	Yield     Resources  `json:"yield"`
+	Buildings []Building `json:"buildings,omitempty"`
+}
+
+// MapValidationError lists every problem found in one map.
+type MapValidationError struct {
+	MapID    string
+	Problems []string
+}
+
+func (e *MapValidationError) Error() string {
+	return fmt.Sprintf("game: map %q is invalid: %s", e.MapID, strings.Join(e.Problems, "; "))
+}
+
+// ParseMap decodes a map file. Unknown fields are rejected so typos in a
+// hand-written file surface instead of being silently ignored.
+func ParseMap(data []byte) (*MapDefinition, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var def MapDefinition
+	if err := dec.Decode(&def); err != nil {
+		return nil, fmt.Errorf("game: parse map: %w", err)
+	}
+	return &def, nil
+}
+
+// Validate checks the schema version, the graph and the spawns, and returns a
+// *MapValidationError listing everything wrong, or nil.
+func (d *MapDefinition) Validate() error {
+	var problems []string
+	fail := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if d.Version < 1 || d.Version > MapSchemaVersion {
+		fail("unsupported version %d (this server reads up to %d)", d.Version, MapSchemaVersion)
+	}
+	if d.ID == "" {
+		fail("id is required")
+	}
+	if len(d.Regions) == 0 {
+		fail("no regions")
+	}
+
+	neighbors := make(map[RegionID]map[RegionID]bool, len(d.Regions))
+	for _, r := range d.Regions {
+		if r.ID == "" {
+			fail("region without an id")
+			continue
+		}
+		if _, dup := neighbors[r.ID]; dup {
+			fail("region %q defined twice", r.ID)
+			continue
+		}
+		neighbors[r.ID] = make(map[RegionID]bool, len(r.Neighbors))
+		for _, n := range r.Neighbors {
+			neighbors[r.ID][n] = true
+		}
+		if r.Garrison < 0 {
+			fail("region %q has a negative garrison", r.ID)
+		}
+		for _, b := range r.Buildings {
+			if _, ok := BuildingCosts[b]; !ok {
+				fail("region %q has unknown building %q", r.ID, b)
+			}
+		}
+	}
+
+	for _, r := range d.Regions {
+		for _, n := range r.Neighbors {
+			switch back, ok := neighbors[n]; {
+			case n == r.ID:
+				fail("region %q lists itself as a neighbour", r.ID)
+			case !ok:
+				fail("region %q has unknown neighbour %q", r.ID, n)
+			case !back[r.ID]:
+				fail("adjacency %q -> %q is not mirrored", r.ID, n)
+			}
+		}
+	}
+
+	if len(problems) == 0 {
+		w := d.World()
+		if reached := w.Distances(d.Regions[0].ID); len(reached) != len(w.Regions) {
+			fail("graph is disconnected: %d of %d regions reachable from %q", len(reached), len(w.Regions), d.Regions[0].ID)
+		} else {
+			problems = append(problems, spawnProblems(w, d.Spawns)...)
+		}
+	}
+
+	if len(problems) > 0 {
+		return &MapValidationError{MapID: d.ID, Problems: problems}
+	}
+	return nil
+}
+
+func spawnProblems(w *World, spawns []RegionID) []string {
+	if len(spawns) < 2 {
+		return []string{fmt.Sprintf("need at least 2 spawns, found %d", len(spawns))}
+	}
+
+	var problems []string
+	seen := make(map[RegionID]bool)
+	for _, s := range spawns {
+		switch {
+		case w.Region(s) == nil:
+			problems = append(problems, fmt.Sprintf("spawn %q is not a region", s))
+		case seen[s]:
+			problems = append(problems, fmt.Sprintf("spawn %q listed twice", s))
+		}
+		seen[s] = true
+	}
+	if len(problems) > 0 {
+		return problems
+	}
+
+	// Every prefix of the spawn list is a valid player count, so each must be fair.
+	for n := 2; n <= len(spawns); n++ {
+		lo, hi := rivalDistances(w, spawns[:n])
+		if hi-lo > MaxSpawnDistanceSpread {
+			problems = append(problems, fmt.Sprintf("with %d players the nearest rival is between %d and %d regions away", n, lo, hi))
+		}
+	}
+	return problems
+}
+
+// rivalDistances is the nearest and furthest any spawn is from its closest rival.
+func rivalDistances(w *World, spawns []RegionID) (lo, hi int) {
+	lo = -1
+	for i, s := range spawns {
+		dist := w.Distances(s)
+		closest := -1
+		for j, o := range spawns {
+			if i != j && (closest < 0 || dist[o] < closest) {
+				closest = dist[o]
+			}
+		}
+		if lo < 0 || closest < lo {
+			lo = closest
+		}
+		hi = max(hi, closest)
+	}
+	return lo, hi
+}
+
+// MaxPlayers is how many players the map seats.
+func (d *MapDefinition) MaxPlayers() int {
+	return len(d.Spawns)
+}
+
+// World builds a fresh world from the definition, with spawns as capitals.
+// Call Validate first; World does not check the graph.
+func (d *MapDefinition) World() *World {
+	w := NewWorld()
+	for _, r := range d.Regions {
+		_ = w.AddRegion(Region{
+			ID:        r.ID,
+			Name:      r.Name,
+			Garrison:  r.Garrison,
+			Yield:     r.Yield,
+			Buildings: append([]Building(nil), r.Buildings...),
+		})
+	}
+	for _, r := range d.Regions {
+		for _, n := range r.Neighbors {
+			_ = w.Connect(r.ID, n)
+		}
+	}
+	for _, s := range d.Spawns {
+		if r := w.Region(s); r != nil {
+			r.Capital = true
+		}
+	}
+	w.Spawns = append([]RegionID(nil), d.Spawns...)
+	return w
+}
+
+// MapRegistry holds every validated map, keyed by id.
+type MapRegistry struct {
+	maps map[string]*MapDefinition
+}
+
+func NewMapRegistry() *MapRegistry {
+	return &MapRegistry{maps: make(map[string]*MapDefinition)}
+}
+
+// Register validates a map and adds it.
+func (r *MapRegistry) Register(d *MapDefinition) error {
+	if err := d.Validate(); err != nil {
+		return err
+	}
+	if _, ok := r.maps[d.ID]; ok {
+		return fmt.Errorf("%w: %s", ErrDuplicateMap, d.ID)
+	}
+	r.maps[d.ID] = d
+	return nil
+}
+
+// LoadFS registers every *.json file in dir. It stops at the first bad file.
+func (r *MapRegistry) LoadFS(fsys fs.FS, dir string) error {
+	files, err := fs.Glob(fsys, path.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, name := range files {
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return fmt.Errorf("game: read map %s: %w", name, err)
+		}
+		def, err := ParseMap(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		if err := r.Register(def); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (r *MapRegistry) Get(id string) (*MapDefinition, error) {
+	d, ok := r.maps[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownMap, id)
+	}
+	return d, nil
+}
+
+// IDs lists the registered maps in a stable order.
+func (r *MapRegistry) IDs() []string {
+	ids := make([]string, 0, len(r.maps))
+	for id := range r.maps {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
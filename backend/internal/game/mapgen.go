@@ -0,0 +1,256 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ResourceLevel controls how generous generated yields are.
+type ResourceLevel string
+
+const (
+	ResourcesScarce   ResourceLevel = "scarce"
+	ResourcesBalanced ResourceLevel = "balanced"
+	ResourcesRich     ResourceLevel = "rich"
+)
+
+// Generator tuning.
+const (
+	MinRegionsPerPlayer = 3
+	CapitalGarrison     = 10
+	MaxNeutralGarrison  = 4
+)
+
+var CapitalYield = Resources{Gold: 5, Food: 5}
+
+// MaxMapAttempts is how many graphs GenerateMap rolls looking for fair spawns.
+const MaxMapAttempts = 8
+
+var (
+	ErrInvalidMapParams = errors.New("game: invalid map parameters")
+	ErrUnfairMap        = errors.New("game: no fair spawns found")
+)
+
+type MapParams struct {
+	Seed    uint64 `json:"seed,string"`
+	Players int    `json:"players"`
+	Regions int    `json:"regions"`
+	// Continents are densely linked clusters of regions.
+	Continents int `json:"continents"`
+	// Chokepoints is how many links join the continents; at least Continents-1.
+	Chokepoints int           `json:"chokepoints"`
+	Resources   ResourceLevel `json:"resources"`
+}
+
+// WithDefaults fills every zero field with a sensible value for the player count.
+func (p MapParams) WithDefaults() MapParams {
+	if p.Players == 0 {
+		p.Players = 2
+	}
+	if p.Regions == 0 {
+		p.Regions = p.Players * 5
+	}
+	if p.Continents == 0 {
+		p.Continents = 1 + p.Regions/20
+	}
+	if p.Chokepoints == 0 {
+		p.Chokepoints = p.Continents - 1
+	}
+	if p.Resources == "" {
+		p.Resources = ResourcesBalanced
+	}
+	return p
+}
+
+func (p MapParams) Validate() error {
+	switch {
+	case p.Players < 2:
+		return fmt.Errorf("%w: need at least 2 players, got %d", ErrInvalidMapParams, p.Players)
+	case p.Regions < p.Players*MinRegionsPerPlayer:
+		return fmt.Errorf("%w: %d players need at least %d regions", ErrInvalidMapParams, p.Players, p.Players*MinRegionsPerPlayer)
+	case p.Continents < 1 || p.Continents*2 > p.Regions:
+		return fmt.Errorf("%w: %d continents do not fit %d regions", ErrInvalidMapParams, p.Continents, p.Regions)
+	case p.Chokepoints < p.Continents-1:
+		return fmt.Errorf("%w: %d continents need at least %d chokepoints", ErrInvalidMapParams, p.Continents, p.Continents-1)
+	}
+	switch p.Resources {
+	case ResourcesScarce, ResourcesBalanced, ResourcesRich:
+	default:
+		return fmt.Errorf("%w: unknown resource level %q", ErrInvalidMapParams, p.Resources)
+	}
+	return nil
+}
+
+// GenerateMap builds a connected territory graph from params. The same params
+// always give the same map. Spawns are the set of regions that are furthest
+// apart from each other with the most even distances, and each one is made a
+// capital with identical garrison and yield. When the best spawns on a graph
+// leave some player's nearest rival more than MaxSpawnDistanceSpread regions
+// further away than another's, the graph is rolled again, up to
+// MaxMapAttempts times.
+func GenerateMap(params MapParams) (*World, error) {
+	params = params.WithDefaults()
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+	rng := NewRNG(params.Seed)
+	for range MaxMapAttempts {
+		w := generateGraph(params, rng)
+		spawns := pickSpawns(w, params.Players)
+		if lo, hi := rivalDistances(w, spawns); hi-lo > MaxSpawnDistanceSpread {
+			continue
+		}
+		w.Spawns = spawns
+		for _, id := range w.Spawns {
+			r := w.Regions[id]
+			r.Capital = true
+			r.Garrison = CapitalGarrison
+			r.Yield = CapitalYield
+		}
+		return w, nil
+	}
+	return nil, fmt.Errorf("%w: %d players on %d regions after %d attempts", ErrUnfairMap, params.Players, params.Regions, MaxMapAttempts)
+}
+
+// generateGraph rolls the regions and links of a map, without spawns.
+func generateGraph(params MapParams, rng *RNG) *World {
+	w := NewWorld()
+
+	ids := make([]RegionID, params.Regions)
+	for i := range ids {
+		ids[i] = RegionID(fmt.Sprintf("region-%02d", i+1))
+		_ = w.AddRegion(Region{
+			ID:       ids[i],
+			Name:     fmt.Sprintf("Region %d", i+1),
+			Garrison: 1 + rng.Intn(MaxNeutralGarrison),
+			Yield:    rollYield(rng, params.Resources),
+		})
+	}
+
+	// Split the regions into contiguous continents of near-equal size.
+	continents := make([][]RegionID, params.Continents)
+	for i, id := range ids {
+		c := i * params.Continents / params.Regions
+		continents[c] = append(continents[c], id)
+	}
+
+	for _, c := range continents {
+		// A random spanning tree keeps the continent connected...
+		for i := 1; i < len(c); i++ {
+			_ = w.Connect(c[i], c[rng.Intn(i)])
+		}
+		// ...and a few extra links give it more than one route.
+		for extra := len(c) / 2; extra > 0; extra-- {
+			a, b := c[rng.Intn(len(c))], c[rng.Intn(len(c))]
+			if a != b {
+				_ = w.Connect(a, b)
+			}
+		}
+	}
+
+	// Chain the continents together, then spend the remaining chokepoints on
+	// random pairs.
+	bridge := func(a, b []RegionID) {
+		_ = w.Connect(a[rng.Intn(len(a))], b[rng.Intn(len(b))])
+	}
+	for i := 1; i < len(continents); i++ {
+		bridge(continents[i-1], continents[i])
+	}
+	for i := len(continents) - 1; i < params.Chokepoints && len(continents) > 1; i++ {
+		a := rng.Intn(len(continents))
+		b := (a + 1 + rng.Intn(len(continents)-1)) % len(continents)
+		bridge(continents[a], continents[b])
+	}
+	return w
+}
+
+func rollYield(rng *RNG, level ResourceLevel) Resources {
+	base := Resources{Gold: rng.Intn(3), Food: 1 + rng.Intn(3)}
+	switch level {
+	case ResourcesScarce:
+		base.Gold /= 2
+		base.Food = max(1, base.Food-1)
+	case ResourcesRich:
+		base = base.Add(Resources{Gold: 1, Food: 1})
+	}
+	return base
+}
+
+// Distances returns the hop count from start to every reachable region.
+func (w *World) Distances(start RegionID) map[RegionID]int {
+	dist := map[RegionID]int{start: 0}
+	queue := []RegionID{start}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, n := range w.Regions[id].Neighbors {
+			if _, ok := dist[n]; !ok {
+				dist[n] = dist[id] + 1
+				queue = append(queue, n)
+			}
+		}
+	}
+	return dist
+}
+
+// pickSpawns tries every region as the first spawn, greedily adds the region
+// furthest from those already chosen, and keeps the candidate set whose
+// closest pair is furthest apart, preferring the most even spacing on ties.
+func pickSpawns(w *World, n int) []RegionID {
+	ids := w.RegionIDs()
+	dist := make(map[RegionID]map[RegionID]int, len(ids))
+	for _, id := range ids {
+		dist[id] = w.Distances(id)
+	}
+
+	var best []RegionID
+	bestMin, bestSpread := -1, 0
+	for _, first := range ids {
+		chosen := []RegionID{first}
+		for len(chosen) < n {
+			var next RegionID
+			far := -1
+			for _, id := range ids {
+				closest := nearest(dist, chosen, id)
+				if closest > far {
+					far, next = closest, id
+				}
+			}
+			chosen = append(chosen, next)
+		}
+
+		lo, hi := -1, 0
+		for _, id := range chosen {
+			d := nearest(dist, without(chosen, id), id)
+			if lo < 0 || d < lo {
+				lo = d
+			}
+			hi = max(hi, d)
+		}
+		if lo > bestMin || (lo == bestMin && hi-lo < bestSpread) {
+			best, bestMin, bestSpread = chosen, lo, hi-lo
+		}
+	}
+	return best
+}
+
+// nearest is the distance from id to the closest region in set.
+func nearest(dist map[RegionID]map[RegionID]int, set []RegionID, id RegionID) int {
+	closest := -1
+	for _, s := range set {
+		if d := dist[s][id]; closest < 0 || d < closest {
+			closest = d
+		}
+	}
+	return closest
+}
+
+func without(set []RegionID, id RegionID) []RegionID {
+	out := make([]RegionID, 0, len(set))
+	for _, s := range set {
+		if s != id {
+			out = append(out, s)
+		}
+	}
+	return out
+}
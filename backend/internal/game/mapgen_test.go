@@ -0,0 +1,89 @@
+package game
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestGenerateMap(t *testing.T) {
+	for _, params := range []MapParams{
+		{Players: 2},
+		{Players: 3, Regions: 15},
+		{Players: 4, Regions: 20, Continents: 2},
+		{Players: 6, Regions: 30, Continents: 3, Chokepoints: 4, Resources: ResourcesScarce},
+		{Players: 8, Regions: 40, Continents: 3, Resources: ResourcesRich},
+	} {
+		for seed := uint64(1); seed <= 20; seed++ {
+			params.Seed = seed
+			w, err := GenerateMap(params)
+			if err != nil {
+				t.Fatalf("%+v: %v", params, err)
+			}
+			regions := params.WithDefaults().Regions
+			if len(w.Regions) != regions {
+				t.Fatalf("%+v: %d regions, want %d", params, len(w.Regions), regions)
+			}
+			if reached := w.Distances("region-01"); len(reached) != len(w.Regions) {
+				t.Errorf("%+v: only %d of %d regions connected", params, len(reached), len(w.Regions))
+			}
+			for _, id := range w.RegionIDs() {
+				for _, n := range w.Regions[id].Neighbors {
+					if !w.Adjacent(n, id) {
+						t.Errorf("%+v: %s -> %s is one way", params, id, n)
+					}
+				}
+			}
+
+			if len(w.Spawns) != params.Players {
+				t.Fatalf("%+v: %d spawns for %d players", params, len(w.Spawns), params.Players)
+			}
+			seen := make(map[RegionID]bool)
+			for _, id := range w.Spawns {
+				r := w.Regions[id]
+				if seen[id] {
+					t.Errorf("%+v: spawn %s picked twice", params, id)
+				}
+				seen[id] = true
+				if !r.Capital || r.Garrison != CapitalGarrison || r.Yield != CapitalYield {
+					t.Errorf("%+v: spawn %s starts with %d units and %v", params, id, r.Garrison, r.Yield)
+				}
+			}
+			if lo, hi := rivalDistances(w, w.Spawns); hi-lo > MaxSpawnDistanceSpread {
+				t.Errorf("%+v: nearest rival between %d and %d regions away", params, lo, hi)
+			}
+
+			again, _ := GenerateMap(params)
+			if !reflect.DeepEqual(w, again) {
+				t.Errorf("%+v: same params gave a different map", params)
+			}
+		}
+	}
+}
+
+func TestGenerateMapRerollsUnfairSpawns(t *testing.T) {
+	params := MapParams{Players: 8, Regions: 24, Continents: 8, Chokepoints: 7}.WithDefaults()
+	rerolled := 0
+	for seed := uint64(1); seed <= 100; seed++ {
+		params.Seed = seed
+		first := generateGraph(params, NewRNG(seed))
+		if lo, hi := rivalDistances(first, pickSpawns(first, params.Players)); hi-lo <= MaxSpawnDistanceSpread {
+			continue
+		}
+		rerolled++
+
+		w, err := GenerateMap(params)
+		if err != nil {
+			if !errors.Is(err, ErrUnfairMap) {
+				t.Fatalf("seed %d: %v", seed, err)
+			}
+			continue
+		}
+		if lo, hi := rivalDistances(w, w.Spawns); hi-lo > MaxSpawnDistanceSpread {
+			t.Errorf("seed %d: kept spawns whose nearest rival is between %d and %d regions away", seed, lo, hi)
+		}
+	}
+	if rerolled == 0 {
+		t.Fatal("no seed rolled unfair spawns first; pick params that do")
+	}
+}
@@ -0,0 +1,22 @@
+// Package maps embeds the designer-authored map files so they ship inside
+// the plugin binary.
+package maps
+
+import (
+	"embed"
+
+	"github.com/delta/terrabound/backend/internal/game"
+)
+
+//go:embed *.json
+var files embed.FS
+
+// Load parses and validates every embedded map. A bad map file is a build
+// mistake, so callers should refuse to start rather than skip it.
+func Load() (*game.MapRegistry, error) {
+	registry := game.NewMapRegistry()
+	if err := registry.LoadFS(files, "."); err != nil {
+		return nil, err
+	}
+	return registry, nil
+}
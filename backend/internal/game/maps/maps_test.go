@@ -0,0 +1,44 @@
+package maps
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/delta/terrabound/backend/internal/game"
+)
+
+func TestEmbeddedMapsValid(t *testing.T) {
+	names, err := fs.Glob(files, "*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) == 0 {
+		t.Fatal("no maps embedded")
+	}
+	for _, name := range names {
+		t.Run(name, func(t *testing.T) {
+			data, err := fs.ReadFile(files, name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			def, err := game.ParseMap(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := def.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			if def.ID+".json" != name {
+				t.Errorf("map id %q does not match its file name", def.ID)
+			}
+		})
+	}
+
+	registry, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(registry.IDs()); got != len(names) {
+		t.Errorf("registry holds %d maps, %d files embedded", got, len(names))
+	}
+}
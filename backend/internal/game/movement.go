@@ -0,0 +1,141 @@
+package game
+
+import "math"
+
+// Vec2 is a point or direction on the free-movement plane.
+type Vec2 struct {
+	X float32 `json:"x"`
+	Y float32 `json:"y"`
+}
+
+func (v Vec2) Add(o Vec2) Vec2 { return Vec2{v.X + o.X, v.Y + o.Y} }
+
+func (v Vec2) Scale(f float32) Vec2 { return Vec2{v.X * f, v.Y * f} }
+
+func (v Vec2) Len() float32 {
+	return float32(math.Hypot(float64(v.X), float64(v.Y)))
+}
+
+func (v Vec2) finite() bool {
+	for _, f := range []float32{v.X, v.Y} {
+		if math.IsNaN(float64(f)) || math.IsInf(float64(f), 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// Bounds is the playable rectangle.
+type Bounds struct {
+	Min Vec2 `json:"min"`
+	Max Vec2 `json:"max"`
+}
+
+func (b Bounds) Clamp(p Vec2) Vec2 {
+	return Vec2{
+		X: min(max(p.X, b.Min.X), b.Max.X),
+		Y: min(max(p.Y, b.Min.Y), b.Max.Y),
+	}
+}
+
+// MoveIntent is what a client sends instead of a position: which way it wants
+// to go. Seq must increase with every intent so replays and reordered packets
+// are dropped. Position is only set by older clients that still report where
+// they think they are; it is accepted only if it was reachable.
+type MoveIntent struct {
+	Seq       uint32 `json:"seq"`
+	Direction Vec2   `json:"direction"`
+	Position  *Vec2  `json:"position,omitempty"`
+}
+
+type MoveRejection string
+
+const (
+	RejectStaleSequence    MoveRejection = "stale_sequence"
+	RejectInvalidDirection MoveRejection = "invalid_direction"
+	RejectImpossibleJump   MoveRejection = "impossible_jump"
+)
+
+// MovementRules bound how fast anything may move and where.
+type MovementRules struct {
+	// MaxSpeed is in world units per second.
+	MaxSpeed float32
+	Bounds   Bounds
+	// JumpTolerance is how far past what MaxSpeed allows a player may get in
+	// all, to absorb jitter between client and server clocks. It is granted
+	// once, as part of the most a player can bank, not with every report.
+	JumpTolerance float32
+	// FixWindow is the most seconds of unused movement a player can bank, so
+	// standing still, being held or reconnecting never earns a long jump.
+	FixWindow float32
+}
+
+// Mover is the server's authoritative motion state for one player.
+type Mover struct {
+	Position  Vec2
+	Direction Vec2
+	Seq       uint32
+	// Reach is how much further the player may move right now. Time adds to
+	// it at MaxSpeed up to the rules' cap; moving along Direction and
+	// reported positions both spend it, so the two never add up to more than
+	// MaxSpeed.
+	Reach float32
+	// Fixed is set when a report placed the player this tick, so Step does
+	// not move them again on top of it.
+	Fixed bool
+}
+
+// maxReach is the most movement a player can have banked.
+func (r MovementRules) maxReach() float32 {
+	return r.MaxSpeed*r.FixWindow + r.JumpTolerance
+}
+
+// Apply records an intent, or says why it was refused. A refused intent
+// leaves the mover untouched.
+func (r MovementRules) Apply(m *Mover, in MoveIntent) (MoveRejection, bool) {
+	if in.Seq <= m.Seq {
+		return RejectStaleSequence, false
+	}
+	// Diagonals are allowed up to unit length; anything longer asks for more
+	// than MaxSpeed.
+	if !in.Direction.finite() || in.Direction.Len() > 1.001 {
+		return RejectInvalidDirection, false
+	}
+	if in.Position != nil {
+		if !in.Position.finite() {
+			return RejectImpossibleJump, false
+		}
+		to := r.Bounds.Clamp(*in.Position)
+		jump := to.Add(m.Position.Scale(-1)).Len()
+		if jump > m.Reach {
+			return RejectImpossibleJump, false
+		}
+		m.Reach -= jump
+		m.Position = to
+		m.Fixed = true
+	}
+	m.Seq = in.Seq
+	m.Direction = in.Direction
+	return "", true
+}
+
+// Step advances the mover dt seconds along its current direction and keeps it
+// inside the bounds. A mover a report already placed this tick stays put.
+func (r MovementRules) Step(m *Mover, dt float32) {
+	m.Reach = min(m.Reach+r.MaxSpeed*dt, r.maxReach())
+	if m.Fixed {
+		m.Fixed = false
+		return
+	}
+	next := r.Bounds.Clamp(m.Position.Add(m.Direction.Scale(r.MaxSpeed * dt)))
+	m.Reach = max(m.Reach-next.Add(m.Position.Scale(-1)).Len(), 0)
+	m.Position = next
+}
+
+// Hold stops the mover and spends whatever movement it had banked, as when
+// its player drops out.
+func (m *Mover) Hold() {
+	m.Direction = Vec2{}
+	m.Reach = 0
+	m.Fixed = false
+}
@@ -0,0 +1,75 @@
+package game
+
+import "testing"
+
+func testRules() MovementRules {
+	return MovementRules{
+		MaxSpeed:      5,
+		Bounds:        Bounds{Min: Vec2{-1000, -1000}, Max: Vec2{1000, 1000}},
+		JumpTolerance: 1,
+		FixWindow:     0.5,
+	}
+}
+
+// run steps the mover for ticks at tickRate, reporting a position step past
+// where it is every reportEvery ticks, and returns how far it got.
+func run(r MovementRules, m *Mover, tickRate, reportEvery, ticks int, step Vec2, direction Vec2) float32 {
+	start := m.Position
+	dt := 1 / float32(tickRate)
+	for tick := 1; tick <= ticks; tick++ {
+		r.Step(m, dt)
+		if tick%reportEvery == 0 {
+			to := m.Position.Add(step)
+			r.Apply(m, MoveIntent{Seq: m.Seq + 1, Direction: direction, Position: &to})
+		}
+	}
+	return m.Position.Add(start.Scale(-1)).Len()
+}
+
+func TestMovementNeverOutrunsMaxSpeed(t *testing.T) {
+	const seconds = 10
+	limit := testRules().MaxSpeed*seconds + testRules().maxReach()
+	for _, tc := range []struct {
+		name      string
+		step      Vec2
+		direction Vec2
+	}{
+		// A 10 Hz position-only client jumping as far as the old per-report tolerance allowed.
+		{"legacy reports", Vec2{1.5, 0}, Vec2{}},
+		// Reports on top of a direction the server also integrates.
+		{"reports and direction", Vec2{0.5, 0}, Vec2{1, 0}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m := &Mover{}
+			if got := run(testRules(), m, 20, 2, 20*seconds, tc.step, tc.direction); got > limit {
+				t.Errorf("moved %.1f in %ds, limit %.1f", got, seconds, limit)
+			}
+		})
+	}
+}
+
+func TestMovementHonestReportsAccepted(t *testing.T) {
+	r := testRules()
+	m := &Mover{}
+	// Full speed, reported every 0.1s.
+	run(r, m, 20, 2, 200, Vec2{r.MaxSpeed * 0.1, 0}, Vec2{})
+	if m.Seq != 100 {
+		t.Errorf("accepted %d of 100 honest reports", m.Seq)
+	}
+}
+
+func TestMovementIdleBanksLittle(t *testing.T) {
+	r := testRules()
+	m := &Mover{}
+	for range 20 * 60 {
+		r.Step(m, 0.05)
+	}
+	far := Vec2{r.MaxSpeed * 10, 0}
+	if reason, ok := r.Apply(m, MoveIntent{Seq: 1, Position: &far}); ok || reason != RejectImpossibleJump {
+		t.Errorf("jump after a minute idle = %q, %v; want rejected", reason, ok)
+	}
+	near := Vec2{r.maxReach(), 0}
+	if _, ok := r.Apply(m, MoveIntent{Seq: 2, Position: &near}); !ok {
+		t.Errorf("jump within the banked reach refused")
+	}
+}
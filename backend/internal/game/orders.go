@@ -0,0 +1,245 @@
+package game
+
+import "fmt"
+
+type Action string
+
+const (
+	// ActionMove shifts units between two regions held by the same side.
+	ActionMove Action = "move"
+	// ActionAttack sends units into a region held by someone else.
+	ActionAttack Action = "attack"
+	// ActionBuild starts a building in a region the player holds.
+	ActionBuild Action = "build"
+	// ActionRecruit buys units in a region with barracks.
+	ActionRecruit Action = "recruit"
+)
+
+// MinGarrison is how many units must stay behind in a region that issues orders.
+const MinGarrison = 1
+
+type Order struct {
+	PlayerID PlayerID `json:"playerId"`
+	Action   Action   `json:"action"`
+	From     RegionID `json:"from,omitempty"`
+	Target   RegionID `json:"target"`
+	Units    int      `json:"units,omitempty"`
+	Building Building `json:"building,omitempty"`
+}
+
+// Violation codes returned by ValidateOrder.
+const (
+	ViolationUnknownPlayer     = "unknown_player"
+	ViolationEliminated        = "player_eliminated"
+	ViolationUnknownAction     = "unknown_action"
+	ViolationUnknownRegion     = "unknown_region"
+	ViolationNotOwner          = "not_owner"
+	ViolationNotAdjacent       = "not_adjacent"
+	ViolationInvalidUnits      = "invalid_units"
+	ViolationInsufficientUnits = "insufficient_units"
+	ViolationFriendlyTarget    = "friendly_target"
+	ViolationHostileTarget     = "hostile_target"
+	ViolationUnknownBuilding   = "unknown_building"
+	ViolationAlreadyBuilt      = "already_built"
+	ViolationNoBarracks        = "no_barracks"
+	ViolationInsufficientFunds = "insufficient_funds"
+)
+
+type Violation struct {
+	Code    string `json:"code"`
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func violation(code, field, format string, args ...interface{}) Violation {
+	return Violation{Code: code, Field: field, Message: fmt.Sprintf(format, args...)}
+}
+
+// commitments tracks what earlier orders in a batch have already used up.
+type commitments struct {
+	units  map[RegionID]int
+	spent  map[PlayerID]Resources
+	builds map[RegionID][]Building
+}
+
+func newCommitments() *commitments {
+	return &commitments{
+		units:  make(map[RegionID]int),
+		spent:  make(map[PlayerID]Resources),
+		builds: make(map[RegionID][]Building),
+	}
+}
+
+func (c *commitments) add(o Order) {
+	switch o.Action {
+	case ActionMove, ActionAttack:
+		c.units[o.From] += o.Units
+	case ActionBuild:
+		c.spent[o.PlayerID] = c.spent[o.PlayerID].Add(BuildingCosts[o.Building])
+		c.builds[o.Target] = append(c.builds[o.Target], o.Building)
+	case ActionRecruit:
+		c.spent[o.PlayerID] = c.spent[o.PlayerID].Add(RecruitCost.Times(o.Units))
+	}
+}
+
+// ValidateOrder checks an order against the world and returns it normalised.
+// When From is empty the best adjacent region the player owns is picked, which
+// lets clients send just an action and a target. An empty violation list means
+// the order can be submitted.
+func ValidateOrder(w *World, o Order) (Order, []Violation) {
+	return validateOrder(w, o, newCommitments())
+}
+
+// validateOrder is ValidateOrder with whatever earlier orders of the same
+// batch committed taken out of the player's units and treasury.
+func validateOrder(w *World, o Order, c *commitments) (Order, []Violation) {
+	var out []Violation
+
+	player := w.Player(o.PlayerID)
+	switch {
+	case player == nil:
+		out = append(out, violation(ViolationUnknownPlayer, "playerId", "player %q is not in this match", o.PlayerID))
+	case player.Eliminated:
+		out = append(out, violation(ViolationEliminated, "playerId", "player %q has been eliminated", o.PlayerID))
+	}
+
+	switch o.Action {
+	case ActionMove, ActionAttack:
+		return validateMovement(w, o, c, out)
+	case ActionBuild:
+		return o, validateBuild(w, player, o, c, out)
+	case ActionRecruit:
+		return o, validateRecruit(w, player, o, c, out)
+	default:
+		out = append(out, violation(ViolationUnknownAction, "action", "action %q is not supported", o.Action))
+		return o, out
+	}
+}
+
+func validateMovement(w *World, o Order, c *commitments, out []Violation) (Order, []Violation) {
+	if o.Units <= 0 {
+		out = append(out, violation(ViolationInvalidUnits, "units", "units must be positive, got %d", o.Units))
+	}
+
+	target := w.Region(o.Target)
+	if target == nil {
+		out = append(out, violation(ViolationUnknownRegion, "target", "region %q does not exist", o.Target))
+		return o, out
+	}
+
+	if o.From == "" {
+		o.From = pickSource(w, o.PlayerID, o.Target, c)
+	}
+	if o.From == "" {
+		out = append(out, violation(ViolationNotAdjacent, "from", "player %q holds no region adjacent to %q", o.PlayerID, o.Target))
+		return o, out
+	}
+
+	source := w.Region(o.From)
+	if source == nil {
+		out = append(out, violation(ViolationUnknownRegion, "from", "region %q does not exist", o.From))
+		return o, out
+	}
+	// Nothing more is said about a region the player does not hold, which
+	// may be an enemy's under fog.
+	if source.Owner != o.PlayerID {
+		return o, append(out, violation(ViolationNotOwner, "from", "region %q is not held by %q", o.From, o.PlayerID))
+	}
+	if !w.Adjacent(o.From, o.Target) {
+		out = append(out, violation(ViolationNotAdjacent, "target", "region %q does not border %q", o.Target, o.From))
+	}
+
+	if available := available(source, c); o.Units > 0 && o.Units > available {
+		out = append(out, violation(ViolationInsufficientUnits, "units", "region %q cannot spare %d units", o.From, o.Units))
+	}
+
+	switch o.Action {
+	case ActionMove:
+		if !w.Allied(o.PlayerID, target.Owner) {
+			out = append(out, violation(ViolationHostileTarget, "target", "cannot move into %q; it is not held by your side", o.Target))
+		}
+	case ActionAttack:
+		if w.Allied(o.PlayerID, target.Owner) {
+			out = append(out, violation(ViolationFriendlyTarget, "target", "cannot attack %q; it is held by your side", o.Target))
+		}
+	}
+
+	return o, out
+}
+
+func validateBuild(w *World, player *Player, o Order, c *commitments, out []Violation) []Violation {
+	cost, ok := BuildingCosts[o.Building]
+	if !ok {
+		out = append(out, violation(ViolationUnknownBuilding, "building", "building %q does not exist", o.Building))
+	}
+
+	target := w.Region(o.Target)
+	if target == nil {
+		return append(out, violation(ViolationUnknownRegion, "target", "region %q does not exist", o.Target))
+	}
+	if target.Owner != o.PlayerID {
+		return append(out, violation(ViolationNotOwner, "target", "region %q is not held by %q", o.Target, o.PlayerID))
+	}
+	if target.Has(o.Building) || containsBuilding(c.builds[o.Target], o.Building) {
+		out = append(out, violation(ViolationAlreadyBuilt, "building", "region %q already has a %s", o.Target, o.Building))
+	}
+
+	if ok && player != nil && !canAfford(player, c, cost) {
+		out = append(out, violation(ViolationInsufficientFunds, "building", "a %s costs %s", o.Building, cost))
+	}
+	return out
+}
+
+func validateRecruit(w *World, player *Player, o Order, c *commitments, out []Violation) []Violation {
+	if o.Units <= 0 {
+		out = append(out, violation(ViolationInvalidUnits, "units", "units must be positive, got %d", o.Units))
+	}
+
+	target := w.Region(o.Target)
+	if target == nil {
+		return append(out, violation(ViolationUnknownRegion, "target", "region %q does not exist", o.Target))
+	}
+	if target.Owner != o.PlayerID {
+		return append(out, violation(ViolationNotOwner, "target", "region %q is not held by %q", o.Target, o.PlayerID))
+	}
+	if !target.Has(BuildingBarracks) {
+		out = append(out, violation(ViolationNoBarracks, "target", "region %q has no barracks", o.Target))
+	}
+
+	if cost := RecruitCost.Times(o.Units); o.Units > 0 && player != nil && !canAfford(player, c, cost) {
+		out = append(out, violation(ViolationInsufficientFunds, "units", "recruiting %d units costs %s", o.Units, cost))
+	}
+	return out
+}
+
+func canAfford(p *Player, c *commitments, cost Resources) bool {
+	return p.Treasury.Sub(c.spent[p.ID]).Covers(cost)
+}
+
+// available is how many units a region can still send this turn.
+func available(r *Region, c *commitments) int {
+	n := r.Garrison - MinGarrison - c.units[r.ID]
+	if n < 0 {
+		return 0
+	}
+	return n
+}
+
+// pickSource chooses the owned neighbour of target with the most spare units,
+// breaking ties by region id so the choice is deterministic.
+func pickSource(w *World, p PlayerID, target RegionID, c *commitments) RegionID {
+	var best *Region
+	for _, id := range w.RegionIDs() {
+		r := w.Regions[id]
+		if r.Owner != p || !w.Adjacent(id, target) {
+			continue
+		}
+		if best == nil || available(r, c) > available(best, c) {
+			best = r
+		}
+	}
+	if best == nil {
+		return ""
+	}
+	return best.ID
+}
@@ -0,0 +1,143 @@
+package game
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestValidateOrderKeepsEnemyGarrisonsHidden(t *testing.T) {
+	w := SampleWorld()
+	w.Regions["capital-2"].Garrison = 37
+	for _, o := range []Order{
+		{PlayerID: "user-1", Action: ActionAttack, From: "capital-2", Target: "plains-2", Units: 50},
+		{PlayerID: "user-1", Action: ActionRecruit, Target: "capital-2", Units: 50},
+		{PlayerID: "user-1", Action: ActionBuild, Target: "capital-2", Building: BuildingBarracks},
+	} {
+		_, violations := ValidateOrder(w, o)
+		if len(violations) != 1 || violations[0].Code != ViolationNotOwner {
+			t.Errorf("%s from %s: violations %+v, want only %s", o.Action, o.From, violations, ViolationNotOwner)
+		}
+		for _, v := range violations {
+			if strings.Contains(v.Message, strconv.Itoa(37)) || strings.Contains(v.Message, strconv.Itoa(36)) {
+				t.Errorf("violation %q gives away the garrison", v.Message)
+			}
+		}
+	}
+}
+
+func TestValidateOrder(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		order    Order
+		wantFrom RegionID
+		want     []string
+	}{
+		{
+			name:  "move to own region",
+			order: Order{PlayerID: "user-1", Action: ActionMove, From: "capital-1", Target: "plains-1", Units: 9},
+		},
+		{
+			name:     "attack with source picked",
+			order:    Order{PlayerID: "user-1", Action: ActionAttack, Target: "forest", Units: 5},
+			wantFrom: "capital-1",
+		},
+		{
+			name:  "garrison must stay",
+			order: Order{PlayerID: "user-1", Action: ActionMove, From: "capital-1", Target: "plains-1", Units: 10},
+			want:  []string{ViolationInsufficientUnits},
+		},
+		{
+			name:  "zero units",
+			order: Order{PlayerID: "user-1", Action: ActionAttack, From: "plains-1", Target: "river", Units: 0},
+			want:  []string{ViolationInvalidUnits},
+		},
+		{
+			name:  "not adjacent",
+			order: Order{PlayerID: "user-1", Action: ActionAttack, From: "capital-1", Target: "river", Units: 2},
+			want:  []string{ViolationNotAdjacent},
+		},
+		{
+			name:  "no adjacent source",
+			order: Order{PlayerID: "user-1", Action: ActionAttack, Target: "capital-2", Units: 2},
+			want:  []string{ViolationNotAdjacent},
+		},
+		{
+			name:  "attack own region",
+			order: Order{PlayerID: "user-1", Action: ActionAttack, From: "capital-1", Target: "plains-1", Units: 2},
+			want:  []string{ViolationFriendlyTarget},
+		},
+		{
+			name:  "move into enemy region",
+			order: Order{PlayerID: "user-1", Action: ActionMove, From: "plains-1", Target: "river", Units: 1},
+			want:  []string{ViolationHostileTarget},
+		},
+		{
+			name:  "unknown target",
+			order: Order{PlayerID: "user-1", Action: ActionAttack, From: "plains-1", Target: "atlantis", Units: 1},
+			want:  []string{ViolationUnknownRegion},
+		},
+		{
+			name:  "unknown player",
+			order: Order{PlayerID: "user-9", Action: ActionBuild, Target: "capital-1", Building: BuildingFort},
+			want:  []string{ViolationUnknownPlayer, ViolationNotOwner},
+		},
+		{
+			name:  "unknown action",
+			order: Order{PlayerID: "user-1", Action: "pray", Target: "capital-1"},
+			want:  []string{ViolationUnknownAction},
+		},
+		{
+			name:  "build",
+			order: Order{PlayerID: "user-1", Action: ActionBuild, Target: "capital-1", Building: BuildingMarket},
+		},
+		{
+			name:  "build unaffordable",
+			order: Order{PlayerID: "user-1", Action: ActionBuild, Target: "capital-1", Building: BuildingFort},
+			want:  []string{ViolationInsufficientFunds},
+		},
+		{
+			name:  "unknown building",
+			order: Order{PlayerID: "user-1", Action: ActionBuild, Target: "capital-1", Building: "moat"},
+			want:  []string{ViolationUnknownBuilding},
+		},
+		{
+			name:  "recruit without barracks",
+			order: Order{PlayerID: "user-1", Action: ActionRecruit, Target: "capital-1", Units: 1},
+			want:  []string{ViolationNoBarracks},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, violations := ValidateOrder(SampleWorld(), tc.order)
+			var codes []string
+			for _, v := range violations {
+				codes = append(codes, v.Code)
+			}
+			if strings.Join(codes, ",") != strings.Join(tc.want, ",") {
+				t.Errorf("violations %v, want %v", codes, tc.want)
+			}
+			if tc.wantFrom != "" && got.From != tc.wantFrom {
+				t.Errorf("from = %q, want %q", got.From, tc.wantFrom)
+			}
+		})
+	}
+}
+
+func TestValidateOrdersShareUnitsAndTreasury(t *testing.T) {
+	w := SampleWorld()
+	w.Regions["capital-1"].Buildings = []Building{BuildingBarracks}
+	accepted, rejected := ValidateOrders(w, []Order{
+		{PlayerID: "user-1", Action: ActionAttack, From: "capital-1", Target: "forest", Units: 6},
+		{PlayerID: "user-1", Action: ActionMove, From: "capital-1", Target: "plains-1", Units: 4},
+		{PlayerID: "user-1", Action: ActionRecruit, Target: "capital-1", Units: 3},
+		{PlayerID: "user-1", Action: ActionBuild, Target: "capital-1", Building: BuildingMarket},
+	})
+	if len(accepted) != 2 || len(rejected) != 2 {
+		t.Fatalf("accepted %d, rejected %d; want 2 and 2", len(accepted), len(rejected))
+	}
+	for i, want := range []string{ViolationInsufficientUnits, ViolationInsufficientFunds} {
+		if got := rejected[i].Violations[0].Code; got != want {
+			t.Errorf("rejection %d: %s, want %s", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package game
+
+import "hash/fnv"
+
+// RNG is a splitmix64 generator. The game never touches math/rand so that a
+// seed alone is enough to replay any result, on any platform or Go version.
+type RNG struct {
+	State uint64 `json:"state"`
+}
+
+func NewRNG(seed uint64) *RNG {
+	return &RNG{State: seed}
+}
+
+func (r *RNG) Uint64() uint64 {
+	r.State += 0x9e3779b97f4a7c15
+	z := r.State
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+// Intn returns a value in [0, n). It panics if n <= 0, like math/rand.
+func (r *RNG) Intn(n int) int {
+	if n <= 0 {
+		panic("game: RNG.Intn called with n <= 0")
+	}
+	// Reject the top sliver of the range so every value is equally likely.
+	limit := ^uint64(0) - ^uint64(0)%uint64(n)
+	for {
+		if v := r.Uint64(); v < limit {
+			return int(v % uint64(n))
+		}
+	}
+}
+
+// DeriveSeed mixes a base seed with labels into an independent seed, so a
+// single match seed can give every battle its own reproducible stream.
+func DeriveSeed(seed uint64, labels ...string) uint64 {
+	h := fnv.New64a()
+	for _, l := range labels {
+		_, _ = h.Write([]byte(l))
+		_, _ = h.Write([]byte{0})
+	}
+	return NewRNG(seed ^ h.Sum64()).Uint64()
+}
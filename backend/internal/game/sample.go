@@ -0,0 +1,54 @@
+package game
+
+// SampleMap builds the small two-seat map used until real maps exist. Every
+// region starts neutral; players take the capitals through World.Seat. It is
+// rebuilt on every call so callers may mutate it.
+//
+//	capital-1 -- plains-1 -- river -- plains-2 -- capital-2
+//	     \                  /    \                 /
+//	      `---- forest ----'      `-- mountain ---'
+func SampleMap() *World {
+	w := NewWorld()
+
+	regions := []Region{
+		{ID: "capital-1", Name: "Northhold", Garrison: 10, Capital: true, Yield: Resources{Gold: 5, Food: 5}},
+		{ID: "plains-1", Name: "Northern Plains", Garrison: 3, Yield: Resources{Gold: 1, Food: 4}},
+		{ID: "forest", Name: "Greywood", Garrison: 2, Yield: Resources{Gold: 1, Food: 2}},
+		{ID: "river", Name: "River Crossing", Garrison: 2, Yield: Resources{Gold: 3, Food: 3}},
+		{ID: "mountain", Name: "Iron Pass", Garrison: 4, Yield: Resources{Gold: 4}},
+		{ID: "plains-2", Name: "Southern Plains", Garrison: 3, Yield: Resources{Gold: 1, Food: 4}},
+		{ID: "capital-2", Name: "Southwatch", Garrison: 10, Capital: true, Yield: Resources{Gold: 5, Food: 5}},
+	}
+	for _, r := range regions {
+		_ = w.AddRegion(r)
+	}
+
+	links := [][2]RegionID{
+		{"capital-1", "plains-1"},
+		{"capital-1", "forest"},
+		{"plains-1", "river"},
+		{"forest", "river"},
+		{"river", "plains-2"},
+		{"river", "mountain"},
+		{"plains-2", "capital-2"},
+		{"mountain", "capital-2"},
+	}
+	for _, l := range links {
+		_ = w.Connect(l[0], l[1])
+	}
+
+	w.Spawns = []RegionID{"capital-1", "capital-2"}
+	return w
+}
+
+// SampleWorld is SampleMap mid-game: "user-1" and "user-2" are seated and each
+// holds the plains next to their capital. The "debug" match id validates
+// orders against it.
+func SampleWorld() *World {
+	w := SampleMap()
+	_, _ = w.Seat(Player{ID: "user-1", Name: "Player 1"})
+	_, _ = w.Seat(Player{ID: "user-2", Name: "Player 2"})
+	w.Regions["plains-1"].Owner = "user-1"
+	w.Regions["plains-2"].Owner = "user-2"
+	return w
+}
@@ -0,0 +1,274 @@
+package game
+
+import "sort"
+
+type Phase string
+
+const (
+	// PhaseLobby is the wait for enough players before turn one.
+	PhaseLobby Phase = "lobby"
+	// PhasePlanning accepts and replaces orders.
+	PhasePlanning Phase = "planning"
+	// PhaseLockIn freezes orders briefly so late packets cannot sneak in.
+	PhaseLockIn Phase = "lock_in"
+	// PhaseResolution applies every player's orders at once.
+	PhaseResolution Phase = "resolution"
+)
+
+// RejectedOrder pairs an order with the reasons it was dropped.
+type RejectedOrder struct {
+	Order      Order       `json:"order"`
+	Violations []Violation `json:"violations"`
+}
+
+// ValidateOrders checks a batch from one or more players. Orders drawing on
+// the same region share its spare units, first come first served, so a batch
+// that is valid here is valid to resolve.
+func ValidateOrders(w *World, orders []Order) ([]Order, []RejectedOrder) {
+	c := newCommitments()
+	var accepted []Order
+	var rejected []RejectedOrder
+	for _, o := range orders {
+		norm, vs := validateOrder(w, o, c)
+		if len(vs) > 0 {
+			rejected = append(rejected, RejectedOrder{Order: norm, Violations: vs})
+			continue
+		}
+		c.add(norm)
+		accepted = append(accepted, norm)
+	}
+	return accepted, rejected
+}
+
+// Force is one side's units in a battle.
+type Force struct {
+	Player PlayerID `json:"player"`
+	Units  int      `json:"units"`
+}
+
+// Battle is every hostile force that ended up in the same region this turn.
+// Forces[0] is always the holder of the region, which may be neutral, and
+// allies arriving together come as a single force.
+type Battle struct {
+	Turn   int      `json:"turn"`
+	Region RegionID `json:"region"`
+	Forces []Force  `json:"forces"`
+}
+
+// BattleReport is what clients are shown and what gets stored with the match.
+// Resolvers that do not roll dice leave Seed, Modifiers and Rounds empty.
+type BattleReport struct {
+	Turn      int              `json:"turn"`
+	Region    RegionID         `json:"region"`
+	Seed      uint64           `json:"seed,string,omitempty"`
+	Forces    []Force          `json:"forces"`
+	Modifiers []Modifier       `json:"modifiers,omitempty"`
+	Rounds    []CombatRound    `json:"rounds,omitempty"`
+	Winner    PlayerID         `json:"winner"`
+	Survivors int              `json:"survivors"`
+	Losses    map[PlayerID]int `json:"losses"`
+}
+
+// BattleResolver decides who holds a region after a battle.
+type BattleResolver interface {
+	Fight(w *World, b Battle) BattleReport
+}
+
+// StrengthResolver is the simplest resolver: the largest force wins and
+// keeps the difference to the runner-up. A tie goes to the holder if they
+// are in it, otherwise everyone is wiped out and the holder keeps the region.
+type StrengthResolver struct{}
+
+func (StrengthResolver) Fight(w *World, b Battle) BattleReport {
+	out := newReport(b)
+
+	best, second := -1, -1
+	for i, f := range b.Forces {
+		switch {
+		case best < 0 || f.Units > b.Forces[best].Units:
+			best, second = i, best
+		case second < 0 || f.Units > b.Forces[second].Units:
+			second = i
+		}
+	}
+
+	top := b.Forces[best].Units
+	runnerUp := 0
+	if second >= 0 {
+		runnerUp = b.Forces[second].Units
+	}
+	// Ties keep the earliest force as best, so a tied holder is still best.
+	tied := top == runnerUp && best != 0
+
+	for i, f := range b.Forces {
+		if i == best && !tied {
+			out.Losses[f.Player] = runnerUp
+			continue
+		}
+		out.Losses[f.Player] = f.Units
+	}
+	if !tied {
+		out.Winner = b.Forces[best].Player
+		out.Survivors = top - runnerUp
+	}
+	return out
+}
+
+// Capture records a region changing hands.
+type Capture struct {
+	Region RegionID `json:"region"`
+	From   PlayerID `json:"from,omitempty"`
+	To     PlayerID `json:"to"`
+}
+
+type TurnResult struct {
+	Turn       int             `json:"turn"`
+	Executed   []Order         `json:"executed"`
+	Rejected   []RejectedOrder `json:"rejected,omitempty"`
+	Battles    []BattleReport  `json:"battles,omitempty"`
+	Captures   []Capture       `json:"captures,omitempty"`
+	Eliminated []PlayerID      `json:"eliminated,omitempty"`
+
+	Economy map[PlayerID]EconomyReport `json:"economy,omitempty"`
+}
+
+// Engine resolves turns. The zero value is not usable; call NewEngine.
+type Engine struct {
+	Battles BattleResolver
+}
+
+// NewEngine returns an engine that fights battles with dice seeded from seed.
+func NewEngine(seed uint64) *Engine {
+	return &Engine{Battles: DiceResolver{Seed: seed}}
+}
+
+// Resolve applies every order simultaneously and mutates w. Orders are sorted
+// before anything else happens, so the outcome never depends on the order in
+// which players submitted them. Builds and recruits are paid for first and
+// completed after the battles; income and upkeep close the turn.
+func (e *Engine) Resolve(w *World, turn int, orders []Order) TurnResult {
+	result := TurnResult{Turn: turn}
+
+	sorted := append([]Order(nil), orders...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.PlayerID != b.PlayerID {
+			return a.PlayerID < b.PlayerID
+		}
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		if a.Target != b.Target {
+			return a.Target < b.Target
+		}
+		return a.Action < b.Action
+	})
+
+	executed, rejected := ValidateOrders(w, sorted)
+	result.Executed = executed
+	result.Rejected = rejected
+
+	// Every departure happens before any arrival.
+	arrivals := make(map[RegionID][]Force)
+	for _, o := range executed {
+		if o.Action == ActionBuild || o.Action == ActionRecruit {
+			pay(w, o)
+			continue
+		}
+		w.Regions[o.From].Garrison -= o.Units
+		arrivals[o.Target] = append(arrivals[o.Target], Force{Player: o.PlayerID, Units: o.Units})
+	}
+
+	for _, id := range w.RegionIDs() {
+		incoming := arrivals[id]
+		if len(incoming) == 0 {
+			continue
+		}
+		region := w.Regions[id]
+
+		battle := Battle{Turn: turn, Region: id, Forces: []Force{{Player: region.Owner, Units: region.Garrison}}}
+		var attackers []Force
+		for _, f := range incoming {
+			if w.Allied(f.Player, region.Owner) {
+				battle.Forces[0].Units += f.Units
+				continue
+			}
+			attackers = mergeForce(attackers, f)
+		}
+		battle.Forces = append(battle.Forces, alliedStacks(w, attackers)...)
+		if len(battle.Forces) == 1 {
+			region.Garrison = battle.Forces[0].Units
+			continue
+		}
+
+		report := e.Battles.Fight(w, battle)
+		result.Battles = append(result.Battles, report)
+		if report.Winner != region.Owner {
+			result.Captures = append(result.Captures, Capture{Region: id, From: region.Owner, To: report.Winner})
+		}
+		region.Owner = report.Winner
+		region.Garrison = report.Survivors
+	}
+
+	completeConstruction(w, executed)
+
+	for _, id := range w.PlayerIDs() {
+		p := w.Players[id]
+		if !p.Eliminated && len(w.OwnedBy(id)) == 0 {
+			p.Eliminated = true
+			result.Eliminated = append(result.Eliminated, id)
+		}
+	}
+
+	result.Economy = collectIncome(w)
+	return result
+}
+
+func newReport(b Battle) BattleReport {
+	return BattleReport{
+		Turn:   b.Turn,
+		Region: b.Region,
+		Forces: append([]Force(nil), b.Forces...),
+		Winner: b.Forces[0].Player,
+		Losses: make(map[PlayerID]int),
+	}
+}
+
+// mergeForce adds f to the slice, folding it into an existing entry for the same player.
+func mergeForce(forces []Force, f Force) []Force {
+	for i := range forces {
+		if forces[i].Player == f.Player {
+			forces[i].Units += f.Units
+			return forces
+		}
+	}
+	return append(forces, f)
+}
+
+// alliedStacks combines the forces of allies into one, so that allies
+// entering the same region fight alongside each other rather than among
+// themselves. Each stack is led, and the region taken, by whichever ally
+// sent the most units, ties going to the lower player id.
+func alliedStacks(w *World, forces []Force) []Force {
+	var stacks []Force
+	var leads []Force
+next:
+	for _, f := range forces {
+		for i := range stacks {
+			if !w.Allied(stacks[i].Player, f.Player) {
+				continue
+			}
+			stacks[i].Units += f.Units
+			if f.Units > leads[i].Units || f.Units == leads[i].Units && f.Player < leads[i].Player {
+				leads[i] = f
+			}
+			continue next
+		}
+		stacks = append(stacks, f)
+		leads = append(leads, f)
+	}
+	for i := range stacks {
+		stacks[i].Player = leads[i].Player
+	}
+	return stacks
+}
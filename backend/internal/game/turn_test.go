@@ -0,0 +1,141 @@
+package game
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEngineResolve(t *testing.T) {
+	type holding struct {
+		Owner    PlayerID
+		Garrison int
+	}
+	for _, tc := range []struct {
+		name   string
+		setup  func(w *World)
+		orders []Order
+		want   map[RegionID]holding
+	}{
+		{
+			name: "departures happen before arrivals",
+			orders: []Order{
+				{PlayerID: "user-1", Action: ActionMove, From: "capital-1", Target: "plains-1", Units: 5},
+				{PlayerID: "user-1", Action: ActionAttack, From: "plains-1", Target: "river", Units: 2},
+			},
+			want: map[RegionID]holding{
+				"capital-1": {"user-1", 5},
+				"plains-1":  {"user-1", 6},
+				// A tie goes to the holder, who is left with nothing.
+				"river": {Neutral, 0},
+			},
+		},
+		{
+			name: "armies swapping regions pass each other",
+			setup: func(w *World) {
+				w.Regions["plains-1"].Garrison = 6
+				w.Regions["river"].Owner = "user-2"
+				w.Regions["river"].Garrison = 5
+			},
+			orders: []Order{
+				{PlayerID: "user-1", Action: ActionAttack, From: "plains-1", Target: "river", Units: 5},
+				{PlayerID: "user-2", Action: ActionAttack, From: "river", Target: "plains-1", Units: 4},
+			},
+			want: map[RegionID]holding{
+				"plains-1": {"user-2", 3},
+				"river":    {"user-1", 4},
+			},
+		},
+		{
+			name: "equal attackers bounce off a neutral region",
+			orders: []Order{
+				{PlayerID: "user-1", Action: ActionAttack, From: "plains-1", Target: "river", Units: 2},
+				{PlayerID: "user-2", Action: ActionAttack, From: "plains-2", Target: "river", Units: 2},
+			},
+			want: map[RegionID]holding{
+				"plains-1": {"user-1", 1},
+				"plains-2": {"user-2", 1},
+				"river":    {Neutral, 0},
+			},
+		},
+		{
+			name: "the larger of two attackers takes the region",
+			setup: func(w *World) {
+				w.Regions["plains-2"].Garrison = 8
+			},
+			orders: []Order{
+				{PlayerID: "user-1", Action: ActionAttack, From: "plains-1", Target: "river", Units: 2},
+				{PlayerID: "user-2", Action: ActionAttack, From: "plains-2", Target: "river", Units: 7},
+			},
+			want: map[RegionID]holding{
+				"river": {"user-2", 5},
+			},
+		},
+		{
+			name: "moves and attacks elsewhere do not interact",
+			orders: []Order{
+				{PlayerID: "user-1", Action: ActionMove, From: "capital-1", Target: "plains-1", Units: 4},
+				{PlayerID: "user-2", Action: ActionAttack, From: "capital-2", Target: "mountain", Units: 9},
+			},
+			want: map[RegionID]holding{
+				"plains-1": {"user-1", 7},
+				"mountain": {"user-2", 5},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			resolve := func(orders []Order) (*World, TurnResult) {
+				w := SampleWorld()
+				if tc.setup != nil {
+					tc.setup(w)
+				}
+				e := &Engine{Battles: StrengthResolver{}}
+				return w, e.Resolve(w, 1, orders)
+			}
+			w, result := resolve(tc.orders)
+			if len(result.Rejected) > 0 {
+				t.Fatalf("orders rejected: %+v", result.Rejected)
+			}
+			for id, want := range tc.want {
+				r := w.Regions[id]
+				if got := (holding{r.Owner, r.Garrison}); got != want {
+					t.Errorf("%s: %+v, want %+v", id, got, want)
+				}
+			}
+
+			// Submission order never matters.
+			reversed := make([]Order, len(tc.orders))
+			for i, o := range tc.orders {
+				reversed[len(tc.orders)-1-i] = o
+			}
+			other, otherResult := resolve(reversed)
+			if !reflect.DeepEqual(w, other) || !reflect.DeepEqual(result, otherResult) {
+				t.Error("resolving the orders in reverse gave a different outcome")
+			}
+		})
+	}
+}
+
+func TestEngineResolveEliminates(t *testing.T) {
+	w := SampleWorld()
+	w.Regions["plains-1"].Owner = Neutral
+	w.Regions["capital-1"].Garrison = 1
+	w.Regions["capital-2"].Garrison = 20
+	w.Regions["mountain"].Owner = "user-2"
+	w.Regions["mountain"].Garrison = 1
+	w.Regions["river"].Owner = "user-2"
+	w.Regions["river"].Garrison = 1
+	w.Regions["forest"].Owner = "user-2"
+	w.Regions["forest"].Garrison = 6
+
+	e := &Engine{Battles: StrengthResolver{}}
+	result := e.Resolve(w, 3, []Order{{PlayerID: "user-2", Action: ActionAttack, From: "forest", Target: "capital-1", Units: 5}})
+	if want := []PlayerID{"user-1"}; !reflect.DeepEqual(result.Eliminated, want) {
+		t.Fatalf("eliminated %v, want %v", result.Eliminated, want)
+	}
+	if want := []Capture{{Region: "capital-1", From: "user-1", To: "user-2"}}; !reflect.DeepEqual(result.Captures, want) {
+		t.Errorf("captures %+v, want %+v", result.Captures, want)
+	}
+	if _, ok := result.Economy["user-1"]; ok {
+		t.Error("an eliminated player was paid")
+	}
+}
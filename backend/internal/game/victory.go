@@ -0,0 +1,242 @@
+package game
+
+import "sort"
+
+type VictoryReason string
+
+const (
+	VictoryCapitalCapture VictoryReason = "capital_capture"
+	VictoryLastStanding   VictoryReason = "last_standing"
+	VictoryScore          VictoryReason = "score"
+	VictoryConcession     VictoryReason = "concession"
+	VictoryForfeit        VictoryReason = "forfeit"
+	VictoryDeclared       VictoryReason = "declared"
+)
+
+// Score weights used when the turn limit is reached.
+const (
+	ScorePerRegion  = 10
+	ScorePerCapital = 25
+	ScorePerUnit    = 1
+)
+
+// VictoryConditions says which ways a match can be won. Allies win together.
+type VictoryConditions struct {
+	// CapitalCapture ends the match once one side holds every capital.
+	CapitalCapture bool `json:"capitalCapture"`
+	// LastStanding ends the match once only one side has players left.
+	LastStanding bool `json:"lastStanding"`
+	// TurnLimit ends the match on score after that many turns; 0 disables it.
+	TurnLimit int `json:"turnLimit"`
+	// AllowConcession lets players resign with Concede.
+	AllowConcession bool `json:"allowConcession"`
+}
+
+func DefaultVictoryConditions() VictoryConditions {
+	return VictoryConditions{
+		CapitalCapture:  true,
+		LastStanding:    true,
+		TurnLimit:       30,
+		AllowConcession: true,
+	}
+}
+
+type Outcome struct {
+	Reason  VictoryReason    `json:"reason"`
+	Winners []PlayerID       `json:"winners"`
+	Turn    int              `json:"turn"`
+	Scores  map[PlayerID]int `json:"scores"`
+}
+
+// Check returns the outcome once any enabled condition is met, or nil while
+// the match should go on. It is meant to run after every resolved turn.
+func (v VictoryConditions) Check(w *World, turn int) *Outcome {
+	sides := Sides(w)
+
+	if v.CapitalCapture {
+		if side := capitalHolder(w); side != nil {
+			return newOutcome(w, VictoryCapitalCapture, side, turn)
+		}
+	}
+	if v.LastStanding && len(sides) == 1 {
+		return newOutcome(w, VictoryLastStanding, sides[0], turn)
+	}
+	if len(sides) == 0 {
+		return newOutcome(w, VictoryLastStanding, nil, turn)
+	}
+	if v.TurnLimit > 0 && turn >= v.TurnLimit {
+		return newOutcome(w, VictoryScore, topScoringSide(w, sides), turn)
+	}
+	return nil
+}
+
+// Concede eliminates p and hands their regions, garrisons included, to
+// neutral. If that leaves a single side standing it has won by concession.
+func (v VictoryConditions) Concede(w *World, p PlayerID, turn int) (*Outcome, bool) {
+	if !v.AllowConcession {
+		return nil, false
+	}
+	return withdraw(w, p, turn, VictoryConcession)
+}
+
+// Forfeit removes p the same way as Concede, but for players who are gone
+// rather than resigning, so it applies even when concession is disabled.
+func (v VictoryConditions) Forfeit(w *World, p PlayerID, turn int) (*Outcome, bool) {
+	return withdraw(w, p, turn, VictoryForfeit)
+}
+
+// Declare ends the game in favour of winners regardless of the board, as when
+// an operator stops a match. Scores are taken as the board stands.
+func Declare(w *World, winners []PlayerID, turn int) *Outcome {
+	sorted := append([]PlayerID(nil), winners...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return newOutcome(w, VictoryDeclared, sorted, turn)
+}
+
+func withdraw(w *World, p PlayerID, turn int, reason VictoryReason) (*Outcome, bool) {
+	player := w.Player(p)
+	if player == nil || player.Eliminated {
+		return nil, false
+	}
+	player.Eliminated = true
+	for _, r := range w.OwnedBy(p) {
+		r.Owner = Neutral
+	}
+	if sides := Sides(w); len(sides) == 1 {
+		return newOutcome(w, reason, sides[0], turn), true
+	}
+	return nil, true
+}
+
+// Sides groups the players still in the game into allied teams, ordered by
+// their first player's id.
+func Sides(w *World) [][]PlayerID {
+	var sides [][]PlayerID
+	for _, id := range w.PlayerIDs() {
+		if w.Players[id].Eliminated {
+			continue
+		}
+		placed := false
+		for i, side := range sides {
+			if w.Allied(side[0], id) {
+				sides[i] = append(side, id)
+				placed = true
+				break
+			}
+		}
+		if !placed {
+			sides = append(sides, []PlayerID{id})
+		}
+	}
+	return sides
+}
+
+func Score(w *World, p PlayerID) int {
+	score := 0
+	for _, r := range w.OwnedBy(p) {
+		score += ScorePerRegion + r.Garrison*ScorePerUnit
+		if r.Capital {
+			score += ScorePerCapital
+		}
+	}
+	return score
+}
+
+// capitalHolder returns the side holding every capital on the map, if any.
+func capitalHolder(w *World) []PlayerID {
+	var holder PlayerID
+	for _, id := range w.RegionIDs() {
+		r := w.Regions[id]
+		if !r.Capital {
+			continue
+		}
+		if r.Owner == Neutral {
+			return nil
+		}
+		if holder == Neutral {
+			holder = r.Owner
+		}
+		if !w.Allied(holder, r.Owner) {
+			return nil
+		}
+	}
+	if holder == Neutral {
+		return nil
+	}
+	for _, side := range Sides(w) {
+		if w.Allied(side[0], holder) {
+			return side
+		}
+	}
+	return nil
+}
+
+// topScoringSide picks the side with the best combined score. Ties share the win.
+func topScoringSide(w *World, sides [][]PlayerID) []PlayerID {
+	best := -1
+	var winners []PlayerID
+	for _, side := range sides {
+		total := 0
+		for _, p := range side {
+			total += Score(w, p)
+		}
+		switch {
+		case total > best:
+			best = total
+			winners = append([]PlayerID(nil), side...)
+		case total == best:
+			winners = append(winners, side...)
+		}
+	}
+	sort.Slice(winners, func(i, j int) bool { return winners[i] < winners[j] })
+	return winners
+}
+
+func newOutcome(w *World, reason VictoryReason, winners []PlayerID, turn int) *Outcome {
+	scores := make(map[PlayerID]int)
+	for _, id := range w.PlayerIDs() {
+		scores[id] = Score(w, id)
+	}
+	return &Outcome{Reason: reason, Winners: winners, Turn: turn, Scores: scores}
+}
+
+// Standings places every player once the match is over: the winners first,
+// then those still in the game by score, then the eliminated, last out
+// placed highest. eliminatedOn is the turn each player went out; players
+// missing from it went out before anyone else. Ties share a place.
+func Standings(w *World, o *Outcome, eliminatedOn map[PlayerID]int) map[PlayerID]int {
+	won := make(map[PlayerID]bool, len(o.Winners))
+	for _, id := range o.Winners {
+		won[id] = true
+	}
+	// rank orders players: lower group first, then higher value.
+	type rank struct{ group, value int }
+	ranks := make(map[PlayerID]rank)
+	for _, id := range w.PlayerIDs() {
+		switch p := w.Players[id]; {
+		case won[id]:
+			ranks[id] = rank{0, 0}
+		case !p.Eliminated:
+			ranks[id] = rank{1, o.Scores[id]}
+		default:
+			ranks[id] = rank{2, eliminatedOn[id]}
+		}
+	}
+	ids := w.PlayerIDs()
+	sort.SliceStable(ids, func(i, j int) bool {
+		a, b := ranks[ids[i]], ranks[ids[j]]
+		if a.group != b.group {
+			return a.group < b.group
+		}
+		return a.value > b.value
+	})
+	places := make(map[PlayerID]int, len(ids))
+	for i, id := range ids {
+		if i > 0 && ranks[id] == ranks[ids[i-1]] {
+			places[id] = places[ids[i-1]]
+		} else {
+			places[id] = i + 1
+		}
+	}
+	return places
+}
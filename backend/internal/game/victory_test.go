@@ -0,0 +1,183 @@
+package game
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestVictoryCheck(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		setup       func(w *World, v *VictoryConditions)
+		turn        int
+		wantReason  VictoryReason
+		wantWinners []PlayerID
+	}{
+		{
+			name: "game goes on",
+			turn: 5,
+		},
+		{
+			name: "capital capture",
+			setup: func(w *World, v *VictoryConditions) {
+				w.Regions["capital-2"].Owner = "user-1"
+			},
+			turn:        5,
+			wantReason:  VictoryCapitalCapture,
+			wantWinners: []PlayerID{"user-1"},
+		},
+		{
+			name: "allies holding every capital win together",
+			setup: func(w *World, v *VictoryConditions) {
+				w.Players["user-1"].Faction = "north"
+				w.Players["user-2"].Faction = "north"
+			},
+			turn:        5,
+			wantReason:  VictoryCapitalCapture,
+			wantWinners: []PlayerID{"user-1", "user-2"},
+		},
+		{
+			name: "capital capture disabled",
+			setup: func(w *World, v *VictoryConditions) {
+				v.CapitalCapture = false
+				w.Regions["capital-2"].Owner = "user-1"
+			},
+			turn: 5,
+		},
+		{
+			name: "last standing",
+			setup: func(w *World, v *VictoryConditions) {
+				w.Players["user-2"].Eliminated = true
+				w.Regions["capital-2"].Owner = Neutral
+				w.Regions["plains-2"].Owner = Neutral
+			},
+			turn:        5,
+			wantReason:  VictoryLastStanding,
+			wantWinners: []PlayerID{"user-1"},
+		},
+		{
+			name: "score at the turn limit",
+			setup: func(w *World, v *VictoryConditions) {
+				w.Regions["plains-2"].Garrison = 10
+			},
+			turn:        30,
+			wantReason:  VictoryScore,
+			wantWinners: []PlayerID{"user-2"},
+		},
+		{
+			name:        "tied score shares the win",
+			turn:        30,
+			wantReason:  VictoryScore,
+			wantWinners: []PlayerID{"user-1", "user-2"},
+		},
+		{
+			name: "no turn limit",
+			setup: func(w *World, v *VictoryConditions) {
+				v.TurnLimit = 0
+			},
+			turn: 300,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			w := SampleWorld()
+			v := DefaultVictoryConditions()
+			if tc.setup != nil {
+				tc.setup(w, &v)
+			}
+			got := v.Check(w, tc.turn)
+			if tc.wantReason == "" {
+				if got != nil {
+					t.Fatalf("match ended: %+v", got)
+				}
+				return
+			}
+			if got == nil {
+				t.Fatal("match did not end")
+			}
+			if got.Reason != tc.wantReason || !reflect.DeepEqual(got.Winners, tc.wantWinners) {
+				t.Errorf("%s won by %s, want %v by %s", got.Winners, got.Reason, tc.wantWinners, tc.wantReason)
+			}
+		})
+	}
+}
+
+func TestConcedeAndForfeit(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		concession  bool
+		forfeit     bool
+		players     int
+		wantOK      bool
+		wantReason  VictoryReason
+		wantWinners []PlayerID
+	}{
+		{"concession ends a two-player game", true, false, 2, true, VictoryConcession, []PlayerID{"user-1"}},
+		{"concession disabled", false, false, 2, false, "", nil},
+		{"forfeit applies without concession", false, true, 2, true, VictoryForfeit, []PlayerID{"user-1"}},
+		{"others play on", true, false, 3, true, "", nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			w := SampleWorld()
+			if tc.players == 3 {
+				_ = w.AddPlayer(Player{ID: "user-3"})
+				w.Regions["mountain"].Owner = "user-3"
+			}
+			v := DefaultVictoryConditions()
+			v.AllowConcession = tc.concession
+
+			withdraw := v.Concede
+			if tc.forfeit {
+				withdraw = v.Forfeit
+			}
+			outcome, ok := withdraw(w, "user-2", 7)
+			if ok != tc.wantOK {
+				t.Fatalf("ok = %v, want %v", ok, tc.wantOK)
+			}
+			if !ok {
+				if w.Players["user-2"].Eliminated {
+					t.Error("player eliminated although they could not concede")
+				}
+				return
+			}
+			if r := w.Regions["capital-2"]; !w.Players["user-2"].Eliminated || r.Owner != Neutral || r.Garrison != 10 {
+				t.Errorf("after withdrawing: eliminated %v, capital held by %q with %d", w.Players["user-2"].Eliminated, r.Owner, r.Garrison)
+			}
+			switch {
+			case tc.wantReason == "" && outcome != nil:
+				t.Errorf("match ended: %+v", outcome)
+			case tc.wantReason != "" && (outcome == nil || outcome.Reason != tc.wantReason || !reflect.DeepEqual(outcome.Winners, tc.wantWinners)):
+				t.Errorf("outcome %+v, want %v by %s", outcome, tc.wantWinners, tc.wantReason)
+			}
+			if _, again := withdraw(w, "user-2", 8); again {
+				t.Error("withdrew twice")
+			}
+		})
+	}
+}
+
+func TestStandings(t *testing.T) {
+	w := SampleWorld()
+	for _, id := range []PlayerID{"user-3", "user-4", "user-5", "user-6", "user-7"} {
+		_ = w.AddPlayer(Player{ID: id})
+	}
+	for _, id := range []PlayerID{"user-5", "user-6", "user-7"} {
+		w.Players[id].Eliminated = true
+	}
+	outcome := Declare(w, []PlayerID{"user-1"}, 9)
+
+	got := Standings(w, outcome, map[PlayerID]int{"user-5": 4, "user-6": 2})
+	want := map[PlayerID]int{
+		"user-1": 1,
+		// Still in, by score; the two with nothing share a place.
+		"user-2": 2,
+		"user-3": 3,
+		"user-4": 3,
+		// Out, last out first; user-7 has no turn so went out first.
+		"user-5": 5,
+		"user-6": 6,
+		"user-7": 7,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("standings %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,124 @@
+package game
+
+// Vision tuning.
+const (
+	// VisionRange is how many hops every held region sees.
+	VisionRange = 1
+	// ScoutGarrison units in one region push its vision to ScoutRange hops.
+	ScoutGarrison = 8
+	ScoutRange    = 2
+)
+
+// VisibleRegions is everything a player's side can currently see: each region
+// held by them or an ally plus its neighbourhood out to the vision range.
+func VisibleRegions(w *World, p PlayerID) map[RegionID]bool {
+	visible := make(map[RegionID]bool)
+	for _, id := range w.RegionIDs() {
+		r := w.Regions[id]
+		if !w.Allied(p, r.Owner) {
+			continue
+		}
+		reach := VisionRange
+		if r.Garrison >= ScoutGarrison {
+			reach = ScoutRange
+		}
+		for seen := range w.Within(id, reach) {
+			visible[seen] = true
+		}
+	}
+	return visible
+}
+
+// Within returns every region at most hops steps from start, start included.
+func (w *World) Within(start RegionID, hops int) map[RegionID]bool {
+	seen := map[RegionID]bool{start: true}
+	frontier := []RegionID{start}
+	for i := 0; i < hops && len(frontier) > 0; i++ {
+		var next []RegionID
+		for _, id := range frontier {
+			for _, n := range w.Regions[id].Neighbors {
+				if !seen[n] {
+					seen[n] = true
+					next = append(next, n)
+				}
+			}
+		}
+		frontier = next
+	}
+	return seen
+}
+
+// ViewFor returns the copy of w that player p is allowed to see. Hidden
+// regions keep their place in the graph but lose owner, garrison and
+// buildings, and only allies' treasuries are shown.
+func ViewFor(w *World, p PlayerID) *World {
+	view := w.Clone()
+	visible := VisibleRegions(w, p)
+	for id, r := range view.Regions {
+		if visible[id] {
+			continue
+		}
+		r.Hidden = true
+		r.Owner = Neutral
+		r.Garrison = 0
+		r.Buildings = nil
+	}
+	for id, other := range view.Players {
+		if !w.Allied(p, id) {
+			other.Treasury = Resources{}
+		}
+	}
+	return view
+}
+
+// ViewOfTurn trims a turn result to what p's side took part in or could see.
+// visible should come from the world after the turn was resolved. An enemy
+// order seen only where it arrived loses its source and unit count; what
+// arrived shows in the battle and the region itself.
+func ViewOfTurn(w *World, r TurnResult, p PlayerID, visible map[RegionID]bool) TurnResult {
+	view := TurnResult{Turn: r.Turn, Eliminated: r.Eliminated}
+
+	for _, o := range r.Executed {
+		switch {
+		case w.Allied(p, o.PlayerID):
+		case !visible[o.Target]:
+			continue
+		case !visible[o.From]:
+			o.From, o.Units = "", 0
+		}
+		view.Executed = append(view.Executed, o)
+	}
+	for _, o := range r.Rejected {
+		if o.Order.PlayerID == p {
+			view.Rejected = append(view.Rejected, o)
+		}
+	}
+	for _, b := range r.Battles {
+		if visible[b.Region] || foughtIn(b, p) {
+			view.Battles = append(view.Battles, b)
+		}
+	}
+	for _, c := range r.Captures {
+		if visible[c.Region] || c.From == p {
+			view.Captures = append(view.Captures, c)
+		}
+	}
+	for id, rep := range r.Economy {
+		if w.Allied(p, id) {
+			if view.Economy == nil {
+				view.Economy = make(map[PlayerID]EconomyReport)
+			}
+			view.Economy[id] = rep
+		}
+	}
+	return view
+}
+
+func foughtIn(b BattleReport, p PlayerID) bool {
+	for _, f := range b.Forces {
+		if f.Player == p {
+			return true
+		}
+	}
+	return false
+}
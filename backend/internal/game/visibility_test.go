@@ -0,0 +1,76 @@
+package game
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestViewFor(t *testing.T) {
+	w := SampleWorld()
+	w.Regions["capital-2"].Buildings = []Building{BuildingFort}
+	view := ViewFor(w, "user-1")
+
+	// The capital's large garrison scouts two hops out.
+	for id, hidden := range map[RegionID]bool{
+		"capital-1": false,
+		"plains-1":  false,
+		"forest":    false,
+		"river":     false,
+		"mountain":  true,
+		"plains-2":  true,
+		"capital-2": true,
+	} {
+		r := view.Regions[id]
+		if r.Hidden != hidden {
+			t.Errorf("%s: hidden = %v, want %v", id, r.Hidden, hidden)
+		}
+		if hidden && (r.Owner != Neutral || r.Garrison != 0 || r.Buildings != nil) {
+			t.Errorf("%s: hidden region shows %q, %d units, %v", id, r.Owner, r.Garrison, r.Buildings)
+		}
+	}
+	if view.Regions["capital-1"].Garrison != 10 {
+		t.Error("own garrison hidden")
+	}
+	if view.Players["user-2"].Treasury != (Resources{}) {
+		t.Error("enemy treasury shown")
+	}
+	if view.Players["user-1"].Treasury != w.Players["user-1"].Treasury {
+		t.Error("own treasury hidden")
+	}
+	if w.Regions["capital-2"].Hidden || w.Regions["capital-2"].Garrison != 10 {
+		t.Error("ViewFor changed the world")
+	}
+}
+
+func TestViewOfTurn(t *testing.T) {
+	w := SampleWorld()
+	own := Order{PlayerID: "user-1", Action: ActionMove, From: "capital-1", Target: "plains-1", Units: 4}
+	seen := Order{PlayerID: "user-2", Action: ActionAttack, From: "river", Target: "forest", Units: 2}
+	arrived := Order{PlayerID: "user-2", Action: ActionAttack, From: "plains-2", Target: "river", Units: 3}
+	unseen := Order{PlayerID: "user-2", Action: ActionAttack, From: "capital-2", Target: "mountain", Units: 9}
+	result := TurnResult{
+		Turn:     4,
+		Executed: []Order{own, seen, arrived, unseen},
+		Rejected: []RejectedOrder{{Order: unseen}},
+		Captures: []Capture{
+			{Region: "river", From: Neutral, To: "user-2"},
+			{Region: "mountain", From: Neutral, To: "user-2"},
+		},
+		Economy: map[PlayerID]EconomyReport{"user-1": {}, "user-2": {}},
+	}
+
+	view := ViewOfTurn(w, result, "user-1", VisibleRegions(w, "user-1"))
+	want := []Order{own, seen, {PlayerID: "user-2", Action: ActionAttack, Target: "river"}}
+	if !reflect.DeepEqual(view.Executed, want) {
+		t.Errorf("executed %+v, want %+v", view.Executed, want)
+	}
+	if len(view.Rejected) != 0 {
+		t.Errorf("another player's rejections shown: %+v", view.Rejected)
+	}
+	if len(view.Captures) != 1 || view.Captures[0].Region != "river" {
+		t.Errorf("captures %+v, want only river", view.Captures)
+	}
+	if _, ok := view.Economy["user-2"]; ok || len(view.Economy) != 1 {
+		t.Errorf("economy %+v, want only user-1", view.Economy)
+	}
+}
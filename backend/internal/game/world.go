@@ -0,0 +1,276 @@
+// Package game holds Terrabound's deterministic rules. It has no Nakama
+// dependencies so that match handlers and RPCs can share it and it can be
+// exercised with plain `go test`.
+package game
+
+import (
+	"fmt"
+	"sort"
+)
+
+type RegionID string
+type PlayerID string
+type FactionID string
+
+// Neutral is the owner of regions nobody controls.
+const Neutral PlayerID = ""
+
+type Region struct {
+	ID        RegionID   `json:"id"`
+	Name      string     `json:"name"`
+	Neighbors []RegionID `json:"neighbors"`
+	Owner     PlayerID   `json:"owner,omitempty"`
+	Garrison  int        `json:"garrison"`
+	Capital   bool       `json:"capital,omitempty"`
+	Yield     Resources  `json:"yield"`
+	Buildings []Building `json:"buildings,omitempty"`
+	// Hidden marks a region outside the viewer's vision in a ViewFor copy.
+	Hidden bool `json:"hidden,omitempty"`
+}
+
+type Player struct {
+	ID         PlayerID  `json:"id"`
+	Name       string    `json:"name"`
+	Faction    FactionID `json:"faction,omitempty"`
+	Eliminated bool      `json:"eliminated,omitempty"`
+	Treasury   Resources `json:"treasury"`
+	// Bot marks a seat a bot was given from the start. A player a bot
+	// took over for is still a player.
+	Bot bool `json:"bot,omitempty"`
+}
+
+type Faction struct {
+	ID   FactionID `json:"id"`
+	Name string    `json:"name"`
+}
+
+// World is the territory graph plus everyone playing on it.
+type World struct {
+	Regions  map[RegionID]*Region   `json:"regions"`
+	Players  map[PlayerID]*Player   `json:"players"`
+	Factions map[FactionID]*Faction `json:"factions"`
+	// Spawns lists the regions new players are seated on, in seating order.
+	Spawns []RegionID `json:"spawns,omitempty"`
+}
+
+func NewWorld() *World {
+	return &World{
+		Regions:  make(map[RegionID]*Region),
+		Players:  make(map[PlayerID]*Player),
+		Factions: make(map[FactionID]*Faction),
+	}
+}
+
+// AddRegion inserts a region without any neighbours; use Connect to link it.
+func (w *World) AddRegion(r Region) error {
+	if _, ok := w.Regions[r.ID]; ok {
+		return fmt.Errorf("%w: %s", ErrDuplicateRegion, r.ID)
+	}
+	r.Neighbors = nil
+	w.Regions[r.ID] = &r
+	return nil
+}
+
+// Connect links two regions in both directions. Connecting twice is a no-op.
+func (w *World) Connect(a, b RegionID) error {
+	if a == b {
+		return fmt.Errorf("%w: %s", ErrSelfAdjacency, a)
+	}
+	ra, ok := w.Regions[a]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownRegion, a)
+	}
+	rb, ok := w.Regions[b]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownRegion, b)
+	}
+	if w.Adjacent(a, b) {
+		return nil
+	}
+	ra.Neighbors = append(ra.Neighbors, b)
+	rb.Neighbors = append(rb.Neighbors, a)
+	return nil
+}
+
+func (w *World) AddPlayer(p Player) error {
+	if _, ok := w.Players[p.ID]; ok {
+		return fmt.Errorf("%w: %s", ErrDuplicatePlayer, p.ID)
+	}
+	if p.Faction != "" {
+		if _, ok := w.Factions[p.Faction]; !ok {
+			return fmt.Errorf("%w: %s", ErrUnknownFaction, p.Faction)
+		}
+	}
+	w.Players[p.ID] = &p
+	return nil
+}
+
+func (w *World) AddFaction(f Faction) error {
+	if _, ok := w.Factions[f.ID]; ok {
+		return fmt.Errorf("%w: %s", ErrDuplicateFaction, f.ID)
+	}
+	w.Factions[f.ID] = &f
+	return nil
+}
+
+// Seat adds a player and hands them the first spawn nobody holds yet, along
+// with the starting treasury.
+func (w *World) Seat(p Player) (RegionID, error) {
+	var spawn RegionID
+	for _, id := range w.Spawns {
+		if r := w.Regions[id]; r != nil && r.Owner == Neutral {
+			spawn = id
+			break
+		}
+	}
+	if spawn == "" {
+		return "", ErrNoFreeSpawn
+	}
+	p.Treasury = StartingTreasury
+	if err := w.AddPlayer(p); err != nil {
+		return "", err
+	}
+	w.Regions[spawn].Owner = p.ID
+	return spawn, nil
+}
+
+// ReleaseSpawns strips the capital status from spawns nobody took, so a map
+// built for more players than joined does not leave neutral capitals behind.
+func (w *World) ReleaseSpawns() {
+	var taken []RegionID
+	for _, id := range w.Spawns {
+		r := w.Regions[id]
+		if r == nil {
+			continue
+		}
+		if r.Owner == Neutral {
+			r.Capital = false
+			continue
+		}
+		taken = append(taken, id)
+	}
+	w.Spawns = taken
+}
+
+// Unseat removes a player and returns everything they held to neutral.
+func (w *World) Unseat(p PlayerID) {
+	for _, r := range w.Regions {
+		if r.Owner == p {
+			r.Owner = Neutral
+		}
+	}
+	delete(w.Players, p)
+}
+
+// Region returns nil when the id is unknown.
+func (w *World) Region(id RegionID) *Region {
+	return w.Regions[id]
+}
+
+// Player returns nil when the id is unknown.
+func (w *World) Player(id PlayerID) *Player {
+	return w.Players[id]
+}
+
+func (w *World) Adjacent(a, b RegionID) bool {
+	r, ok := w.Regions[a]
+	if !ok {
+		return false
+	}
+	for _, n := range r.Neighbors {
+		if n == b {
+			return true
+		}
+	}
+	return false
+}
+
+// Allied reports whether two players share a side. A player is always allied
+// with themselves; neutral is allied with nobody.
+func (w *World) Allied(a, b PlayerID) bool {
+	if a == Neutral || b == Neutral {
+		return false
+	}
+	if a == b {
+		return true
+	}
+	pa, pb := w.Players[a], w.Players[b]
+	if pa == nil || pb == nil {
+		return false
+	}
+	return pa.Faction != "" && pa.Faction == pb.Faction
+}
+
+// RegionIDs returns every region id in a stable order. Callers that need
+// deterministic results must iterate through this rather than the map.
+func (w *World) RegionIDs() []RegionID {
+	ids := make([]RegionID, 0, len(w.Regions))
+	for id := range w.Regions {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// PlayerIDs returns every player id in a stable order.
+func (w *World) PlayerIDs() []PlayerID {
+	ids := make([]PlayerID, 0, len(w.Players))
+	for id := range w.Players {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// OwnedBy lists the regions held by a player, ordered by id.
+func (w *World) OwnedBy(p PlayerID) []*Region {
+	var out []*Region
+	for _, id := range w.RegionIDs() {
+		if r := w.Regions[id]; r.Owner == p {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// CapitalOf returns the capital a player currently holds, or nil.
+func (w *World) CapitalOf(p PlayerID) *Region {
+	for _, r := range w.OwnedBy(p) {
+		if r.Capital {
+			return r
+		}
+	}
+	return nil
+}
+
+// Units totals a player's garrisons across the map.
+func (w *World) Units(p PlayerID) int {
+	total := 0
+	for _, r := range w.Regions {
+		if r.Owner == p {
+			total += r.Garrison
+		}
+	}
+	return total
+}
+
+// Clone returns a deep copy so resolution can work on a scratch world.
+func (w *World) Clone() *World {
+	c := NewWorld()
+	for id, r := range w.Regions {
+		cr := *r
+		cr.Neighbors = append([]RegionID(nil), r.Neighbors...)
+		cr.Buildings = append([]Building(nil), r.Buildings...)
+		c.Regions[id] = &cr
+	}
+	for id, p := range w.Players {
+		cp := *p
+		c.Players[id] = &cp
+	}
+	for id, f := range w.Factions {
+		cf := *f
+		c.Factions[id] = &cf
+	}
+	c.Spawns = append([]RegionID(nil), w.Spawns...)
+	return c
+}
@@ -0,0 +1,4 @@
+// Package matchpb holds the protobuf types for match messages.
+package matchpb
+
+//go:generate protoc -I ../.. --go_out=../.. --go_opt=paths=source_relative internal/matchpb/match.proto
@@ -0,0 +1,810 @@
+// Wire format for match messages sent with the protobuf encoding. Opcodes
+// are the same as for JSON; see internal/nakama/opcodes.go.
+//
+// Regenerate match.pb.go with `go generate ./internal/matchpb` after editing.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.8
+// 	protoc        (unknown)
+// source: internal/matchpb/match.proto
+
+package matchpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Encoding is how a client wants its match messages encoded.
+type Encoding int32
+
+const (
+	Encoding_ENCODING_UNSPECIFIED Encoding = 0
+	Encoding_ENCODING_JSON        Encoding = 1
+	Encoding_ENCODING_PROTOBUF    Encoding = 2
+)
+
+// Enum value maps for Encoding.
+var (
+	Encoding_name = map[int32]string{
+		0: "ENCODING_UNSPECIFIED",
+		1: "ENCODING_JSON",
+		2: "ENCODING_PROTOBUF",
+	}
+	Encoding_value = map[string]int32{
+		"ENCODING_UNSPECIFIED": 0,
+		"ENCODING_JSON":        1,
+		"ENCODING_PROTOBUF":    2,
+	}
+)
+
+func (x Encoding) Enum() *Encoding {
+	p := new(Encoding)
+	*p = x
+	return p
+}
+
+func (x Encoding) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Encoding) Descriptor() protoreflect.EnumDescriptor {
+	return file_internal_matchpb_match_proto_enumTypes[0].Descriptor()
+}
+
+func (Encoding) Type() protoreflect.EnumType {
+	return &file_internal_matchpb_match_proto_enumTypes[0]
+}
+
+func (x Encoding) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Encoding.Descriptor instead.
+func (Encoding) EnumDescriptor() ([]byte, []int) {
+	return file_internal_matchpb_match_proto_rawDescGZIP(), []int{0}
+}
+
+// Welcome is sent to a player when they join, confirming the protocol
+// version and encoding the server settled on from their join metadata.
+type Welcome struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	ProtocolVersion uint32                 `protobuf:"varint,1,opt,name=protocol_version,json=protocolVersion,proto3" json:"protocol_version,omitempty"`
+	Encoding        Encoding               `protobuf:"varint,2,opt,name=encoding,proto3,enum=terrabound.match.v1.Encoding" json:"encoding,omitempty"`
+	Tick            int64                  `protobuf:"varint,3,opt,name=tick,proto3" json:"tick,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *Welcome) Reset() {
+	*x = Welcome{}
+	mi := &file_internal_matchpb_match_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Welcome) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Welcome) ProtoMessage() {}
+
+func (x *Welcome) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_matchpb_match_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Welcome.ProtoReflect.Descriptor instead.
+func (*Welcome) Descriptor() ([]byte, []int) {
+	return file_internal_matchpb_match_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Welcome) GetProtocolVersion() uint32 {
+	if x != nil {
+		return x.ProtocolVersion
+	}
+	return 0
+}
+
+func (x *Welcome) GetEncoding() Encoding {
+	if x != nil {
+		return x.Encoding
+	}
+	return Encoding_ENCODING_UNSPECIFIED
+}
+
+func (x *Welcome) GetTick() int64 {
+	if x != nil {
+		return x.Tick
+	}
+	return 0
+}
+
+// Error tells a client a message it sent was not understood.
+type Error struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Code          string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`
+	OpCode        int64                  `protobuf:"varint,2,opt,name=op_code,json=opCode,proto3" json:"op_code,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Error) Reset() {
+	*x = Error{}
+	mi := &file_internal_matchpb_match_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Error) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Error) ProtoMessage() {}
+
+func (x *Error) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_matchpb_match_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Error.ProtoReflect.Descriptor instead.
+func (*Error) Descriptor() ([]byte, []int) {
+	return file_internal_matchpb_match_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Error) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *Error) GetOpCode() int64 {
+	if x != nil {
+		return x.OpCode
+	}
+	return 0
+}
+
+type Vec2 struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	X             float32                `protobuf:"fixed32,1,opt,name=x,proto3" json:"x,omitempty"`
+	Y             float32                `protobuf:"fixed32,2,opt,name=y,proto3" json:"y,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Vec2) Reset() {
+	*x = Vec2{}
+	mi := &file_internal_matchpb_match_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Vec2) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Vec2) ProtoMessage() {}
+
+func (x *Vec2) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_matchpb_match_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Vec2.ProtoReflect.Descriptor instead.
+func (*Vec2) Descriptor() ([]byte, []int) {
+	return file_internal_matchpb_match_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Vec2) GetX() float32 {
+	if x != nil {
+		return x.X
+	}
+	return 0
+}
+
+func (x *Vec2) GetY() float32 {
+	if x != nil {
+		return x.Y
+	}
+	return 0
+}
+
+// MoveIntent is sent on player_move.
+type MoveIntent struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Seq       uint32                 `protobuf:"varint,1,opt,name=seq,proto3" json:"seq,omitempty"`
+	Direction *Vec2                  `protobuf:"bytes,2,opt,name=direction,proto3" json:"direction,omitempty"`
+	// Only set by clients that still report absolute positions.
+	Position      *Vec2 `protobuf:"bytes,3,opt,name=position,proto3" json:"position,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MoveIntent) Reset() {
+	*x = MoveIntent{}
+	mi := &file_internal_matchpb_match_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MoveIntent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MoveIntent) ProtoMessage() {}
+
+func (x *MoveIntent) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_matchpb_match_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MoveIntent.ProtoReflect.Descriptor instead.
+func (*MoveIntent) Descriptor() ([]byte, []int) {
+	return file_internal_matchpb_match_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *MoveIntent) GetSeq() uint32 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *MoveIntent) GetDirection() *Vec2 {
+	if x != nil {
+		return x.Direction
+	}
+	return nil
+}
+
+func (x *MoveIntent) GetPosition() *Vec2 {
+	if x != nil {
+		return x.Position
+	}
+	return nil
+}
+
+type PlayerState struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	X             float32                `protobuf:"fixed32,2,opt,name=x,proto3" json:"x,omitempty"`
+	Y             float32                `protobuf:"fixed32,3,opt,name=y,proto3" json:"y,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PlayerState) Reset() {
+	*x = PlayerState{}
+	mi := &file_internal_matchpb_match_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PlayerState) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PlayerState) ProtoMessage() {}
+
+func (x *PlayerState) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_matchpb_match_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PlayerState.ProtoReflect.Descriptor instead.
+func (*PlayerState) Descriptor() ([]byte, []int) {
+	return file_internal_matchpb_match_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *PlayerState) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *PlayerState) GetX() float32 {
+	if x != nil {
+		return x.X
+	}
+	return 0
+}
+
+func (x *PlayerState) GetY() float32 {
+	if x != nil {
+		return x.Y
+	}
+	return 0
+}
+
+// GameStateSync is a full snapshot, sent on game_state_sync.
+type GameStateSync struct {
+	state         protoimpl.MessageState  `protogen:"open.v1"`
+	Players       map[string]*PlayerState `protobuf:"bytes,1,rep,name=players,proto3" json:"players,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GameStateSync) Reset() {
+	*x = GameStateSync{}
+	mi := &file_internal_matchpb_match_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GameStateSync) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GameStateSync) ProtoMessage() {}
+
+func (x *GameStateSync) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_matchpb_match_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GameStateSync.ProtoReflect.Descriptor instead.
+func (*GameStateSync) Descriptor() ([]byte, []int) {
+	return file_internal_matchpb_match_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *GameStateSync) GetPlayers() map[string]*PlayerState {
+	if x != nil {
+		return x.Players
+	}
+	return nil
+}
+
+// StateAck is sent on state_ack. Tick 0 or resync asks for a full snapshot.
+type StateAck struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Tick          int64                  `protobuf:"varint,1,opt,name=tick,proto3" json:"tick,omitempty"`
+	Resync        bool                   `protobuf:"varint,2,opt,name=resync,proto3" json:"resync,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StateAck) Reset() {
+	*x = StateAck{}
+	mi := &file_internal_matchpb_match_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StateAck) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StateAck) ProtoMessage() {}
+
+func (x *StateAck) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_matchpb_match_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StateAck.ProtoReflect.Descriptor instead.
+func (*StateAck) Descriptor() ([]byte, []int) {
+	return file_internal_matchpb_match_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *StateAck) GetTick() int64 {
+	if x != nil {
+		return x.Tick
+	}
+	return 0
+}
+
+func (x *StateAck) GetResync() bool {
+	if x != nil {
+		return x.Resync
+	}
+	return false
+}
+
+// StateDelta is sent on state_delta. Each changed entity is itself an encoded
+// message; for the movement match that is a PlayerState.
+type StateDelta struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Tick          int64                  `protobuf:"varint,1,opt,name=tick,proto3" json:"tick,omitempty"`
+	BaseTick      int64                  `protobuf:"varint,2,opt,name=base_tick,json=baseTick,proto3" json:"base_tick,omitempty"`
+	Full          bool                   `protobuf:"varint,3,opt,name=full,proto3" json:"full,omitempty"`
+	Changed       map[string][]byte      `protobuf:"bytes,4,rep,name=changed,proto3" json:"changed,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Removed       []string               `protobuf:"bytes,5,rep,name=removed,proto3" json:"removed,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StateDelta) Reset() {
+	*x = StateDelta{}
+	mi := &file_internal_matchpb_match_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StateDelta) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StateDelta) ProtoMessage() {}
+
+func (x *StateDelta) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_matchpb_match_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StateDelta.ProtoReflect.Descriptor instead.
+func (*StateDelta) Descriptor() ([]byte, []int) {
+	return file_internal_matchpb_match_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *StateDelta) GetTick() int64 {
+	if x != nil {
+		return x.Tick
+	}
+	return 0
+}
+
+func (x *StateDelta) GetBaseTick() int64 {
+	if x != nil {
+		return x.BaseTick
+	}
+	return 0
+}
+
+func (x *StateDelta) GetFull() bool {
+	if x != nil {
+		return x.Full
+	}
+	return false
+}
+
+func (x *StateDelta) GetChanged() map[string][]byte {
+	if x != nil {
+		return x.Changed
+	}
+	return nil
+}
+
+func (x *StateDelta) GetRemoved() []string {
+	if x != nil {
+		return x.Removed
+	}
+	return nil
+}
+
+// Shutdown warns that the match is stopping in grace_seconds, or already
+// has when that is 0.
+type Shutdown struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Reason        string                 `protobuf:"bytes,1,opt,name=reason,proto3" json:"reason,omitempty"`
+	GraceSeconds  int64                  `protobuf:"varint,2,opt,name=grace_seconds,json=graceSeconds,proto3" json:"grace_seconds,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Shutdown) Reset() {
+	*x = Shutdown{}
+	mi := &file_internal_matchpb_match_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Shutdown) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Shutdown) ProtoMessage() {}
+
+func (x *Shutdown) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_matchpb_match_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Shutdown.ProtoReflect.Descriptor instead.
+func (*Shutdown) Descriptor() ([]byte, []int) {
+	return file_internal_matchpb_match_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Shutdown) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *Shutdown) GetGraceSeconds() int64 {
+	if x != nil {
+		return x.GraceSeconds
+	}
+	return 0
+}
+
+// ServerMessage is an announcement from the operators, sent on server_message.
+type ServerMessage struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Text          string                 `protobuf:"bytes,1,opt,name=text,proto3" json:"text,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ServerMessage) Reset() {
+	*x = ServerMessage{}
+	mi := &file_internal_matchpb_match_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ServerMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServerMessage) ProtoMessage() {}
+
+func (x *ServerMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_matchpb_match_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServerMessage.ProtoReflect.Descriptor instead.
+func (*ServerMessage) Descriptor() ([]byte, []int) {
+	return file_internal_matchpb_match_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ServerMessage) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+// MatchPaused is sent on match_paused whenever an operator pauses or resumes
+// the match. Inputs sent while paused are dropped.
+type MatchPaused struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Paused        bool                   `protobuf:"varint,1,opt,name=paused,proto3" json:"paused,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MatchPaused) Reset() {
+	*x = MatchPaused{}
+	mi := &file_internal_matchpb_match_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MatchPaused) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MatchPaused) ProtoMessage() {}
+
+func (x *MatchPaused) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_matchpb_match_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MatchPaused.ProtoReflect.Descriptor instead.
+func (*MatchPaused) Descriptor() ([]byte, []int) {
+	return file_internal_matchpb_match_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *MatchPaused) GetPaused() bool {
+	if x != nil {
+		return x.Paused
+	}
+	return false
+}
+
+var File_internal_matchpb_match_proto protoreflect.FileDescriptor
+
+const file_internal_matchpb_match_proto_rawDesc = "" +
+	"\n" +
+	"\x1cinternal/matchpb/match.proto\x12\x13terrabound.match.v1\"\x83\x01\n" +
+	"\aWelcome\x12)\n" +
+	"\x10protocol_version\x18\x01 \x01(\rR\x0fprotocolVersion\x129\n" +
+	"\bencoding\x18\x02 \x01(\x0e2\x1d.terrabound.match.v1.EncodingR\bencoding\x12\x12\n" +
+	"\x04tick\x18\x03 \x01(\x03R\x04tick\"4\n" +
+	"\x05Error\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\x12\x17\n" +
+	"\aop_code\x18\x02 \x01(\x03R\x06opCode\"\"\n" +
+	"\x04Vec2\x12\f\n" +
+	"\x01x\x18\x01 \x01(\x02R\x01x\x12\f\n" +
+	"\x01y\x18\x02 \x01(\x02R\x01y\"\x8e\x01\n" +
+	"\n" +
+	"MoveIntent\x12\x10\n" +
+	"\x03seq\x18\x01 \x01(\rR\x03seq\x127\n" +
+	"\tdirection\x18\x02 \x01(\v2\x19.terrabound.match.v1.Vec2R\tdirection\x125\n" +
+	"\bposition\x18\x03 \x01(\v2\x19.terrabound.match.v1.Vec2R\bposition\"B\n" +
+	"\vPlayerState\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\f\n" +
+	"\x01x\x18\x02 \x01(\x02R\x01x\x12\f\n" +
+	"\x01y\x18\x03 \x01(\x02R\x01y\"\xb8\x01\n" +
+	"\rGameStateSync\x12I\n" +
+	"\aplayers\x18\x01 \x03(\v2/.terrabound.match.v1.GameStateSync.PlayersEntryR\aplayers\x1a\\\n" +
+	"\fPlayersEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x126\n" +
+	"\x05value\x18\x02 \x01(\v2 .terrabound.match.v1.PlayerStateR\x05value:\x028\x01\"6\n" +
+	"\bStateAck\x12\x12\n" +
+	"\x04tick\x18\x01 \x01(\x03R\x04tick\x12\x16\n" +
+	"\x06resync\x18\x02 \x01(\bR\x06resync\"\xef\x01\n" +
+	"\n" +
+	"StateDelta\x12\x12\n" +
+	"\x04tick\x18\x01 \x01(\x03R\x04tick\x12\x1b\n" +
+	"\tbase_tick\x18\x02 \x01(\x03R\bbaseTick\x12\x12\n" +
+	"\x04full\x18\x03 \x01(\bR\x04full\x12F\n" +
+	"\achanged\x18\x04 \x03(\v2,.terrabound.match.v1.StateDelta.ChangedEntryR\achanged\x12\x18\n" +
+	"\aremoved\x18\x05 \x03(\tR\aremoved\x1a:\n" +
+	"\fChangedEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\fR\x05value:\x028\x01\"G\n" +
+	"\bShutdown\x12\x16\n" +
+	"\x06reason\x18\x01 \x01(\tR\x06reason\x12#\n" +
+	"\rgrace_seconds\x18\x02 \x01(\x03R\fgraceSeconds\"#\n" +
+	"\rServerMessage\x12\x12\n" +
+	"\x04text\x18\x01 \x01(\tR\x04text\"%\n" +
+	"\vMatchPaused\x12\x16\n" +
+	"\x06paused\x18\x01 \x01(\bR\x06paused*N\n" +
+	"\bEncoding\x12\x18\n" +
+	"\x14ENCODING_UNSPECIFIED\x10\x00\x12\x11\n" +
+	"\rENCODING_JSON\x10\x01\x12\x15\n" +
+	"\x11ENCODING_PROTOBUF\x10\x02B6Z4github.com/delta/terrabound/backend/internal/matchpbb\x06proto3"
+
+var (
+	file_internal_matchpb_match_proto_rawDescOnce sync.Once
+	file_internal_matchpb_match_proto_rawDescData []byte
+)
+
+func file_internal_matchpb_match_proto_rawDescGZIP() []byte {
+	file_internal_matchpb_match_proto_rawDescOnce.Do(func() {
+		file_internal_matchpb_match_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_internal_matchpb_match_proto_rawDesc), len(file_internal_matchpb_match_proto_rawDesc)))
+	})
+	return file_internal_matchpb_match_proto_rawDescData
+}
+
+var file_internal_matchpb_match_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_internal_matchpb_match_proto_msgTypes = make([]protoimpl.MessageInfo, 13)
+var file_internal_matchpb_match_proto_goTypes = []any{
+	(Encoding)(0),         // 0: terrabound.match.v1.Encoding
+	(*Welcome)(nil),       // 1: terrabound.match.v1.Welcome
+	(*Error)(nil),         // 2: terrabound.match.v1.Error
+	(*Vec2)(nil),          // 3: terrabound.match.v1.Vec2
+	(*MoveIntent)(nil),    // 4: terrabound.match.v1.MoveIntent
+	(*PlayerState)(nil),   // 5: terrabound.match.v1.PlayerState
+	(*GameStateSync)(nil), // 6: terrabound.match.v1.GameStateSync
+	(*StateAck)(nil),      // 7: terrabound.match.v1.StateAck
+	(*StateDelta)(nil),    // 8: terrabound.match.v1.StateDelta
+	(*Shutdown)(nil),      // 9: terrabound.match.v1.Shutdown
+	(*ServerMessage)(nil), // 10: terrabound.match.v1.ServerMessage
+	(*MatchPaused)(nil),   // 11: terrabound.match.v1.MatchPaused
+	nil,                   // 12: terrabound.match.v1.GameStateSync.PlayersEntry
+	nil,                   // 13: terrabound.match.v1.StateDelta.ChangedEntry
+}
+var file_internal_matchpb_match_proto_depIdxs = []int32{
+	0,  // 0: terrabound.match.v1.Welcome.encoding:type_name -> terrabound.match.v1.Encoding
+	3,  // 1: terrabound.match.v1.MoveIntent.direction:type_name -> terrabound.match.v1.Vec2
+	3,  // 2: terrabound.match.v1.MoveIntent.position:type_name -> terrabound.match.v1.Vec2
+	12, // 3: terrabound.match.v1.GameStateSync.players:type_name -> terrabound.match.v1.GameStateSync.PlayersEntry
+	13, // 4: terrabound.match.v1.StateDelta.changed:type_name -> terrabound.match.v1.StateDelta.ChangedEntry
+	5,  // 5: terrabound.match.v1.GameStateSync.PlayersEntry.value:type_name -> terrabound.match.v1.PlayerState
+	6,  // [6:6] is the sub-list for method output_type
+	6,  // [6:6] is the sub-list for method input_type
+	6,  // [6:6] is the sub-list for extension type_name
+	6,  // [6:6] is the sub-list for extension extendee
+	0,  // [0:6] is the sub-list for field type_name
+}
+
+func init() { file_internal_matchpb_match_proto_init() }
+func file_internal_matchpb_match_proto_init() {
+	if File_internal_matchpb_match_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_internal_matchpb_match_proto_rawDesc), len(file_internal_matchpb_match_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   13,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_internal_matchpb_match_proto_goTypes,
+		DependencyIndexes: file_internal_matchpb_match_proto_depIdxs,
+		EnumInfos:         file_internal_matchpb_match_proto_enumTypes,
+		MessageInfos:      file_internal_matchpb_match_proto_msgTypes,
+	}.Build()
+	File_internal_matchpb_match_proto = out.File
+	file_internal_matchpb_match_proto_goTypes = nil
+	file_internal_matchpb_match_proto_depIdxs = nil
+}
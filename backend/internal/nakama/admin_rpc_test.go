@@ -0,0 +1,104 @@
+package nakama
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+func TestAuthorizeAdminNeedsKeyWithoutSession(t *testing.T) {
+	nk := &fakeNakama{}
+	withKey := context.WithValue(context.Background(), runtime.RUNTIME_CTX_ENV, map[string]string{envAdminKey: "secret"})
+	for _, tc := range []struct {
+		name string
+		ctx  context.Context
+		key  string
+		ok   bool
+	}{
+		{"http_key alone", context.Background(), "", false},
+		{"no key configured", context.Background(), "secret", false},
+		{"wrong key", withKey, "guess", false},
+		{"missing key", withKey, "", false},
+		{"admin key", withKey, "secret", true},
+	} {
+		admin, err := authorizeAdmin(tc.ctx, nk, tc.key)
+		if ok := err == nil && admin == "server"; ok != tc.ok {
+			t.Errorf("%s: authorized %v (%q, %v), want %v", tc.name, ok, admin, err, tc.ok)
+		}
+	}
+}
+
+// fakeMessage is a match message from a player.
+type fakeMessage struct {
+	fakePresence
+	opCode int64
+	data   []byte
+}
+
+func (m fakeMessage) GetOpCode() int64      { return m.opCode }
+func (m fakeMessage) GetData() []byte       { return m.data }
+func (m fakeMessage) GetReliable() bool     { return true }
+func (m fakeMessage) GetReceiveTime() int64 { return 0 }
+
+func TestAdminMatchAuditsBeforeSignalling(t *testing.T) {
+	nk := &fakeNakama{matches: make(map[string]*fakeMatch)}
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_ENV, map[string]string{envAdminKey: "secret"})
+	id, _ := nk.MatchCreate(ctx, "movement_match", map[string]interface{}{})
+	paused := func() bool { return nk.matches[id].state.(*MatchState).Admin.Paused }
+	pause := `{"matchId":"` + id + `","adminKey":"secret","op":"pause"}`
+
+	nk.storageErr = errors.New("storage down")
+	if _, err := adminMatchRPC(ctx, nopLogger{}, nil, nk, pause); err == nil {
+		t.Fatal("pause succeeded without an audit record")
+	}
+	if paused() {
+		t.Fatal("match paused although the audit record was not written")
+	}
+
+	nk.storageErr = nil
+	if _, err := adminMatchRPC(ctx, nopLogger{}, nil, nk, pause); err != nil {
+		t.Fatal(err)
+	}
+	if !paused() {
+		t.Fatal("match not paused")
+	}
+	var records []adminAuditRecord
+	for key, o := range nk.storage {
+		var r adminAuditRecord
+		if strings.HasPrefix(key, adminAuditCollection+"/") && json.Unmarshal([]byte(o.value), &r) == nil {
+			records = append(records, r)
+		}
+	}
+	if len(records) != 1 || records[0].Reply == nil || !records[0].Reply.OK {
+		t.Errorf("audit records %+v, want one with the match's reply", records)
+	}
+}
+
+func TestPausedMatchRefusesInput(t *testing.T) {
+	nk := &fakeNakama{matches: make(map[string]*fakeMatch)}
+	ctx := context.Background()
+	id, _ := nk.MatchCreate(ctx, "movement_match", map[string]interface{}{})
+	if _, err := nk.MatchSignal(ctx, id, `{"op":"pause"}`); err != nil {
+		t.Fatal(err)
+	}
+	m := nk.matches[id]
+	m.sent = nil
+
+	msg := fakeMessage{fakePresence: fakePresence{"p1", "s1"}, opCode: opCodePlayerMove, data: []byte(`{}`)}
+	m.state = m.handler.MatchLoop(ctx, nopLogger{}, nil, nk, m, 1, m.state, []runtime.MatchData{msg})
+
+	if len(m.sent) != 1 || m.sent[0].opCode != opCodeError {
+		t.Fatalf("sent %+v, want one error", m.sent)
+	}
+	var reply errorMessage
+	if err := json.Unmarshal([]byte(m.sent[0].data), &reply); err != nil || reply.Code != errorPaused {
+		t.Errorf("reply %s, want %s", m.sent[0].data, errorPaused)
+	}
+	if len(m.sent[0].to) != 1 || m.sent[0].to[0].GetUserId() != "p1" {
+		t.Errorf("error sent to %v, want only the sender", m.sent[0].to)
+	}
+}
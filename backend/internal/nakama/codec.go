@@ -0,0 +1,116 @@
+package nakama
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/delta/terrabound/backend/internal/matchpb"
+)
+
+// Match protocol versions this server speaks. Bump protocolVersion when a
+// message changes shape, and minProtocolVersion when old clients can no
+// longer be served.
+const (
+	protocolVersion    = 1
+	minProtocolVersion = 1
+)
+
+// The handshake is carried in the join metadata under these keys. A client
+// that sends neither is an original JSON client on protocol 1.
+const (
+	metaProtocol = "protocol"
+	metaEncoding = "encoding"
+)
+
+type wireEncoding string
+
+const (
+	encodingJSON     wireEncoding = "json"
+	encodingProtobuf wireEncoding = "protobuf"
+)
+
+func (e wireEncoding) proto() matchpb.Encoding {
+	if e == encodingProtobuf {
+		return matchpb.Encoding_ENCODING_PROTOBUF
+	}
+	return matchpb.Encoding_ENCODING_JSON
+}
+
+// clientProtocol is what a client and the match agreed on at join.
+type clientProtocol struct {
+	Version  int
+	Encoding wireEncoding
+}
+
+type welcomeMessage struct {
+	ProtocolVersion int          `json:"protocolVersion"`
+	Encoding        wireEncoding `json:"encoding"`
+	Tick            int64        `json:"tick"`
+}
+
+// negotiate reads a client's handshake from its join metadata and checks it
+// against what the server speaks and the encodings this match supports. It
+// returns a rejection reason when they are incompatible.
+func negotiate(metadata map[string]string, supported ...wireEncoding) (clientProtocol, string) {
+	p := clientProtocol{Version: 1, Encoding: encodingJSON}
+	if v, ok := metadata[metaProtocol]; ok {
+		version, err := strconv.Atoi(v)
+		if err != nil {
+			return p, fmt.Sprintf("malformed protocol version %q", v)
+		}
+		p.Version = version
+	}
+	if p.Version < minProtocolVersion || p.Version > protocolVersion {
+		return p, fmt.Sprintf("unsupported protocol version %d (server speaks %d to %d)", p.Version, minProtocolVersion, protocolVersion)
+	}
+	if e, ok := metadata[metaEncoding]; ok {
+		p.Encoding = wireEncoding(e)
+	}
+	for _, e := range supported {
+		if e == p.Encoding {
+			return p, ""
+		}
+	}
+	return p, fmt.Sprintf("unsupported encoding %q", p.Encoding)
+}
+
+// encode marshals one outgoing message in the given encoding. pb builds the
+// protobuf form and is only called when that is the encoding in use.
+func encode(enc wireEncoding, v interface{}, pb func() proto.Message) ([]byte, error) {
+	if enc == encodingProtobuf {
+		return proto.MarshalOptions{Deterministic: true}.Marshal(pb())
+	}
+	return json.Marshal(v)
+}
+
+func (p clientProtocol) welcome(tick int64) ([]byte, error) {
+	return encode(p.Encoding, welcomeMessage{ProtocolVersion: p.Version, Encoding: p.Encoding, Tick: tick}, func() proto.Message {
+		return &matchpb.Welcome{ProtocolVersion: uint32(p.Version), Encoding: p.Encoding.proto(), Tick: tick}
+	})
+}
+
+// notifyAll sends one message to every connected client in the encoding they
+// negotiated, encoding it once per encoding. Clients missing from protocols
+// get JSON.
+func notifyAll(logger runtime.Logger, dispatcher runtime.MatchDispatcher, presences map[string]runtime.Presence, protocols map[string]clientProtocol, opCode int64, v interface{}, pb func() proto.Message) {
+	byEncoding := make(map[wireEncoding][]runtime.Presence)
+	for userID, presence := range presences {
+		enc := encodingJSON
+		if p, ok := protocols[userID]; ok {
+			enc = p.Encoding
+		}
+		byEncoding[enc] = append(byEncoding[enc], presence)
+	}
+	for enc, to := range byEncoding {
+		data, err := encode(enc, v, pb)
+		if err != nil {
+			logger.Error("Failed to marshal opcode %d: %v", opCode, err)
+			continue
+		}
+		dispatcher.BroadcastMessage(opCode, data, to, nil, true)
+	}
+}
@@ -0,0 +1,91 @@
+package nakama
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/delta/terrabound/backend/internal/matchpb"
+)
+
+func TestNegotiate(t *testing.T) {
+	both := []wireEncoding{encodingJSON, encodingProtobuf}
+	for _, tc := range []struct {
+		name      string
+		metadata  map[string]string
+		supported []wireEncoding
+		want      clientProtocol
+		rejected  bool
+	}{
+		{"original client", nil, both, clientProtocol{1, encodingJSON}, false},
+		{"protobuf", map[string]string{metaProtocol: "1", metaEncoding: "protobuf"}, both, clientProtocol{1, encodingProtobuf}, false},
+		{"explicit json", map[string]string{metaEncoding: "json"}, both, clientProtocol{1, encodingJSON}, false},
+		{"newer than the server", map[string]string{metaProtocol: strconv.Itoa(protocolVersion + 1)}, both, clientProtocol{}, true},
+		{"older than the server serves", map[string]string{metaProtocol: strconv.Itoa(minProtocolVersion - 1)}, both, clientProtocol{}, true},
+		{"malformed version", map[string]string{metaProtocol: "v1"}, both, clientProtocol{}, true},
+		{"unknown encoding", map[string]string{metaEncoding: "xml"}, both, clientProtocol{}, true},
+		{"encoding the match does not speak", map[string]string{metaEncoding: "protobuf"}, []wireEncoding{encodingJSON}, clientProtocol{}, true},
+	} {
+		got, reason := negotiate(tc.metadata, tc.supported...)
+		if tc.rejected {
+			if reason == "" {
+				t.Errorf("%s: accepted as %+v", tc.name, got)
+			}
+			continue
+		}
+		if reason != "" || got != tc.want {
+			t.Errorf("%s: %+v, %q; want %+v", tc.name, got, reason, tc.want)
+		}
+	}
+}
+
+func TestMovementMatchJoinNegotiates(t *testing.T) {
+	m := &MovementMatch{}
+	ctx, nk, dispatcher := context.Background(), &fakeNakama{}, &fakeMatch{}
+	state, _, _ := m.MatchInit(ctx, nopLogger{}, nil, nk, map[string]interface{}{})
+	s := state.(*MatchState)
+
+	old := fakePresence{"old", "s-old"}
+	if _, ok, reason := m.MatchJoinAttempt(ctx, nopLogger{}, nil, nk, dispatcher, 1, s, old, map[string]string{metaProtocol: "99"}); ok || reason == "" {
+		t.Fatalf("joined on an unsupported protocol: %v %q", ok, reason)
+	}
+	if _, ok := s.Protocols["old"]; ok {
+		t.Error("rejected client's protocol recorded")
+	}
+
+	p := fakePresence{"p1", "s1"}
+	if _, ok, reason := m.MatchJoinAttempt(ctx, nopLogger{}, nil, nk, dispatcher, 1, s, p, map[string]string{metaProtocol: "1", metaEncoding: "protobuf"}); !ok {
+		t.Fatalf("protobuf client rejected: %q", reason)
+	}
+	m.MatchJoin(ctx, nopLogger{}, nil, nk, dispatcher, 7, s, []runtime.Presence{p})
+
+	if len(dispatcher.sent) == 0 || dispatcher.sent[0].opCode != opCodeWelcome {
+		t.Fatalf("first message %+v, want a welcome", dispatcher.sent)
+	}
+	var welcome matchpb.Welcome
+	if err := proto.Unmarshal([]byte(dispatcher.sent[0].data), &welcome); err != nil {
+		t.Fatalf("welcome is not protobuf: %v", err)
+	}
+	if welcome.ProtocolVersion != 1 || welcome.Encoding != matchpb.Encoding_ENCODING_PROTOBUF || welcome.Tick != 7 {
+		t.Errorf("welcome %+v", &welcome)
+	}
+}
+
+func TestStrategyMatchJoinNegotiates(t *testing.T) {
+	m, s := newTestStrategyMatch(t, map[string]interface{}{})
+	ctx, nk, dispatcher := context.Background(), &fakeNakama{}, &fakeMatch{}
+	for _, metadata := range []map[string]string{
+		{metaEncoding: "protobuf"},
+		{metaProtocol: "99"},
+	} {
+		if _, ok, _ := m.MatchJoinAttempt(ctx, nopLogger{}, nil, nk, dispatcher, 1, s, fakePresence{"p1", "s1"}, metadata); ok {
+			t.Errorf("joined with %v", metadata)
+		}
+	}
+	if _, ok, reason := m.MatchJoinAttempt(ctx, nopLogger{}, nil, nk, dispatcher, 1, s, fakePresence{"p1", "s1"}, map[string]string{metaProtocol: "1"}); !ok {
+		t.Errorf("JSON client rejected: %q", reason)
+	}
+}
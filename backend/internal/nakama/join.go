@@ -0,0 +1,118 @@
+package nakama
+
+import (
+	"context"
+	"time"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+// Reasons handed back from MatchJoinAttempt, which the client sees as-is.
+const (
+	rejectFull    = "match is full"
+	rejectRating  = "rating outside match band"
+	rejectStarted = "match already started"
+	rejectBanned  = "banned from this match"
+	rejectClosing = "match is shutting down"
+)
+
+// matchSettings are the MatchCreate params every match type shares, kept in
+// state so joins can be checked against them.
+type matchSettings struct {
+	MinElo int32
+	MaxElo int32
+	// MaxPlayers caps the seats; 0 leaves it to the match type.
+	MaxPlayers int
+	Banned     map[string]bool
+	Region     string
+	CreatedAt  int64
+	// Widen, if set, grows the band around Centre as the match waits for
+	// players, along the same curve a queued player's window follows. Waited
+	// is how long the player the match was made for had already queued.
+	Widen  *ratingWindow
+	Centre int32
+	Waited time.Duration
+}
+
+// newMatchSettings reads minElo, maxElo, maxPlayers, banned and region from
+// the match params. With widenBand set the band widens by the runtime's
+// rating window, starting from bandWaitedSeconds, around bandCentre or else
+// the middle of the band.
+func newMatchSettings(ctx context.Context, params map[string]interface{}) matchSettings {
+	settings := matchSettings{
+		MinElo:     int32(paramInt(params, "minElo", 0)),
+		MaxElo:     int32(paramInt(params, "maxElo", 0)),
+		MaxPlayers: paramInt(params, "maxPlayers", 0),
+		Banned:     make(map[string]bool),
+		Region:     paramString(params, "region", ""),
+		CreatedAt:  time.Now().Unix(),
+	}
+	for _, id := range paramStrings(params, "banned") {
+		settings.Banned[id] = true
+	}
+	if paramBool(params, "widenBand", false) {
+		window := ratingWindowFromEnv(ctx)
+		settings.Widen = &window
+		settings.Waited = time.Duration(paramInt(params, "bandWaitedSeconds", 0)) * time.Second
+		settings.Centre = int32(paramInt(params, "bandCentre", int(settings.MinElo+(settings.MaxElo-settings.MinElo)/2)))
+	}
+	return settings
+}
+
+// widenBand widens the rating band to what the match's wait so far allows,
+// reporting whether it changed. A band never narrows.
+func (m *matchSettings) widenBand(now time.Time) bool {
+	if m.Widen == nil {
+		return false
+	}
+	window := m.Widen.At(m.Waited + time.Duration(now.Unix()-m.CreatedAt)*time.Second)
+	lo, hi := ratingBand(m.Centre, window)
+	if lo >= m.MinElo && hi <= m.MaxElo {
+		return false
+	}
+	m.MinElo = min(m.MinElo, lo)
+	m.MaxElo = max(m.MaxElo, hi)
+	return true
+}
+
+// label fills in the label fields that come from the settings.
+func (m matchSettings) label(mode, phase string, players int) matchLabel {
+	return matchLabel{
+		Mode:       mode,
+		Players:    players,
+		MaxPlayers: m.MaxPlayers,
+		Open:       max(m.MaxPlayers-players, 0),
+		MinElo:     m.MinElo,
+		MaxElo:     m.MaxElo,
+		Region:     m.Region,
+		Phase:      phase,
+		CreatedAt:  m.CreatedAt,
+	}
+}
+
+// admit runs the checks every match makes of a joining player: bans, then
+// capacity and rating band, which a player returning to their own seat skips.
+// seated is how many seats are already taken. It returns the rejection
+// reason, or "" to let them in.
+func (m matchSettings) admit(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, userID string, seated int, returning bool) string {
+	if m.Banned[userID] {
+		return rejectBanned
+	}
+	if returning {
+		return ""
+	}
+	if m.MaxPlayers > 0 && seated >= m.MaxPlayers {
+		return rejectFull
+	}
+	if m.MinElo < m.MaxElo {
+		elo, err := readPlayerElo(ctx, nk, userID)
+		if err != nil {
+			logger.Warn("elo read failed: %v", err)
+			elo = 1000
+		}
+		if elo < m.MinElo || elo > m.MaxElo {
+			return rejectRating
+		}
+	}
+	return ""
+}
@@ -0,0 +1,43 @@
+package nakama
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMatchSettingsAdmit(t *testing.T) {
+	nk := &fakeNakama{metadata: map[string]string{
+		"strong": `{"elo": 1900}`,
+		"weak":   `{"elo": 900}`,
+	}}
+	settings := matchSettings{
+		MinElo:     1000,
+		MaxElo:     1400,
+		MaxPlayers: 2,
+		Banned:     map[string]bool{"banned": true},
+	}
+	for _, tc := range []struct {
+		name      string
+		userID    string
+		seated    int
+		returning bool
+		want      string
+	}{
+		{"in band with a seat free", "fresh", 1, false, ""},
+		{"banned", "banned", 0, false, rejectBanned},
+		{"banned even from their own seat", "banned", 0, true, rejectBanned},
+		{"full", "fresh", 2, false, rejectFull},
+		{"returning to a full match", "strong", 2, true, ""},
+		{"above the band", "strong", 0, false, rejectRating},
+		{"below the band", "weak", 0, false, rejectRating},
+	} {
+		if got := settings.admit(context.Background(), nopLogger{}, nk, tc.userID, tc.seated, tc.returning); got != tc.want {
+			t.Errorf("%s: %q, want %q", tc.name, got, tc.want)
+		}
+	}
+
+	open := matchSettings{Banned: map[string]bool{}}
+	if got := open.admit(context.Background(), nopLogger{}, nk, "strong", 10, false); got != "" {
+		t.Errorf("match without a band or cap rejected with %q", got)
+	}
+}
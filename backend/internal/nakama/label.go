@@ -0,0 +1,91 @@
+package nakama
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+// labelPhaseOpen is the phase of match types with no turn structure of their own.
+const labelPhaseOpen = "open"
+
+// labelPhaseClosing is published by any match that is shutting down.
+const labelPhaseClosing = "closing"
+
+// matchLabel is the JSON label every authoritative match publishes. Nakama
+// indexes its fields, so the matchmaking RPC can find matches with a
+// MatchList query on label.mode, label.open and the rest.
+type matchLabel struct {
+	Mode       string `json:"mode"`
+	Players    int    `json:"players"`
+	MaxPlayers int    `json:"maxPlayers"`
+	// Open is the number of seats a new player could still take; it drops to
+	// zero once a match stops accepting new players.
+	Open      int    `json:"open"`
+	MinElo    int32  `json:"minElo"`
+	MaxElo    int32  `json:"maxElo"`
+	Region    string `json:"region,omitempty"`
+	Phase     string `json:"phase"`
+	Map       string `json:"map,omitempty"`
+	CreatedAt int64  `json:"createdAt"`
+	// Spectators is how many people are watching without a seat.
+	Spectators int `json:"spectators"`
+}
+
+func (l matchLabel) String() string {
+	out, _ := json.Marshal(l)
+	return string(out)
+}
+
+// labelPublisher pushes a match's label to Nakama whenever it changes.
+type labelPublisher struct {
+	last string
+}
+
+func (p *labelPublisher) publish(logger runtime.Logger, dispatcher runtime.MatchDispatcher, label matchLabel) {
+	next := label.String()
+	if next == p.last {
+		return
+	}
+	if err := dispatcher.MatchLabelUpdate(next); err != nil {
+		logger.Warn("Failed to update match label: %v", err)
+		return
+	}
+	p.last = next
+}
+
+// openMatchQuery finds matches of mode with seats free seats whose rating
+// band comes within window of elo. An empty region matches any.
+func openMatchQuery(mode string, elo, window int32, region string, seats int) string {
+	lo, hi := ratingBand(elo, window)
+	query := fmt.Sprintf("+label.mode:%s +label.open:>=%d +label.minElo:<=%d +label.maxElo:>=%d", mode, seats, hi, lo)
+	if region != "" {
+		query += fmt.Sprintf(" +label.region:%s", region)
+	}
+	return query
+}
+
+// fits reports whether l is a match openMatchQuery would find, for matches
+// whose label Nakama may not have indexed yet.
+func (l matchLabel) fits(mode string, elo, window int32, region string, seats int) bool {
+	lo, hi := ratingBand(elo, window)
+	return l.Mode == mode && l.Open >= seats && l.MinElo <= hi && l.MaxElo >= lo &&
+		(region == "" || l.Region == region)
+}
+
+// ratingBand is the range of ratings within window of elo. Ratings are never
+// negative, and a negative bound would not parse in a query.
+func ratingBand(elo, window int32) (lo, hi int32) {
+	return max(elo-window, 0), elo + window
+}
+
+// underfilledMatchQuery is openMatchQuery limited to matches someone is already playing in.
+func underfilledMatchQuery(mode string, elo, window int32, region string, seats int) string {
+	return openMatchQuery(mode, elo, window, region, seats) + " +label.players:>=1"
+}
+
+// underfilled reports whether fewer than half the match's seats are taken.
+func (l matchLabel) underfilled() bool {
+	return l.Players > 0 && l.Players*2 < l.MaxPlayers
+}
@@ -0,0 +1,42 @@
+package nakama
+
+import "testing"
+
+func TestOpenMatchQuery(t *testing.T) {
+	for _, tc := range []struct {
+		elo, window int32
+		region      string
+		want        string
+	}{
+		{1000, 200, "", "+label.mode:movement +label.open:>=2 +label.minElo:<=1200 +label.maxElo:>=800"},
+		{50, 200, "eu", "+label.mode:movement +label.open:>=2 +label.minElo:<=250 +label.maxElo:>=0 +label.region:eu"},
+	} {
+		if got := openMatchQuery(modeMovement, tc.elo, tc.window, tc.region, 2); got != tc.want {
+			t.Errorf("query for %d±%d = %q, want %q", tc.elo, tc.window, got, tc.want)
+		}
+	}
+}
+
+func TestMatchLabelFits(t *testing.T) {
+	label := matchLabel{Mode: modeMovement, Players: 1, MaxPlayers: 8, Open: 2, MinElo: 0, MaxElo: 300, Region: "eu"}
+	for _, tc := range []struct {
+		name        string
+		elo, window int32
+		region      string
+		seats       int
+		want        bool
+	}{
+		{"in band", 100, 100, "eu", 2, true},
+		{"band floored at zero", 50, 200, "", 1, true},
+		{"band too high", 600, 200, "", 1, false},
+		{"too few seats", 100, 100, "", 3, false},
+		{"other region", 100, 100, "us", 1, false},
+	} {
+		if got := label.fits(modeMovement, tc.elo, tc.window, tc.region, tc.seats); got != tc.want {
+			t.Errorf("%s: fits = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+	if !label.underfilled() {
+		t.Error("one of eight seats taken is under-filled")
+	}
+}
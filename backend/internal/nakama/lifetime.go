@@ -0,0 +1,68 @@
+package nakama
+
+import (
+	"github.com/heroiclabs/nakama-common/runtime"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/delta/terrabound/backend/internal/matchpb"
+)
+
+// Reasons a match shuts itself down, sent in the shutdown notice.
+const (
+	shutdownEmpty       = "empty"
+	shutdownMaxDuration = "max_duration"
+	shutdownServer      = "server_shutdown"
+	shutdownAdmin       = "ended_by_admin"
+)
+
+// shutdownMessage warns clients the match is going away. GraceSeconds is how
+// long they have before it does; 0 means it already has.
+type shutdownMessage struct {
+	Reason       string `json:"reason"`
+	GraceSeconds int    `json:"graceSeconds"`
+}
+
+// matchLifetime stops a match that has had nobody connected for EmptyTicks,
+// or that has run for MaxTicks. Either limit is off when zero.
+type matchLifetime struct {
+	EmptyTicks int64
+	MaxTicks   int64
+	// EmptySince is the tick the match was last seen empty, or -1 while
+	// someone is connected. A new match starts out empty.
+	EmptySince int64
+}
+
+// newMatchLifetime reads emptyTimeoutSeconds and maxDurationSeconds from the
+// match params.
+func newMatchLifetime(params map[string]interface{}, tickRate, emptySeconds, maxSeconds int) matchLifetime {
+	return matchLifetime{
+		EmptyTicks: int64(paramInt(params, "emptyTimeoutSeconds", emptySeconds) * tickRate),
+		MaxTicks:   int64(paramInt(params, "maxDurationSeconds", maxSeconds) * tickRate),
+	}
+}
+
+// Check returns why the match should shut down this tick, or "" if it should
+// keep running.
+func (l *matchLifetime) Check(tick int64, connected int) string {
+	if l.MaxTicks > 0 && tick >= l.MaxTicks {
+		return shutdownMaxDuration
+	}
+	if connected > 0 {
+		l.EmptySince = -1
+		return ""
+	}
+	if l.EmptySince < 0 {
+		l.EmptySince = tick
+	}
+	if l.EmptyTicks > 0 && tick-l.EmptySince >= l.EmptyTicks {
+		return shutdownEmpty
+	}
+	return ""
+}
+
+// announceShutdown sends every connected client the shutdown notice.
+func announceShutdown(logger runtime.Logger, dispatcher runtime.MatchDispatcher, presences map[string]runtime.Presence, protocols map[string]clientProtocol, reason string, graceSeconds int) {
+	notifyAll(logger, dispatcher, presences, protocols, opCodeShutdown, shutdownMessage{Reason: reason, GraceSeconds: graceSeconds}, func() proto.Message {
+		return &matchpb.Shutdown{Reason: reason, GraceSeconds: int64(graceSeconds)}
+	})
+}
@@ -0,0 +1,109 @@
+package nakama
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+func TestMatchLifetime(t *testing.T) {
+	l := newMatchLifetime(map[string]interface{}{"emptyTimeoutSeconds": 2}, 10, 120, 0)
+	if l.EmptyTicks != 20 || l.MaxTicks != 0 {
+		t.Fatalf("lifetime %+v, want 20 empty ticks and no limit", l)
+	}
+
+	// Nobody ever joins: the timeout runs from the start.
+	if got := l.Check(19, 0); got != "" {
+		t.Errorf("shut down at tick 19: %s", got)
+	}
+	// Someone joins, then leaves at tick 30; the timeout starts again.
+	l.Check(25, 1)
+	for _, tick := range []int64{30, 49} {
+		if got := l.Check(tick, 0); got != "" {
+			t.Errorf("shut down at tick %d: %s", tick, got)
+		}
+	}
+	if got := l.Check(50, 0); got != shutdownEmpty {
+		t.Errorf("at tick 50: %q, want %q", got, shutdownEmpty)
+	}
+
+	forever := newMatchLifetime(map[string]interface{}{"emptyTimeoutSeconds": 0}, 10, 120, 0)
+	if got := forever.Check(1_000_000, 0); got != "" {
+		t.Errorf("shut down with both limits off: %s", got)
+	}
+
+	capped := newMatchLifetime(map[string]interface{}{"maxDurationSeconds": 3}, 10, 120, 0)
+	if got := capped.Check(29, 4); got != "" {
+		t.Errorf("shut down at tick 29: %s", got)
+	}
+	if got := capped.Check(30, 4); got != shutdownMaxDuration {
+		t.Errorf("at tick 30: %q, want %q", got, shutdownMaxDuration)
+	}
+}
+
+func TestMovementMatchShutsDown(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		params map[string]interface{}
+		joined bool
+		tick   int64
+		want   string
+	}{
+		{"empty", map[string]interface{}{"emptyTimeoutSeconds": 1}, false, movementTickRate, shutdownEmpty},
+		{"max duration", map[string]interface{}{"maxDurationSeconds": 1}, true, movementTickRate, shutdownMaxDuration},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m := &MovementMatch{}
+			ctx, nk, dispatcher := context.Background(), &fakeNakama{}, &fakeMatch{}
+			state, _, _ := m.MatchInit(ctx, nopLogger{}, nil, nk, tc.params)
+			p := fakePresence{"p1", "s1"}
+			if tc.joined {
+				m.MatchJoin(ctx, nopLogger{}, nil, nk, dispatcher, 1, state, []runtime.Presence{p})
+			}
+
+			if m.MatchLoop(ctx, nopLogger{}, nil, nk, dispatcher, tc.tick-1, state, nil) == nil {
+				t.Fatal("match stopped a tick early")
+			}
+			if m.MatchLoop(ctx, nopLogger{}, nil, nk, dispatcher, tc.tick, state, nil) != nil {
+				t.Fatal("match kept running")
+			}
+			if got := state.(*MatchState).Shutdown; got != tc.want {
+				t.Errorf("shut down for %q, want %q", got, tc.want)
+			}
+
+			var notice *shutdownMessage
+			for _, msg := range dispatcher.sent {
+				if msg.opCode == opCodeShutdown {
+					notice = &shutdownMessage{}
+					if err := json.Unmarshal([]byte(msg.data), notice); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			switch {
+			case tc.joined && (notice == nil || notice.Reason != tc.want):
+				t.Errorf("player was sent %+v, want a %s notice", notice, tc.want)
+			case !tc.joined && notice != nil:
+				t.Errorf("shutdown notice %+v sent to an empty match", notice)
+			}
+		})
+	}
+}
+
+func TestStrategyMatchBotsKeepItRunning(t *testing.T) {
+	m, s := newTestStrategyMatch(t, map[string]interface{}{"maxPlayers": 2, "emptyTimeoutSeconds": 1})
+	ctx, nk, dispatcher := context.Background(), &fakeNakama{}, &fakeMatch{}
+	m.MatchJoin(ctx, nopLogger{}, nil, nk, dispatcher, 1, s, []runtime.Presence{fakePresence{"p1", "s1"}})
+	if !s.start(nopLogger{}, dispatcher, 2) || len(s.Bots) != 1 {
+		t.Fatal("match did not start with a bot")
+	}
+	m.MatchLeave(ctx, nopLogger{}, nil, nk, dispatcher, 3, s, []runtime.Presence{fakePresence{"p1", "s1"}})
+
+	for tick := int64(4); tick < 4+3*strategyTickRate; tick++ {
+		if m.MatchLoop(ctx, nopLogger{}, nil, nk, dispatcher, tick, s, nil) == nil {
+			t.Fatalf("match with a bot still playing stopped at tick %d: %s", tick, s.Shutdown)
+		}
+	}
+}
@@ -1,170 +1,686 @@
-	package nakama
+package nakama
 
-	import (
-		"context"
-		"database/sql"
-		"encoding/json"
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
 
-		"github.com/heroiclabs/nakama-common/runtime"
-	)
+	"github.com/heroiclabs/nakama-common/runtime"
+	"google.golang.org/protobuf/proto"
 
-	type InputMessage struct {
-		X float32 `json:"x"`
-		Y float32 `json:"y"`
-	}
+	"github.com/delta/terrabound/backend/internal/game"
+	"github.com/delta/terrabound/backend/internal/matchpb"
+)
+
+const (
+	movementTickRate = 10
+	// defaultMovementPlayers caps a movement match created without maxPlayers.
+	defaultMovementPlayers = 8
+)
+
+// InputMessage is a movement intent. Clients that predate intents send only
+// an absolute X/Y, which is treated as a position report that must have been
+// reachable since the last one.
+type InputMessage struct {
+	game.MoveIntent
+	X *float32 `json:"x,omitempty"`
+	Y *float32 `json:"y,omitempty"`
+}
+
+type PlayerState struct {
+	UserID string  `json:"user_id"`
+	X      float32 `json:"x"`
+	Y      float32 `json:"y"`
+
+	Motion     game.Mover `json:"-"`
+	Rejections int        `json:"-"`
+}
+
+type MatchState struct {
+	MatchID   string
+	Settings  matchSettings
+	Label     labelPublisher
+	Players   map[string]*PlayerState
+	Presences map[string]runtime.Presence
+	// Spectators watch without a seat and are not in Players or Presences.
+	Spectators *spectatorFeed
+	// Protocols is what each player and spectator negotiated when they joined.
+	Protocols map[string]clientProtocol
+	// VisionRadius limits how far each player sees others; 0 means everywhere.
+	VisionRadius float32
+	Movement     game.MovementRules
+	Sync         *stateSync
+	// Grace keeps a disconnected player's position until they come back or
+	// it runs out and they are removed.
+	Grace        *reconnectGrace
+	Reservations *seatReservations
+	Lifetime     matchLifetime
+	// Shutdown is why the match is stopping, once it is.
+	Shutdown string
+	Admin    adminControls
+}
+
+type MovementMatch struct{}
+
+func (m *MovementMatch) MatchInit(
+	ctx context.Context,
+	logger runtime.Logger,
+	db *sql.DB,
+	nk runtime.NakamaModule,
+	params map[string]interface{},
+) (interface{}, int, string) {
 
-	type PlayerState struct {
-		UserID string  `json:"user_id"`
-		X      float32 `json:"x"`
-		Y      float32 `json:"y"`
+	// The world is a rectangle centred on the spawn point at the origin.
+	halfWidth := paramFloat(params, "worldWidth", 100) / 2
+	halfHeight := paramFloat(params, "worldHeight", 100) / 2
+
+	matchID, _ := ctx.Value(runtime.RUNTIME_CTX_MATCH_ID).(string)
+	settings := newMatchSettings(ctx, params)
+	if settings.MaxPlayers == 0 {
+		settings.MaxPlayers = defaultMovementPlayers
 	}
 
-	type MatchState struct {
-		Players map[string]*PlayerState
+	state := &MatchState{
+		MatchID:      matchID,
+		Settings:     settings,
+		Players:      make(map[string]*PlayerState),
+		Presences:    make(map[string]runtime.Presence),
+		Protocols:    make(map[string]clientProtocol),
+		Spectators:   newSpectatorFeed(params, movementTickRate),
+		VisionRadius: float32(paramInt(params, "visionRadius", 0)),
+		Sync:         newStateSync(),
+		Grace:        newReconnectGrace(paramInt(params, "reconnectGraceSeconds", 30), movementTickRate, policyForfeit),
+		Reservations: newSeatReservations(params, movementTickRate),
+		Lifetime:     newMatchLifetime(params, movementTickRate, 120, 0),
+		Movement: game.MovementRules{
+			MaxSpeed: paramFloat(params, "maxSpeed", 5),
+			Bounds: game.Bounds{
+				Min: game.Vec2{X: -halfWidth, Y: -halfHeight},
+				Max: game.Vec2{X: halfWidth, Y: halfHeight},
+			},
+			JumpTolerance: paramFloat(params, "jumpTolerance", 1),
+			FixWindow:     paramFloat(params, "fixWindowSeconds", 0.5),
+		},
 	}
 
-	type MovementMatch struct{}
+	tickRate := movementTickRate
+	label := state.label().String()
+	state.Label.last = label
 
-	func (m *MovementMatch) MatchInit(
-		ctx context.Context,
-		logger runtime.Logger,
-		db *sql.DB,
-		nk runtime.NakamaModule,
-		params map[string]interface{},
-	) (interface{}, int, string) {
+	logger.Info("Movement match initialized.")
+	return state, tickRate, label
+}
 
-		state := &MatchState{
-			Players: make(map[string]*PlayerState),
-		}
+func (m *MovementMatch) MatchJoinAttempt(
+	ctx context.Context,
+	logger runtime.Logger,
+	db *sql.DB,
+	nk runtime.NakamaModule,
+	dispatcher runtime.MatchDispatcher,
+	tick int64,
+	state interface{},
+	presence runtime.Presence,
+	metadata map[string]string,
+) (interface{}, bool, string) {
 
-		tickRate := 10 // 10 ticks/sec
-		label := "movement_match"
+	s := state.(*MatchState)
 
-		logger.Info("Movement match initialized.")
-		return state, tickRate, label
+	if s.Shutdown != "" {
+		return s, false, rejectClosing
+	}
+	protocol, reason := negotiate(metadata, encodingJSON, encodingProtobuf)
+	if reason != "" {
+		logger.Info("Rejected join from %s: %s", presence.GetUserId(), reason)
+		return s, false, reason
 	}
 
-	func (m *MovementMatch) MatchJoinAttempt(
-		ctx context.Context,
-		logger runtime.Logger,
-		db *sql.DB,
-		nk runtime.NakamaModule,
-		dispatcher runtime.MatchDispatcher,
-		tick int64,
-		state interface{},
-		presence runtime.Presence,
-		metadata map[string]string,
-	) (interface{}, bool, string) {
-		return state, true, ""
+	if spectating(metadata) {
+		_, seated := s.Players[presence.GetUserId()]
+		reason := rejectBanned
+		if !s.Settings.Banned[presence.GetUserId()] {
+			reason = s.Spectators.Admit(presence, metadata, seated, func(id string) bool {
+				_, ok := s.Players[id]
+				return ok
+			})
+		}
+		if reason != "" {
+			logger.Info("Rejected spectator %s: %s", presence.GetUserId(), reason)
+			return s, false, reason
+		}
+		s.Protocols[presence.GetUserId()] = protocol
+		return s, true, ""
 	}
 
-	func (m *MovementMatch) MatchJoin(
-		ctx context.Context,
-		logger runtime.Logger,
-		db *sql.DB,
-		nk runtime.NakamaModule,
-		dispatcher runtime.MatchDispatcher,
-		tick int64,
-		state interface{},
-		joins []runtime.Presence,
-	) interface{} {
+	if reason := s.admitPlayer(ctx, logger, nk, presence.GetUserId()); reason != "" {
+		logger.Info("Rejected join from %s: %s", presence.GetUserId(), reason)
+		return s, false, reason
+	}
+	if s.Grace.Holding(presence.GetUserId()) {
+		logger.Info("Player reconnecting: %s", presence.GetUserId())
+	}
+	s.Protocols[presence.GetUserId()] = protocol
+	return s, true, ""
+}
 
-		s := state.(*MatchState)
+func (m *MovementMatch) MatchJoin(
+	ctx context.Context,
+	logger runtime.Logger,
+	db *sql.DB,
+	nk runtime.NakamaModule,
+	dispatcher runtime.MatchDispatcher,
+	tick int64,
+	state interface{},
+	joins []runtime.Presence,
+) interface{} {
 
-		for _, p := range joins {
-			s.Players[p.GetUserId()] = &PlayerState{
-				UserID: p.GetUserId(),
-				X:      0,
-				Y:      0,
+	s := state.(*MatchState)
+
+	for _, p := range joins {
+		if s.Spectators.Join(p, tick) != nil {
+			s.welcome(logger, dispatcher, tick, p)
+			logger.Info("Spectator joined: %s", p.GetUserId())
+			continue
+		}
+		s.Presences[p.GetUserId()] = p
+		s.welcome(logger, dispatcher, tick, p)
+		if _, ok := s.Players[p.GetUserId()]; ok {
+			s.Grace.Return(p.GetUserId())
+			s.resync(logger, dispatcher, p)
+			logger.Info("Player reconnected: %s", p.GetUserId())
+			continue
+		}
+		s.Reservations.Claim(p.GetUserId())
+		s.Players[p.GetUserId()] = &PlayerState{
+			UserID: p.GetUserId(),
+			X:      0,
+			Y:      0,
+		}
+		logger.Info("Player joined: %s", p.GetUserId())
+	}
+	s.Label.publish(logger, dispatcher, s.label())
+
+	return s
+}
+
+func (m *MovementMatch) MatchLeave(
+	ctx context.Context,
+	logger runtime.Logger,
+	db *sql.DB,
+	nk runtime.NakamaModule,
+	dispatcher runtime.MatchDispatcher,
+	tick int64,
+	state interface{},
+	leaves []runtime.Presence,
+) interface{} {
+
+	s := state.(*MatchState)
+
+	for _, p := range leaves {
+		delete(s.Protocols, p.GetUserId())
+		if s.Spectators.Leave(p) {
+			logger.Info("Spectator left: %s", p.GetUserId())
+			continue
+		}
+		delete(s.Presences, p.GetUserId())
+		s.Sync.Forget(p.GetUserId())
+		if player, ok := s.Players[p.GetUserId()]; ok {
+			// Stand still, and accept the fresh sequence a reconnecting client starts with.
+			player.Motion.Hold()
+			player.Motion.Seq = 0
+			if s.Settings.Banned[p.GetUserId()] {
+				s.Grace.Drop(p.GetUserId(), tick)
+			} else {
+				s.Grace.Hold(p.GetUserId(), tick)
 			}
-			logger.Info("Player joined: %s", p.GetUserId())
 		}
+		logger.Info("Player left: %s", p.GetUserId())
+	}
+	s.Label.publish(logger, dispatcher, s.label())
+
+	return s
+}
+
+func (m *MovementMatch) MatchLoop(
+	ctx context.Context,
+	logger runtime.Logger,
+	db *sql.DB,
+	nk runtime.NakamaModule,
+	dispatcher runtime.MatchDispatcher,
+	tick int64,
+	state interface{},
+	messages []runtime.MatchData,
+) interface{} {
+
+	s := state.(*MatchState)
 
+	if s.Admin.End != nil {
+		s.stop(ctx, logger, nk, dispatcher, tick, shutdownAdmin, 0)
+		return nil
+	}
+	if reason := s.Lifetime.Check(tick, len(s.Presences)); reason != "" {
+		s.stop(ctx, logger, nk, dispatcher, tick, reason, 0)
+		return nil
+	}
+	s.Spectators.Flush(dispatcher, tick)
+	c := &loopContext{ctx: ctx, logger: logger, nk: nk, dispatcher: dispatcher, tick: tick, protocols: s.Protocols}
+	messages = s.Spectators.FromPlayers(messages)
+	// A paused match refuses its inputs; grace deadlines are pushed back on resume.
+	if s.Admin.Paused {
+		c.refuse(messages, errorPaused)
+		return s
+	}
+
+	if expired := s.Grace.Expired(tick); len(expired) > 0 {
+		for _, userID := range expired {
+			delete(s.Players, userID)
+			logger.Info("Player did not reconnect in time, seat released: %s", userID)
+		}
+		s.Label.publish(logger, dispatcher, s.label())
+	}
+	if lapsed := s.Reservations.Expired(tick); len(lapsed) > 0 {
+		logger.Info("Seat reservations lapsed: %v", lapsed)
+		s.Label.publish(logger, dispatcher, s.label())
+	}
+	if tick%movementTickRate == 0 && s.Settings.widenBand(time.Now()) {
+		s.Label.publish(logger, dispatcher, s.label())
+	}
+
+	// 1. Process movement intents and acks from clients
+	movementRoutes.dispatch(s, c, messages)
+	if s.Admin.Slowed(tick) {
 		return s
 	}
 
-	func (m *MovementMatch) MatchLeave(
-		ctx context.Context,
-		logger runtime.Logger,
-		db *sql.DB,
-		nk runtime.NakamaModule,
-		dispatcher runtime.MatchDispatcher,
-		tick int64,
-		state interface{},
-		leaves []runtime.Presence,
-	) interface{} {
+	// 2. Integrate every player one tick along their current intent
+	dt := float32(s.Admin.StepTicks()) / movementTickRate
+	for _, player := range s.Players {
+		s.Movement.Step(&player.Motion, dt)
+		player.X, player.Y = player.Motion.Position.X, player.Motion.Position.Y
+	}
 
-		s := state.(*MatchState)
+	// 3. Stream full snapshots to spectators
+	s.streamSpectators(logger, dispatcher)
 
-		for _, p := range leaves {
-			delete(s.Players, p.GetUserId())
-			logger.Info("Player left: %s", p.GetUserId())
+	// 4. Send delta-sync clients what changed since their last ack. Players
+	// are encoded once per wire encoding in use.
+	encoded := make(map[wireEncoding]snapshot)
+	var legacy []runtime.Presence
+	for userID, presence := range s.Presences {
+		if !s.Sync.Enabled(userID) {
+			legacy = append(legacy, presence)
+			continue
 		}
-
+		enc := s.encodingOf(userID)
+		all, ok := encoded[enc]
+		if !ok {
+			all = s.encodeEntities(logger, enc)
+			encoded[enc] = all
+		}
+		view := all
+		if s.VisionRadius > 0 {
+			view = make(snapshot)
+			for id := range s.visibleTo(userID) {
+				view[id] = all[id]
+			}
+		}
+		delta := s.Sync.Delta(userID, tick, view)
+		if delta == nil {
+			continue
+		}
+		data, err := encodeDelta(enc, delta)
+		if err != nil {
+			logger.Error("Failed to marshal state delta for %s: %v", userID, err)
+			continue
+		}
+		dispatcher.BroadcastMessage(opCodeStateDelta, data, []runtime.Presence{presence}, nil, true)
+	}
+	if len(legacy) == 0 {
 		return s
 	}
 
-	func (m *MovementMatch) MatchLoop(
-		ctx context.Context,
-		logger runtime.Logger,
-		db *sql.DB,
-		nk runtime.NakamaModule,
-		dispatcher runtime.MatchDispatcher,
-		tick int64,
-		state interface{},
-		messages []runtime.MatchData,
-	) interface{} {
-
-		s := state.(*MatchState)
-
-		// 1. Process input messages from clients (absolute positions)
-		for _, msg := range messages {
-			var input InputMessage
-			if err := json.Unmarshal(msg.GetData(), &input); err != nil {
-				logger.Warn("Failed to parse input from %s: %v", msg.GetUserId(), err)
+	// 5. Everyone else gets the full snapshot
+	if s.VisionRadius <= 0 {
+		byEncoding := make(map[wireEncoding][]runtime.Presence)
+		for _, presence := range legacy {
+			enc := s.encodingOf(presence.GetUserId())
+			byEncoding[enc] = append(byEncoding[enc], presence)
+		}
+		for enc, presences := range byEncoding {
+			stateData, err := encodePlayers(enc, s.Players)
+			if err != nil {
+				logger.Error("Failed to marshal match state: %v", err)
 				continue
 			}
+			dispatcher.BroadcastMessage(opCodeGameStateSync, stateData, presences, nil, true)
+		}
+		return s
+	}
+
+	// With a vision radius every player gets their own snapshot.
+	for _, presence := range legacy {
+		s.resync(logger, dispatcher, presence)
+	}
+
+	return s
+}
+
+// label describes the match for MatchList; held and reserved seats count as taken.
+func (s *MatchState) label() matchLabel {
+	phase := labelPhaseOpen
+	if s.Shutdown != "" {
+		phase = labelPhaseClosing
+	}
+	label := s.Settings.label(modeMovement, phase, len(s.Players))
+	label.Open = max(label.Open-s.Reservations.Count(), 0)
+	if s.Shutdown != "" {
+		label.Open = 0
+	}
+	label.Spectators = len(s.Spectators.Watching())
+	return label
+}
+
+// admitPlayer checks whether userID may take a seat, counting seats reserved
+// for others as taken. It returns the rejection reason, or "".
+func (s *MatchState) admitPlayer(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, userID string) string {
+	if s.Shutdown != "" {
+		return rejectClosing
+	}
+	_, returning := s.Players[userID]
+	return s.Settings.admit(ctx, logger, nk, userID, len(s.Players)+s.Reservations.Others(userID), returning)
+}
+
+// stop closes the match to new players, warns everyone it is going away in
+// graceSeconds and records where every player ended up.
+func (s *MatchState) stop(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, dispatcher runtime.MatchDispatcher, tick int64, reason string, graceSeconds int) {
+	s.Shutdown = reason
+	logger.Info("Match %s shutting down after %d ticks: %s", s.MatchID, tick, reason)
+	s.Label.publish(logger, dispatcher, s.label())
+	s.Spectators.FlushAll(dispatcher)
+	presences, protocols := s.audience()
+	announceShutdown(logger, dispatcher, presences, protocols, reason, graceSeconds)
+
+	if err := writeMovementResult(ctx, nk, &movementResult{
+		MatchId:  s.MatchID,
+		Shutdown: reason,
+		Players:  s.Players,
+		Ticks:    tick,
+	}); err != nil {
+		logger.Error("Failed to persist final state of match %s: %v", s.MatchID, err)
+	}
+}
+
+var movementRoutes = newMovementRoutes()
+
+func newMovementRoutes() *opRouter[*MatchState] {
+	r := newOpRouter[*MatchState]()
+	handleDecoded(r, opCodePlayerMove, decodeMove, (*MatchState).handleMove)
+	handleDecoded(r, opCodeStateAck, decodeAck, func(s *MatchState, c *loopContext, msg runtime.MatchData, ack stateAckMessage) bool {
+		s.Sync.Ack(msg.GetUserId(), ack)
+		return true
+	})
+	return r
+}
+
+func (s *MatchState) handleMove(c *loopContext, msg runtime.MatchData, input InputMessage) bool {
+	player, ok := s.Players[msg.GetUserId()]
+	if !ok {
+		return true
+	}
+	intent := input.MoveIntent
+	if input.X != nil && input.Y != nil && intent.Seq == 0 {
+		intent = game.MoveIntent{Seq: player.Motion.Seq + 1, Position: &game.Vec2{X: *input.X, Y: *input.Y}}
+	}
+	if reason, ok := s.Movement.Apply(&player.Motion, intent); !ok {
+		player.Rejections++
+		c.logger.WithFields(map[string]interface{}{
+			"user_id":    player.UserID,
+			"reason":     reason,
+			"seq":        intent.Seq,
+			"rejections": player.Rejections,
+		}).Warn("Rejected movement input")
+	}
+	return true
+}
+
+// resync sends one player a full snapshot of what they can see, for a client
+// that has just come back and holds nothing.
+func (s *MatchState) resync(logger runtime.Logger, dispatcher runtime.MatchDispatcher, presence runtime.Presence) {
+	view := s.Players
+	if s.VisionRadius > 0 {
+		view = s.visibleTo(presence.GetUserId())
+	}
+	stateData, err := encodePlayers(s.encodingOf(presence.GetUserId()), view)
+	if err != nil {
+		logger.Error("Failed to marshal resync for %s: %v", presence.GetUserId(), err)
+		return
+	}
+	dispatcher.BroadcastMessage(opCodeGameStateSync, stateData, []runtime.Presence{presence}, nil, true)
+}
 
-			if player, ok := s.Players[msg.GetUserId()]; ok {
-				player.X = input.X
-				player.Y = input.Y
+// streamSpectators sends each spectator the whole match, or what their chosen
+// player can see, encoding each distinct view once.
+func (s *MatchState) streamSpectators(logger runtime.Logger, dispatcher runtime.MatchDispatcher) {
+	type view struct {
+		enc         wireEncoding
+		perspective string
+	}
+	frames := make(map[view][]byte)
+	for userID, sp := range s.Spectators.Watching() {
+		v := view{enc: s.encodingOf(userID), perspective: sp.Perspective}
+		data, ok := frames[v]
+		if !ok {
+			players := s.Players
+			if v.perspective != "" && s.VisionRadius > 0 {
+				players = s.visibleTo(v.perspective)
+			}
+			var err error
+			if data, err = encodePlayers(v.enc, players); err != nil {
+				logger.Error("Failed to marshal spectator view: %v", err)
+				continue
 			}
+			frames[v] = data
 		}
+		s.Spectators.Send(dispatcher, sp, opCodeGameStateSync, data)
+	}
+}
+
+// welcome confirms to a joining player or spectator the protocol they negotiated.
+func (s *MatchState) welcome(logger runtime.Logger, dispatcher runtime.MatchDispatcher, tick int64, presence runtime.Presence) {
+	data, err := s.Protocols[presence.GetUserId()].welcome(tick)
+	if err != nil {
+		logger.Error("Failed to marshal welcome for %s: %v", presence.GetUserId(), err)
+		return
+	}
+	dispatcher.BroadcastMessage(opCodeWelcome, data, []runtime.Presence{presence}, nil, true)
+}
+
+func (s *MatchState) encodingOf(userID string) wireEncoding {
+	if p, ok := s.Protocols[userID]; ok {
+		return p.Encoding
+	}
+	return encodingJSON
+}
 
-		// 2. Broadcast updated state
-		stateJson, err := json.Marshal(s.Players)
+// encodeEntities encodes each player on its own, for delta sync.
+func (s *MatchState) encodeEntities(logger runtime.Logger, enc wireEncoding) snapshot {
+	out := make(snapshot, len(s.Players))
+	for id, p := range s.Players {
+		raw, err := encode(enc, p, func() proto.Message { return p.proto() })
 		if err != nil {
-			logger.Error("Failed to marshal match state: %v", err)
-			return s
+			logger.Error("Failed to marshal player %s: %v", id, err)
+			continue
 		}
+		out[id] = raw
+	}
+	return out
+}
 
-		dispatcher.BroadcastMessage(1, stateJson, nil, nil, true)
+func (p *PlayerState) proto() *matchpb.PlayerState {
+	return &matchpb.PlayerState{UserId: p.UserID, X: p.X, Y: p.Y}
+}
 
-		return s
+// encodePlayers encodes a full game_state_sync snapshot.
+func encodePlayers(enc wireEncoding, players map[string]*PlayerState) ([]byte, error) {
+	return encode(enc, players, func() proto.Message {
+		msg := &matchpb.GameStateSync{Players: make(map[string]*matchpb.PlayerState, len(players))}
+		for id, p := range players {
+			msg.Players[id] = p.proto()
+		}
+		return msg
+	})
+}
+
+func encodeDelta(enc wireEncoding, delta *stateDeltaMessage) ([]byte, error) {
+	return encode(enc, delta, func() proto.Message {
+		msg := &matchpb.StateDelta{
+			Tick:     delta.Tick,
+			BaseTick: delta.BaseTick,
+			Full:     delta.Full,
+			Changed:  make(map[string][]byte, len(delta.Changed)),
+			Removed:  delta.Removed,
+		}
+		for id, raw := range delta.Changed {
+			msg.Changed[id] = raw
+		}
+		return msg
+	})
+}
+
+func decodeMove(enc wireEncoding, data []byte) (InputMessage, error) {
+	if enc != encodingProtobuf {
+		return decodeJSON[InputMessage](enc, data)
+	}
+	var msg matchpb.MoveIntent
+	if err := proto.Unmarshal(data, &msg); err != nil {
+		return InputMessage{}, err
+	}
+	in := InputMessage{MoveIntent: game.MoveIntent{Seq: msg.GetSeq(), Direction: vec2FromProto(msg.GetDirection())}}
+	if msg.Position != nil {
+		pos := vec2FromProto(msg.Position)
+		in.Position = &pos
+	}
+	return in, nil
+}
+
+func decodeAck(enc wireEncoding, data []byte) (stateAckMessage, error) {
+	if enc != encodingProtobuf {
+		return decodeJSON[stateAckMessage](enc, data)
+	}
+	var msg matchpb.StateAck
+	if err := proto.Unmarshal(data, &msg); err != nil {
+		return stateAckMessage{}, err
+	}
+	return stateAckMessage{Tick: msg.GetTick(), Resync: msg.GetResync()}, nil
+}
+
+func vec2FromProto(v *matchpb.Vec2) game.Vec2 {
+	return game.Vec2{X: v.GetX(), Y: v.GetY()}
+}
+
+// visibleTo returns the players within VisionRadius of userID, themselves included.
+func (s *MatchState) visibleTo(userID string) map[string]*PlayerState {
+	self, ok := s.Players[userID]
+	if !ok {
+		return nil
+	}
+	r2 := s.VisionRadius * s.VisionRadius
+	out := make(map[string]*PlayerState)
+	for id, p := range s.Players {
+		dx, dy := p.X-self.X, p.Y-self.Y
+		if id == userID || dx*dx+dy*dy <= r2 {
+			out[id] = p
+		}
+	}
+	return out
+}
+
+func (m *MovementMatch) MatchSignal(
+	ctx context.Context,
+	logger runtime.Logger,
+	db *sql.DB,
+	nk runtime.NakamaModule,
+	dispatcher runtime.MatchDispatcher,
+	tick int64,
+	state interface{},
+	data string,
+) (interface{}, string) {
+
+	s := state.(*MatchState)
+
+	var sig matchSignal
+	if err := json.Unmarshal([]byte(data), &sig); err != nil {
+		logger.Warn("Ignoring malformed match signal: %v", err)
+		return s, ""
+	}
+	if sig.Op == signalOpReserve {
+		reason := s.Reservations.ReserveAll(sig.reservees(), tick, func(userID string) string {
+			return s.admitPlayer(ctx, logger, nk, userID)
+		})
+		if reason == "" {
+			s.Label.publish(logger, dispatcher, s.label())
+		}
+		return s, reserveReply(reason, s.label())
+	}
+	if out, ok := handleAdminSignal(logger, dispatcher, tick, s, sig); ok {
+		logger.Info("Admin signal %q applied to match %s: %s", sig.Op, s.MatchID, out)
+		return s, out
+	}
+	logger.Warn("Ignoring unknown match signal %q", sig.Op)
+	return s, ""
+}
+
+func (s *MatchState) controls() *adminControls { return &s.Admin }
+
+func (s *MatchState) audience() (map[string]runtime.Presence, map[string]clientProtocol) {
+	presences := s.Spectators.Presences()
+	for userID, p := range s.Presences {
+		presences[userID] = p
 	}
+	return presences, s.Protocols
+}
 
-	func (m *MovementMatch) MatchSignal(
-		ctx context.Context,
-		logger runtime.Logger,
-		db *sql.DB,
-		nk runtime.NakamaModule,
-		dispatcher runtime.MatchDispatcher,
-		tick int64,
-		state interface{},
-		data string,
-	) (interface{}, string) {
-		return state, ""
-	}
-
-	func (m *MovementMatch) MatchTerminate(
-		ctx context.Context,
-		logger runtime.Logger,
-		db *sql.DB,
-		nk runtime.NakamaModule,
-		dispatcher runtime.MatchDispatcher,
-		tick int64,
-		state interface{},
-		graceSeconds int,
-	) interface{} {
-		return state
+func (s *MatchState) baseTickRate() int { return movementTickRate }
+
+// kick disconnects a player. Unless they are banned they keep their position
+// for the reconnect grace period like after any other disconnect.
+func (s *MatchState) kick(logger runtime.Logger, dispatcher runtime.MatchDispatcher, tick int64, userID string, ban bool) error {
+	if _, ok := s.Players[userID]; !ok {
+		return fmt.Errorf("%s is not in this match", userID)
+	}
+	if ban {
+		s.Settings.Banned[userID] = true
+	}
+	logger.Info("Kicking %s from match %s (ban: %v)", userID, s.MatchID, ban)
+	presence, connected := s.Presences[userID]
+	if !connected {
+		if ban {
+			s.Grace.Drop(userID, tick)
+		}
+		return nil
 	}
+	return dispatcher.MatchKick([]runtime.Presence{presence})
+}
+
+// checkWinners accepts anything: a movement match has no winners.
+func (s *MatchState) checkWinners(winners []string) error { return nil }
+
+func (s *MatchState) extendDeadlines(ticks int64) {
+	s.Grace.Extend(ticks)
+}
+
+func (m *MovementMatch) MatchTerminate(
+	ctx context.Context,
+	logger runtime.Logger,
+	db *sql.DB,
+	nk runtime.NakamaModule,
+	dispatcher runtime.MatchDispatcher,
+	tick int64,
+	state interface{},
+	graceSeconds int,
+) interface{} {
+	s := state.(*MatchState)
+	s.stop(ctx, logger, nk, dispatcher, tick, shutdownServer, graceSeconds)
+	return s
+}
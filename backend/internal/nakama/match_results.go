@@ -0,0 +1,62 @@
+package nakama
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+
+	"github.com/delta/terrabound/backend/internal/game"
+)
+
+const matchResultsCollection = "match_results"
+
+// matchResult is the permanent record of a finished match. Seed plus Turns is
+// enough to replay every battle. A match stopped before anyone won has no
+// Outcome; Shutdown says why it stopped and World is the board as it was left.
+type matchResult struct {
+	MatchId  string            `json:"matchId"`
+	Outcome  *game.Outcome     `json:"outcome"`
+	Shutdown string            `json:"shutdown,omitempty"`
+	World    *game.World       `json:"world,omitempty"`
+	Seed     uint64            `json:"seed,string"`
+	Turns    []game.TurnResult `json:"turns"`
+	EndedAt  int64             `json:"endedAt"`
+}
+
+// movementResult is the final state of a movement match.
+type movementResult struct {
+	MatchId  string                  `json:"matchId"`
+	Shutdown string                  `json:"shutdown"`
+	Players  map[string]*PlayerState `json:"players"`
+	Ticks    int64                   `json:"ticks"`
+	EndedAt  int64                   `json:"endedAt"`
+}
+
+// writeMatchResult persists the outcome under the match id, readable by everyone.
+func writeMatchResult(ctx context.Context, nk runtime.NakamaModule, result *matchResult) error {
+	result.EndedAt = time.Now().Unix()
+	return writeFinalState(ctx, nk, result.MatchId, result)
+}
+
+func writeMovementResult(ctx context.Context, nk runtime.NakamaModule, result *movementResult) error {
+	result.EndedAt = time.Now().Unix()
+	return writeFinalState(ctx, nk, result.MatchId, result)
+}
+
+func writeFinalState(ctx context.Context, nk runtime.NakamaModule, matchID string, record interface{}) error {
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = nk.StorageWrite(ctx, []*runtime.StorageWrite{{
+		Collection:      matchResultsCollection,
+		Key:             matchID,
+		UserID:          "",
+		Value:           string(value),
+		PermissionRead:  2,
+		PermissionWrite: 0,
+	}})
+	return err
+}
@@ -6,24 +6,34 @@ import (
 	"encoding/json"
 	"fmt"
 	"math"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/heroiclabs/nakama-common/api"
 	"github.com/heroiclabs/nakama-common/runtime"
+
+	"github.com/delta/terrabound/backend/internal/constants"
+	"github.com/delta/terrabound/backend/internal/rating"
 )
 
+const maxReturnRecords = 128
+
+// Match modes the dynamic_match RPC can ask for, and the handler each one runs.
 const (
-	matchesCollection = "dynamic_matches"
-	maxReturnRecords  = 128
+	modeMovement = "movement"
+	modeStrategy = "strategy"
 )
 
-type matchMetadata struct {
-	MatchId        string `json:"matchId"`
-	MinElo         int32  `json:"minElo"`
-	MaxElo         int32  `json:"maxElo"`
-	CurrentPlayers int32  `json:"currentPlayers"`
-	MaxPlayers     int32  `json:"maxPlayers"`
-	CreatedAt      int64  `json:"createdAt"`
+var matchModules = map[string]string{
+	modeMovement: "movement_match",
+	modeStrategy: "strategy_match",
+}
+
+type dynamicMatchRequest struct {
+	Mode string `json:"mode"`
+	// Region restricts the search to matches hosted for that region.
+	Region string `json:"region"`
 }
 
 type rpcResponse struct {
@@ -35,7 +45,7 @@ type rpcResponse struct {
 	ServerTime     int64  `json:"serverTime"`
 }
 
-// readPlayerElo obtains the player's ELO from account metadata, defaults to 1000 when missing.
+// readPlayerElo obtains the player's rating from account metadata, defaults to 1000 when missing.
 func readPlayerElo(ctx context.Context, nk runtime.NakamaModule, userID string) (int32, error) {
 	users, err := nk.UsersGetId(ctx, []string{userID}, nil)
 	if err != nil || len(users) == 0 {
@@ -43,86 +53,271 @@ func readPlayerElo(ctx context.Context, nk runtime.NakamaModule, userID string)
 	}
 	var meta map[string]interface{}
 	if err := json.Unmarshal([]byte(users[0].Metadata), &meta); err != nil {
-		return 1000, nil
-	}
-	if val, ok := meta["elo"].(float64); ok {
-		return int32(val), nil
+		return rating.DefaultRating, nil
 	}
-	return 1000, nil
+	return int32(ratingFromMetadata(meta).Rating), nil
 }
 
-// findCompatibleMatch scans the storage bucket for a compatible open match.
-func findCompatibleMatch(ctx context.Context, nk runtime.NakamaModule, elo int32) (*api.StorageObject, *matchMetadata, error) {
-	list, _, err := nk.StorageList(ctx, "", "", matchesCollection, maxReturnRecords, "")
+// compatibleMatches lists the matches query finds that keep accepts, or all
+// of them if keep is nil, straight from their labels, best first: the one
+// whose band is centred closest to the player leads.
+func compatibleMatches(ctx context.Context, nk runtime.NakamaModule, elo int32, query string, keep func(matchLabel) bool) ([]*api.Match, error) {
+	matches, err := nk.MatchList(ctx, maxReturnRecords, true, "", nil, nil, query)
 	if err != nil {
-		return nil, nil, err
-	}
-	var bestMatch matchMetadata
-	var bestRecord *api.StorageObject
-	bestScore := math.MaxFloat64
-	hasBest := false
-	for _, rec := range list {
-		var meta matchMetadata
-		if err := json.Unmarshal([]byte(rec.Value), &meta); err != nil {
+		return nil, err
+	}
+	scores := make(map[string]float64, len(matches))
+	candidates := matches[:0:0]
+	for _, match := range matches {
+		var label matchLabel
+		if err := json.Unmarshal([]byte(match.GetLabel().GetValue()), &label); err != nil || keep != nil && !keep(label) {
 			continue
 		}
+		currentMid := float64(label.MinElo+label.MaxElo) / 2.0
+		scores[match.GetMatchId()] = math.Abs(float64(elo) - currentMid)
+		candidates = append(candidates, match)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return scores[candidates[i].GetMatchId()] < scores[candidates[j].GetMatchId()]
+	})
+	return candidates, nil
+}
 
-		// Storage key is the authoritative match id for this record.
-		meta.MatchId = rec.Key
+// reserveCompatibleMatch asks each compatible match in turn to reserve seats
+// for all of userIDs and returns the first that does, with its label as of the
+// reservation. A match that filled up since it was listed just says no.
+func reserveCompatibleMatch(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, userIDs []string, elo int32, query string, keep func(matchLabel) bool) (string, *matchLabel, error) {
+	matches, err := compatibleMatches(ctx, nk, elo, query, keep)
+	if err != nil {
+		return "", nil, err
+	}
+	ids := make([]string, len(matches))
+	for i, match := range matches {
+		ids[i] = match.GetMatchId()
+	}
+	matchID, label := reserveInMatches(ctx, logger, nk, userIDs, ids)
+	return matchID, label, nil
+}
 
-		// Defensive: storage can contain stale match IDs if authoritative matches ended
-		// without their corresponding records being removed.
-		if meta.MatchId == "" {
-			_ = nk.StorageDelete(ctx, []*runtime.StorageDelete{{Collection: matchesCollection, Key: rec.Key, UserID: ""}})
+// reserveInMatches asks each of matchIDs in turn to reserve seats for all of
+// userIDs and returns the first that does, with its label as of the
+// reservation.
+func reserveInMatches(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, userIDs, matchIDs []string) (string, *matchLabel) {
+	sig, _ := json.Marshal(matchSignal{Op: signalOpReserve, Party: userIDs})
+	for _, matchID := range matchIDs {
+		out, err := nk.MatchSignal(ctx, matchID, string(sig))
+		if err != nil {
+			// Most likely it ended since it was listed.
+			recentMatches.forget(matchID)
 			continue
 		}
-		match, err := nk.MatchGet(ctx, meta.MatchId)
-		if err != nil || match == nil {
-			_ = nk.StorageDelete(ctx, []*runtime.StorageDelete{{Collection: matchesCollection, Key: rec.Key, UserID: ""}})
+		var reply signalReply
+		if err := json.Unmarshal([]byte(out), &reply); err != nil || !reply.OK {
+			logger.Debug("Match %s declined seats for %v: %s", matchID, userIDs, reply.Error)
 			continue
 		}
-
-		if meta.CurrentPlayers >= meta.MaxPlayers {
+		var label matchLabel
+		if err := json.Unmarshal(reply.State, &label); err != nil {
 			continue
 		}
+		recentMatches.update(matchID, label)
+		return matchID, &label
+	}
+	return "", nil
+}
+
+func newRPCResponse(matchID string, label *matchLabel) rpcResponse {
+	return rpcResponse{
+		MatchId:        matchID,
+		MinElo:         label.MinElo,
+		MaxElo:         label.MaxElo,
+		CurrentPlayers: int32(label.Players),
+		MaxPlayers:     int32(label.MaxPlayers),
+		ServerTime:     time.Now().Unix(),
+	}
+}
 
-		currentMid := float64(meta.MinElo+meta.MaxElo) / 2.0
-		score := math.Abs(float64(elo) - currentMid)
-		if !hasBest || score < bestScore {
-			bestScore = score
-			hasBest = true
-			bestMatch = meta
-			bestRecord = rec
+// validRegion keeps region names to the characters a label query can take verbatim.
+func validRegion(region string) bool {
+	for _, r := range region {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-') {
+			return false
 		}
 	}
-	if !hasBest {
-		return nil, nil, nil
+	return len(region) <= 32
+}
+
+// matchCreateParams builds the MatchCreate params for a new lobby. Strategy
+// matches get a generated map sized for the lobby: five regions a seat and a
+// continent for every four seats.
+func matchCreateParams(mode, region string, minElo, maxElo, maxPlayers int32) map[string]interface{} {
+	params := map[string]interface{}{
+		"minElo":     minElo,
+		"maxElo":     maxElo,
+		"maxPlayers": maxPlayers,
+		"region":     region,
+	}
+	if mode == modeStrategy {
+		continents := 1 + maxPlayers/4
+		params["mapRegions"] = maxPlayers * 5
+		params["mapContinents"] = continents
+		params["mapChokepoints"] = continents
+	}
+	return params
+}
+
+// matchCreateMu makes callers on this node that found no open match take
+// turns creating one, so a burst of requests fills one new match instead of
+// opening one each. Across nodes two matches may still be created, but seats
+// are only ever handed out by the matches themselves.
+var matchCreateMu sync.Mutex
+
+// recentMatchSeconds is how long this node remembers a match it created.
+// Nakama indexes labels in batches, so for a while after it is created a
+// match may not show up in MatchList at all.
+const recentMatchSeconds = 60
+
+// recentMatchSet is the dynamic matches this node created lately, with the
+// label each one last reported here. The creation path looks here as well as
+// in MatchList, so callers queued on matchCreateMu find the match the caller
+// before them just made. Like matchCreateMu it only covers this node: a
+// request served by another node sees the match once MatchList indexes it,
+// and until then two nodes may each create a match for players who would
+// have fit in one.
+type recentMatchSet struct {
+	mu      sync.Mutex
+	matches map[string]recentMatch
+}
+
+type recentMatch struct {
+	Label     matchLabel
+	CreatedAt time.Time
+}
+
+var recentMatches = &recentMatchSet{matches: make(map[string]recentMatch)}
+
+func (r *recentMatchSet) add(matchID string, label matchLabel, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.matches[matchID] = recentMatch{Label: label, CreatedAt: now}
+}
+
+// update records a label a match reported, if it is one of ours.
+func (r *recentMatchSet) update(matchID string, label matchLabel) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if m, ok := r.matches[matchID]; ok {
+		m.Label = label
+		r.matches[matchID] = m
+	}
+}
+
+func (r *recentMatchSet) forget(matchID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.matches, matchID)
+}
+
+// find returns the matches still remembered at now that keep accepts, the
+// one whose band is centred closest to elo first.
+func (r *recentMatchSet) find(now time.Time, elo int32, keep func(matchLabel) bool) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	scores := make(map[string]float64)
+	var ids []string
+	for id, m := range r.matches {
+		if now.Sub(m.CreatedAt) > recentMatchSeconds*time.Second {
+			delete(r.matches, id)
+			continue
+		}
+		if !keep(m.Label) {
+			continue
+		}
+		scores[id] = math.Abs(float64(elo) - float64(m.Label.MinElo+m.Label.MaxElo)/2.0)
+		ids = append(ids, id)
 	}
-	return bestRecord, &bestMatch, nil
+	sort.Slice(ids, func(i, j int) bool {
+		if scores[ids[i]] != scores[ids[j]] {
+			return scores[ids[i]] < scores[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+	return ids
 }
 
-// writeMatchRecord writes or updates a match record.
-func writeMatchRecord(ctx context.Context, nk runtime.NakamaModule, record *matchMetadata, version string) error {
-	value, _ := json.Marshal(record)
-	writes := []*runtime.StorageWrite{
-		{
-			Collection:      matchesCollection,
-			Key:             record.MatchId,
-			UserID:          "", // public ownership
-			Value:           string(value),
-			PermissionRead:  2,
-			PermissionWrite: 0,
-			Version:         version,
-		},
+// joinDynamicMatch reserves seats for all of userIDs together in the best
+// open match for elo. Matches whose band is within window of elo come first;
+// failing those, a match short of players anywhere within the widest window
+// beats opening a new one. A new match is banded window either side of elo.
+func joinDynamicMatch(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, userIDs []string, elo int32, window ratingWindow, waited time.Duration, mode, region string) (string, *matchLabel, error) {
+	const maxPlayers = int32(8)
+	eloRange := window.At(waited)
+
+	reserve := func() (string, *matchLabel) {
+		matchID, label, err := reserveCompatibleMatch(ctx, logger, nk, userIDs, elo, openMatchQuery(mode, elo, eloRange, region, len(userIDs)), nil)
+		if err != nil {
+			logger.Error("list matches failed: %v", err)
+		}
+		if label != nil {
+			return matchID, label
+		}
+		matchID, label, err = reserveCompatibleMatch(ctx, logger, nk, userIDs, elo, underfilledMatchQuery(mode, elo, window.Widest(), region, len(userIDs)), matchLabel.underfilled)
+		if err != nil {
+			logger.Error("list matches failed: %v", err)
+		}
+		if label != nil {
+			return matchID, label
+		}
+		// Matches made here lately that MatchList may not show yet.
+		fits := func(l matchLabel) bool { return l.fits(mode, elo, eloRange, region, len(userIDs)) }
+		return reserveInMatches(ctx, logger, nk, userIDs, recentMatches.find(time.Now(), elo, fits))
 	}
-	if _, err := nk.StorageWrite(ctx, writes); err != nil {
-		return err
+
+	// 1) Reserve seats in a compatible open match
+	matchID, label := reserve()
+
+	// 2) Otherwise create a new authoritative match with the seats already
+	// reserved; it publishes its own label. Whoever held the lock before us
+	// may have just created one we fit in.
+	if label == nil {
+		matchCreateMu.Lock()
+		defer matchCreateMu.Unlock()
+		matchID, label = reserve()
 	}
-	return nil
+	if label == nil {
+		minElo, maxElo := ratingBand(elo, eloRange)
+		params := matchCreateParams(mode, region, minElo, maxElo, maxPlayers)
+		params[reservedForParam] = userIDs
+		// The band keeps widening while the match waits, so players who
+		// come later at a narrower window still find it.
+		params["widenBand"] = true
+		params["bandWaitedSeconds"] = int(waited.Seconds())
+		params["bandCentre"] = int(elo)
+		var err error
+		matchID, err = nk.MatchCreate(ctx, matchModules[mode], params)
+		if err != nil {
+			return "", nil, runtime.NewError(fmt.Sprintf("match create failed: %v", err), 13)
+		}
+		label = &matchLabel{
+			Mode:       mode,
+			Players:    len(userIDs),
+			MaxPlayers: int(maxPlayers),
+			Open:       int(maxPlayers) - len(userIDs),
+			MinElo:     minElo,
+			MaxElo:     maxElo,
+			Region:     region,
+		}
+		recentMatches.add(matchID, *label, time.Now())
+	}
+	return matchID, label, nil
 }
 
-// requestDynamicMatch implements the backend-driven dynamic room creation/join selection.
-// HAVE TO FIX RACE CONDITION IN THIS RPC.
+// requestDynamicMatch reserves a seat for the caller in the best open match,
+// creating one if there is none. The client must join within the match's
+// reservationSeconds or the seat is given to someone else. Until the caller
+// is given a seat alongside other players their queue ticket is kept, so
+// each time they ask again they are matched more widely; a match made for
+// them widens its own band meanwhile, so others find it.
 func requestDynamicMatch(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
 	session := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
 	if session == "" {
@@ -135,62 +330,41 @@ func requestDynamicMatch(ctx context.Context, logger runtime.Logger, db *sql.DB,
 		elo = 1000
 	}
 
-	const maxPlayers = int32(8)
-	const eloRange = int32(200)
-
-	// 1) Find compatible open match
-	rec, meta, err := findCompatibleMatch(ctx, nk, elo)
-	if err != nil {
-		logger.Error("list matches failed: %v", err)
-	}
-
-	if meta != nil && rec != nil {
-		meta.CurrentPlayers = int32(math.Min(float64(meta.CurrentPlayers+1), float64(meta.MaxPlayers)))
-		if err := writeMatchRecord(ctx, nk, meta, rec.Version); err != nil {
-			return "", runtime.NewError(fmt.Sprintf("failed to update match: %v", err), 13)
+	req := dynamicMatchRequest{Mode: modeMovement}
+	if payload != "" {
+		if err := json.Unmarshal([]byte(payload), &req); err != nil {
+			return "", constants.ErrUnmarshalRequest
 		}
-		resp := rpcResponse{
-			MatchId:        meta.MatchId,
-			MinElo:         meta.MinElo,
-			MaxElo:         meta.MaxElo,
-			CurrentPlayers: meta.CurrentPlayers,
-			MaxPlayers:     meta.MaxPlayers,
-			ServerTime:     time.Now().Unix(),
-		}
-		out, _ := json.Marshal(resp)
-		return string(out), nil
+	}
+	if req.Mode == "" {
+		req.Mode = modeMovement
+	}
+	if _, ok := matchModules[req.Mode]; !ok || !validRegion(req.Region) {
+		return "", constants.ErrBadInput
 	}
 
-	// 2) Create new authoritative match and persist metadata
-	matchId, err := nk.MatchCreate(ctx, "movement_match", map[string]interface{}{
-		"minElo": elo - eloRange,
-		"maxElo": elo + eloRange})
-
+	now := time.Now()
+	ticket, err := loadQueueTicket(ctx, nk, session, req.Mode, req.Region, now)
 	if err != nil {
-		return "", runtime.NewError(fmt.Sprintf("match create failed: %v", err), 13)
+		logger.Warn("queue ticket read failed for %s: %v", session, err)
+		ticket = &queueTicket{Mode: req.Mode, Region: req.Region, EnqueuedAt: now.Unix(), LastSeenAt: now.Unix()}
 	}
 
-	meta = &matchMetadata{
-		MatchId:        matchId,
-		MinElo:         elo - eloRange,
-		MaxElo:         elo + eloRange,
-		CurrentPlayers: 1,
-		MaxPlayers:     maxPlayers,
-		CreatedAt:      time.Now().Unix(),
+	matchID, label, err := joinDynamicMatch(ctx, logger, nk, []string{session}, elo, ratingWindowFromEnv(ctx), ticket.Waited(now), req.Mode, req.Region)
+	if err != nil {
+		return "", err
 	}
 
-	if err := writeMatchRecord(ctx, nk, meta, ""); err != nil {
-		return "", runtime.NewError(fmt.Sprintf("storage write failed: %v", err), 13)
+	// Seats taken or held by anyone else mean the caller has company.
+	if label.MaxPlayers-label.Open > 1 {
+		err = deleteQueueTicket(ctx, nk, session)
+	} else {
+		err = writeQueueTicket(ctx, nk, session, ticket)
 	}
-
-	resp := rpcResponse{
-		MatchId:        meta.MatchId,
-		MinElo:         meta.MinElo,
-		MaxElo:         meta.MaxElo,
-		CurrentPlayers: meta.CurrentPlayers,
-		MaxPlayers:     meta.MaxPlayers,
-		ServerTime:     time.Now().Unix(),
+	if err != nil {
+		logger.Warn("queue ticket update failed for %s: %v", session, err)
 	}
-	out, _ := json.Marshal(resp)
+
+	out, _ := json.Marshal(newRPCResponse(matchID, label))
 	return string(out), nil
 }
@@ -0,0 +1,414 @@
+package nakama
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/heroiclabs/nakama-common/api"
+	"github.com/heroiclabs/nakama-common/runtime"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...interface{})                       {}
+func (nopLogger) Info(string, ...interface{})                        {}
+func (nopLogger) Warn(string, ...interface{})                        {}
+func (nopLogger) Error(string, ...interface{})                       {}
+func (l nopLogger) WithField(string, interface{}) runtime.Logger     { return l }
+func (l nopLogger) WithFields(map[string]interface{}) runtime.Logger { return l }
+func (nopLogger) Fields() map[string]interface{}                     { return nil }
+
+// fakeMatch runs a movement match the way Nakama does: one call into the
+// handler at a time.
+type fakeMatch struct {
+	mu      sync.Mutex
+	handler runtime.Match
+	state   interface{}
+
+	// label is what the match last published and indexed what MatchList
+	// shows. Like Nakama's batched indexing, a label only becomes visible
+	// once it has been published for indexLag.
+	labelMu  sync.Mutex
+	label    string
+	labelAt  time.Time
+	indexed  string
+	indexLag time.Duration
+
+	// sent is every message the match sent, in order.
+	sent []sentMessage
+}
+
+type sentMessage struct {
+	opCode int64
+	data   string
+	to     []runtime.Presence
+}
+
+func (m *fakeMatch) BroadcastMessage(opCode int64, data []byte, to []runtime.Presence, _ runtime.Presence, _ bool) error {
+	m.sent = append(m.sent, sentMessage{opCode, string(data), to})
+	return nil
+}
+
+func (m *fakeMatch) BroadcastMessageDeferred(int64, []byte, []runtime.Presence, runtime.Presence, bool) error {
+	return nil
+}
+
+func (m *fakeMatch) MatchKick([]runtime.Presence) error { return nil }
+
+func (m *fakeMatch) MatchLabelUpdate(label string) error {
+	m.labelMu.Lock()
+	defer m.labelMu.Unlock()
+	m.label, m.labelAt = label, time.Now()
+	return nil
+}
+
+// listed returns the label MatchList sees now.
+func (m *fakeMatch) listed() string {
+	m.labelMu.Lock()
+	defer m.labelMu.Unlock()
+	if time.Since(m.labelAt) >= m.indexLag {
+		m.indexed = m.label
+	}
+	return m.indexed
+}
+
+// fakeNakama implements the calls the RPCs under test make. Anything else
+// panics on the nil embedded module.
+type fakeNakama struct {
+	runtime.NakamaModule
+
+	mu      sync.Mutex
+	matches map[string]*fakeMatch
+	// indexLag is how long a match's label takes to show up in MatchList.
+	indexLag time.Duration
+
+	// dataMu guards everything below. Storage and account updates apply
+	// all or nothing, checking object versions like Nakama does.
+	dataMu  sync.Mutex
+	storage map[string]fakeObject
+	// metadata is each account's metadata, "{}" if unset.
+	metadata map[string]string
+	// missing lists user ids with no account behind them.
+	missing map[string]bool
+	// notified is every notification sent, as "user:code".
+	notified []string
+	// storageErr, if set, fails every storage write.
+	storageErr error
+	// beforeUpdate, if set, runs before account updates are applied.
+	beforeUpdate func()
+}
+
+type fakeObject struct {
+	value, version string
+}
+
+// fakeMatchSeq keeps match ids unique across tests, as the node remembers
+// the matches it created between them.
+var fakeMatchSeq atomic.Int64
+
+var fakeVersionSeq atomic.Int64
+
+func storageKey(collection, key, userID string) string {
+	return collection + "/" + key + "/" + userID
+}
+
+// conflicts reports whether a write or delete expecting version of key must fail.
+func (n *fakeNakama) conflicts(key, version string) bool {
+	o, ok := n.storage[key]
+	switch version {
+	case "":
+		return false
+	case "*":
+		return ok
+	default:
+		return !ok || o.version != version
+	}
+}
+
+func (n *fakeNakama) StorageRead(ctx context.Context, reads []*runtime.StorageRead) ([]*api.StorageObject, error) {
+	n.dataMu.Lock()
+	defer n.dataMu.Unlock()
+	var out []*api.StorageObject
+	for _, r := range reads {
+		if o, ok := n.storage[storageKey(r.Collection, r.Key, r.UserID)]; ok {
+			out = append(out, &api.StorageObject{Collection: r.Collection, Key: r.Key, UserId: r.UserID, Value: o.value, Version: o.version})
+		}
+	}
+	return out, nil
+}
+
+func (n *fakeNakama) StorageWrite(ctx context.Context, writes []*runtime.StorageWrite) ([]*api.StorageObjectAck, error) {
+	_, _, err := n.MultiUpdate(ctx, nil, writes, nil, nil, false)
+	return nil, err
+}
+
+func (n *fakeNakama) StorageDelete(ctx context.Context, deletes []*runtime.StorageDelete) error {
+	_, _, err := n.MultiUpdate(ctx, nil, nil, deletes, nil, false)
+	return err
+}
+
+func (n *fakeNakama) MultiUpdate(ctx context.Context, accounts []*runtime.AccountUpdate, writes []*runtime.StorageWrite, deletes []*runtime.StorageDelete, wallets []*runtime.WalletUpdate, updateLedger bool) ([]*api.StorageObjectAck, []*runtime.WalletUpdateResult, error) {
+	if len(accounts) > 0 && n.beforeUpdate != nil {
+		n.beforeUpdate()
+	}
+	n.dataMu.Lock()
+	defer n.dataMu.Unlock()
+	if n.storageErr != nil && len(writes) > 0 {
+		return nil, nil, n.storageErr
+	}
+	for _, w := range writes {
+		if n.conflicts(storageKey(w.Collection, w.Key, w.UserID), w.Version) {
+			return nil, nil, fmt.Errorf("version check failed for %s/%s", w.Collection, w.Key)
+		}
+	}
+	for _, d := range deletes {
+		if n.conflicts(storageKey(d.Collection, d.Key, d.UserID), d.Version) {
+			return nil, nil, fmt.Errorf("version check failed for %s/%s", d.Collection, d.Key)
+		}
+	}
+
+	if n.storage == nil {
+		n.storage = make(map[string]fakeObject)
+	}
+	for _, w := range writes {
+		n.storage[storageKey(w.Collection, w.Key, w.UserID)] = fakeObject{w.Value, fmt.Sprint(fakeVersionSeq.Add(1))}
+	}
+	for _, d := range deletes {
+		delete(n.storage, storageKey(d.Collection, d.Key, d.UserID))
+	}
+	if len(accounts) > 0 && n.metadata == nil {
+		n.metadata = make(map[string]string)
+	}
+	for _, a := range accounts {
+		meta, _ := json.Marshal(a.Metadata)
+		n.metadata[a.UserID] = string(meta)
+	}
+	return nil, nil, nil
+}
+
+func (n *fakeNakama) UsersGetId(ctx context.Context, userIDs []string, facebookIDs []string) ([]*api.User, error) {
+	n.dataMu.Lock()
+	defer n.dataMu.Unlock()
+	var users []*api.User
+	for _, id := range userIDs {
+		if n.missing[id] {
+			continue
+		}
+		meta, ok := n.metadata[id]
+		if !ok {
+			meta = "{}"
+		}
+		users = append(users, &api.User{Id: id, Metadata: meta})
+	}
+	return users, nil
+}
+
+func (n *fakeNakama) NotificationSend(ctx context.Context, userID, subject string, content map[string]interface{}, code int, sender string, persistent bool) error {
+	n.dataMu.Lock()
+	defer n.dataMu.Unlock()
+	n.notified = append(n.notified, fmt.Sprintf("%s:%d", userID, code))
+	return nil
+}
+
+func (n *fakeNakama) MatchCreate(ctx context.Context, module string, params map[string]interface{}) (string, error) {
+	n.mu.Lock()
+	id := fmt.Sprintf("match-%d", fakeMatchSeq.Add(1))
+	m := &fakeMatch{handler: &MovementMatch{}, indexLag: n.indexLag}
+	n.matches[id] = m
+	m.mu.Lock()
+	n.mu.Unlock()
+	defer m.mu.Unlock()
+
+	var label string
+	m.state, _, label = m.handler.MatchInit(context.WithValue(ctx, runtime.RUNTIME_CTX_MATCH_ID, id), nopLogger{}, nil, n, params)
+	m.MatchLabelUpdate(label)
+	return id, nil
+}
+
+// MatchList returns every match whose indexed label shows an open seat; the
+// label query itself is not evaluated.
+func (n *fakeNakama) MatchList(ctx context.Context, limit int, authoritative bool, label string, minSize, maxSize *int, query string) ([]*api.Match, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	var out []*api.Match
+	for id, m := range n.matches {
+		value := m.listed()
+		var l matchLabel
+		if err := json.Unmarshal([]byte(value), &l); err != nil || l.Open < 1 {
+			continue
+		}
+		out = append(out, &api.Match{MatchId: id, Authoritative: true, Label: wrapperspb.String(value)})
+	}
+	return out, nil
+}
+
+func (n *fakeNakama) MatchSignal(ctx context.Context, id string, data string) (string, error) {
+	n.mu.Lock()
+	m, ok := n.matches[id]
+	n.mu.Unlock()
+	if !ok {
+		return "", fmt.Errorf("match not found")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out string
+	m.state, out = m.handler.MatchSignal(ctx, nopLogger{}, nil, n, m, 0, m.state, data)
+	return out, nil
+}
+
+func TestRequestDynamicMatchConcurrent(t *testing.T) {
+	// No match shows up in MatchList for the length of the test, so matches
+	// are only filled through what this node remembers creating.
+	nk := &fakeNakama{matches: make(map[string]*fakeMatch), indexLag: time.Hour}
+	const players = 100
+
+	var wg sync.WaitGroup
+	matchOf := make([]string, players)
+	for i := range players {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, fmt.Sprintf("user-%03d", i))
+			out, err := requestDynamicMatch(ctx, nopLogger{}, nil, nk, `{"mode":"movement"}`)
+			if err != nil {
+				t.Errorf("player %d: %v", i, err)
+				return
+			}
+			var resp rpcResponse
+			if err := json.Unmarshal([]byte(out), &resp); err != nil {
+				t.Errorf("player %d: %v", i, err)
+				return
+			}
+			matchOf[i] = resp.MatchId
+		}()
+	}
+	wg.Wait()
+
+	seats := make(map[string]int)
+	for _, id := range matchOf {
+		seats[id]++
+	}
+	for id, n := range seats {
+		state := nk.matches[id].state.(*MatchState)
+		if n > state.Settings.MaxPlayers {
+			t.Errorf("%s: %d players sent to %d seats", id, n, state.Settings.MaxPlayers)
+		}
+		if got := state.Reservations.Count(); got != n {
+			t.Errorf("%s: %d players sent but %d seats reserved", id, n, got)
+		}
+	}
+	if want := (players + 7) / 8; len(seats) != want {
+		t.Errorf("created %d matches, want %d", len(seats), want)
+	}
+}
+
+func TestSeatReservationLapses(t *testing.T) {
+	nk := &fakeNakama{matches: make(map[string]*fakeMatch)}
+	ctx := context.Background()
+	id, _ := nk.MatchCreate(ctx, "movement_match", map[string]interface{}{"reservationSeconds": 1, reservedForParam: "a"})
+	m := nk.matches[id]
+
+	open := func() int {
+		var l matchLabel
+		json.Unmarshal([]byte(m.label), &l)
+		return l.Open
+	}
+	if got := open(); got != defaultMovementPlayers-1 {
+		t.Fatalf("open seats after create = %d, want %d", got, defaultMovementPlayers-1)
+	}
+	for tick := int64(0); tick <= movementTickRate; tick++ {
+		m.state = m.handler.MatchLoop(ctx, nopLogger{}, nil, nk, m, tick, m.state, nil)
+	}
+	if got := open(); got != defaultMovementPlayers {
+		t.Errorf("open seats after reservation lapsed = %d, want %d", got, defaultMovementPlayers)
+	}
+}
+
+func TestPartySeatsReservedTogether(t *testing.T) {
+	nk := &fakeNakama{matches: make(map[string]*fakeMatch)}
+	ctx := context.Background()
+	join := func(userIDs ...string) string {
+		id, _, err := joinDynamicMatch(ctx, nopLogger{}, nk, userIDs, 1000, ratingWindow{Base: 200}, 0, modeMovement, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+
+	first := join("solo-1", "solo-2", "solo-3")
+	if got := join("a-1", "a-2", "a-3", "a-4"); got != first {
+		t.Fatalf("party of four went to %s with four seats open in %s", got, first)
+	}
+	// One seat is left; the next party must not be split over it.
+	if got := join("b-1", "b-2", "b-3", "b-4"); got == first {
+		t.Fatalf("party of four given the last seat of %s", first)
+	}
+	if got := nk.matches[first].state.(*MatchState).Reservations.Count(); got != 7 {
+		t.Errorf("%s holds %d seats, want 7", first, got)
+	}
+}
+
+func TestNewMatchLabelCountsParty(t *testing.T) {
+	nk := &fakeNakama{matches: make(map[string]*fakeMatch), indexLag: time.Hour}
+	_, label, err := joinDynamicMatch(context.Background(), nopLogger{}, nk, []string{"c-1", "c-2", "c-3"}, 1000, ratingWindow{Base: 200}, 0, modeMovement, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if label.Players != 3 || label.Open != 5 || !label.underfilled() {
+		t.Errorf("label %+v, want 3 players and 5 open seats", label)
+	}
+}
+
+func TestRatingWindowWidens(t *testing.T) {
+	w := ratingWindow{Base: 200, PerSecond: 10, Exponent: 1, Max: 800}
+	for _, tc := range []struct {
+		waited time.Duration
+		want   int32
+	}{
+		{0, 200},
+		{30 * time.Second, 500},
+		{time.Hour, 800},
+	} {
+		if got := w.At(tc.waited); got != tc.want {
+			t.Errorf("window after %v = %d, want %d", tc.waited, got, tc.want)
+		}
+	}
+}
+
+func TestMatchBandWidensWhileWaiting(t *testing.T) {
+	now := time.Now()
+	settings := matchSettings{
+		MinElo:    800,
+		MaxElo:    1200,
+		CreatedAt: now.Add(-20 * time.Second).Unix(),
+		Widen:     &ratingWindow{Base: 200, PerSecond: 10, Exponent: 1, Max: 800},
+		Centre:    1000,
+		Waited:    10 * time.Second,
+	}
+	if !settings.widenBand(now) || settings.MinElo != 500 || settings.MaxElo != 1500 {
+		t.Fatalf("band after 30s = %d..%d, want 500..1500", settings.MinElo, settings.MaxElo)
+	}
+	if settings.widenBand(now) {
+		t.Error("band changed with no more waiting")
+	}
+	if settings.widenBand(now.Add(time.Hour)); settings.MinElo != 200 || settings.MaxElo != 1800 {
+		t.Errorf("band after an hour = %d..%d, want the widest 200..1800", settings.MinElo, settings.MaxElo)
+	}
+
+	fixed := matchSettings{MinElo: 800, MaxElo: 1200, CreatedAt: now.Add(-time.Hour).Unix()}
+	if fixed.widenBand(now) {
+		t.Error("a band created fixed widened")
+	}
+
+	// A band floored at zero still widens around the rating it was made for.
+	low := matchSettings{MinElo: 0, MaxElo: 250, Centre: 50, CreatedAt: now.Unix(), Widen: settings.Widen}
+	if low.widenBand(now) {
+		t.Errorf("floored band widened with no waiting to %d..%d", low.MinElo, low.MaxElo)
+	}
+}
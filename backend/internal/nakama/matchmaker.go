@@ -0,0 +1,288 @@
+package nakama
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+
+	"github.com/delta/terrabound/backend/internal/rating"
+)
+
+// Matchmaker ticket properties. Clients add them to socket.AddMatchmaker
+// alongside a query such as
+//
+//	+properties.mode:strategy +properties.region:eu properties.rating:>=800 properties.rating:<=1200
+//
+// mode, region and party are string properties; rating, teams and partySize
+// numeric. teams is how many sides the match is played in, 0 for
+// free-for-all. tb_party_queue hands party members their properties.
+//
+// rating only narrows the query. Clients set it themselves, so matches are
+// checked and teams balanced on each player's rating read from their account.
+const (
+	ticketMode      = "mode"
+	ticketRegion    = "region"
+	ticketRating    = "rating"
+	ticketParty     = "party"
+	ticketPartySize = "partySize"
+	ticketTeams     = "teams"
+)
+
+// matchmakerJoinSeconds is how long matched players' seats are held for them.
+const matchmakerJoinSeconds = 30
+
+// matchmakerBandMargin widens a matched match's rating band past its players,
+// so late joiners near them can still fill a dropped seat.
+const matchmakerBandMargin = 200
+
+// matchmakerTicket is one matchmaker entry with its properties read.
+type matchmakerTicket struct {
+	Ticket   string
+	UserID   string
+	Username string
+	Mode     string
+	Region   string
+	Rating   float64
+	Party    string
+	// PartySize is how many tickets Party queued, 0 if the ticket does not say.
+	// Nakama's own party tickets are always matched together anyway.
+	PartySize int
+	Teams     int
+}
+
+// ticketFromEntry reads e, taking the player's rating from ratings rather
+// than from what their client put on the ticket.
+func ticketFromEntry(e runtime.MatchmakerEntry, ratings map[string]float64) matchmakerTicket {
+	props := e.GetProperties()
+	t := matchmakerTicket{
+		Ticket:    e.GetTicket(),
+		UserID:    e.GetPresence().GetUserId(),
+		Username:  e.GetPresence().GetUsername(),
+		Mode:      paramString(props, ticketMode, modeMovement),
+		Region:    paramString(props, ticketRegion, ""),
+		Rating:    rating.DefaultRating,
+		Party:     e.GetPartyId(),
+		PartySize: paramInt(props, ticketPartySize, 0),
+		Teams:     paramInt(props, ticketTeams, 0),
+	}
+	if r, ok := ratings[t.UserID]; ok {
+		t.Rating = r
+	}
+	if t.Party == "" {
+		t.Party = paramString(props, ticketParty, "")
+	}
+	return t
+}
+
+func ticketsFromEntries(entries []runtime.MatchmakerEntry, ratings map[string]float64) []matchmakerTicket {
+	tickets := make([]matchmakerTicket, len(entries))
+	for i, e := range entries {
+		tickets[i] = ticketFromEntry(e, ratings)
+	}
+	return tickets
+}
+
+// entryRatings reads the rating of every player in the given matchmaker
+// entries in one lookup.
+func entryRatings(ctx context.Context, nk runtime.NakamaModule, entries ...[]runtime.MatchmakerEntry) (map[string]float64, error) {
+	seen := make(map[string]bool)
+	var userIDs []string
+	for _, es := range entries {
+		for _, e := range es {
+			if id := e.GetPresence().GetUserId(); !seen[id] {
+				seen[id] = true
+				userIDs = append(userIDs, id)
+			}
+		}
+	}
+	if len(userIDs) == 0 {
+		return nil, nil
+	}
+	return readPlayerRatings(ctx, nk, userIDs)
+}
+
+// compatibleTickets reports whether tickets can share a match: same mode,
+// region and team count, for a mode we host, with every party there whole.
+func compatibleTickets(tickets []matchmakerTicket) bool {
+	if len(tickets) == 0 {
+		return false
+	}
+	first := tickets[0]
+	if _, ok := matchModules[first.Mode]; !ok || !validRegion(first.Region) {
+		return false
+	}
+	parties := make(map[string]int)
+	for _, t := range tickets {
+		if t.Mode != first.Mode || t.Region != first.Region || t.Teams != first.Teams {
+			return false
+		}
+		if t.Party != "" {
+			parties[t.Party]++
+		}
+	}
+	for _, t := range tickets {
+		if t.PartySize > 0 && parties[t.Party] != t.PartySize {
+			return false
+		}
+	}
+	return true
+}
+
+// balanceTeams splits tickets into teams sides of as even total rating as it
+// can, never splitting a party. With fewer than two teams every party is a
+// side of its own, as in a free-for-all.
+func balanceTeams(tickets []matchmakerTicket, teams int) [][]matchmakerTicket {
+	var units [][]matchmakerTicket
+	byParty := make(map[string]int)
+	for _, t := range tickets {
+		if i, ok := byParty[t.Party]; ok && t.Party != "" {
+			units[i] = append(units[i], t)
+			continue
+		}
+		byParty[t.Party] = len(units)
+		units = append(units, []matchmakerTicket{t})
+	}
+	if teams < 2 {
+		return units
+	}
+
+	// Strongest units first, each onto the weakest side with room for it.
+	sort.SliceStable(units, func(i, j int) bool { return totalRating(units[i]) > totalRating(units[j]) })
+	capacity := (len(tickets) + teams - 1) / teams
+	sides := make([][]matchmakerTicket, teams)
+	for _, unit := range units {
+		best := -1
+		for i, side := range sides {
+			if len(side)+len(unit) > capacity {
+				continue
+			}
+			if best < 0 || totalRating(side) < totalRating(sides[best]) {
+				best = i
+			}
+		}
+		if best < 0 {
+			// The party is bigger than any side has room for; keep it together on the smallest.
+			best = 0
+			for i, side := range sides {
+				if len(side) < len(sides[best]) {
+					best = i
+				}
+			}
+		}
+		sides[best] = append(sides[best], unit...)
+	}
+	return sides
+}
+
+func totalRating(tickets []matchmakerTicket) float64 {
+	var total float64
+	for _, t := range tickets {
+		total += t.Rating
+	}
+	return total
+}
+
+// ratingImbalance is the gap between the strongest and weakest side's mean rating.
+func ratingImbalance(sides [][]matchmakerTicket) float64 {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, side := range sides {
+		if len(side) == 0 {
+			continue
+		}
+		mean := totalRating(side) / float64(len(side))
+		lo, hi = math.Min(lo, mean), math.Max(hi, mean)
+	}
+	if hi < lo {
+		return 0
+	}
+	return hi - lo
+}
+
+// matchmakerOverride picks, from the matches the matchmaker proposes, those
+// that can be balanced best. Candidates mixing modes, regions or team counts,
+// or splitting a party, are dropped, and no ticket is used twice.
+func matchmakerOverride(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, candidates [][]runtime.MatchmakerEntry) [][]runtime.MatchmakerEntry {
+	type scored struct {
+		entries   []runtime.MatchmakerEntry
+		tickets   []matchmakerTicket
+		imbalance float64
+	}
+	ratings, err := entryRatings(ctx, nk, candidates...)
+	if err != nil {
+		// Nothing is matched this round; the tickets stay in the pool.
+		logger.Error("Failed to read matchmaker ratings: %v", err)
+		return nil
+	}
+	var viable []scored
+	for _, entries := range candidates {
+		tickets := ticketsFromEntries(entries, ratings)
+		if !compatibleTickets(tickets) {
+			continue
+		}
+		viable = append(viable, scored{entries, tickets, ratingImbalance(balanceTeams(tickets, tickets[0].Teams))})
+	}
+	sort.SliceStable(viable, func(i, j int) bool { return viable[i].imbalance < viable[j].imbalance })
+
+	used := make(map[string]bool)
+	var matches [][]runtime.MatchmakerEntry
+next:
+	for _, c := range viable {
+		for _, t := range c.tickets {
+			if used[t.Ticket] {
+				continue next
+			}
+		}
+		for _, t := range c.tickets {
+			used[t.Ticket] = true
+		}
+		matches = append(matches, c.entries)
+	}
+	logger.Debug("Matchmaker override kept %d of %d candidate matches", len(matches), len(candidates))
+	return matches
+}
+
+// matchmakerMatched creates the authoritative match for a set of matched
+// tickets: one seat for each player, all held for them, on balanced teams.
+func matchmakerMatched(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, entries []runtime.MatchmakerEntry) (string, error) {
+	ratings, err := entryRatings(ctx, nk, entries)
+	if err != nil {
+		logger.Error("Failed to read matched players' ratings: %v", err)
+		return "", err
+	}
+	tickets := ticketsFromEntries(entries, ratings)
+	if !compatibleTickets(tickets) {
+		return "", fmt.Errorf("matched tickets do not agree on mode, region and teams or split a party")
+	}
+	mode := tickets[0].Mode
+
+	lo, hi := math.Inf(1), math.Inf(-1)
+	expected := make([]string, len(tickets))
+	for i, t := range tickets {
+		lo, hi = math.Min(lo, t.Rating), math.Max(hi, t.Rating)
+		expected[i] = t.UserID
+	}
+	params := matchCreateParams(mode, tickets[0].Region, int32(lo)-matchmakerBandMargin, int32(hi)+matchmakerBandMargin, int32(len(tickets)))
+	params[reservedForParam] = expected
+	params["reservationSeconds"] = matchmakerJoinSeconds
+	if teams := tickets[0].Teams; teams >= 2 {
+		assignment := make(map[string]string, len(tickets))
+		for i, side := range balanceTeams(tickets, teams) {
+			for _, t := range side {
+				assignment[t.UserID] = fmt.Sprintf("team-%d", i+1)
+			}
+		}
+		params["teams"] = assignment
+	}
+
+	matchID, err := nk.MatchCreate(ctx, matchModules[mode], params)
+	if err != nil {
+		logger.Error("Failed to create match for matched players %v: %v", expected, err)
+		return "", err
+	}
+	logger.Info("Matchmaker created %s match %s for %v", mode, matchID, expected)
+	return matchID, nil
+}
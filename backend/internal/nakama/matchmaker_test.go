@@ -0,0 +1,46 @@
+package nakama
+
+import (
+	"context"
+	"testing"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+type fakeEntry struct {
+	presence fakePresence
+	props    map[string]interface{}
+}
+
+func (e fakeEntry) GetPresence() runtime.Presence         { return e.presence }
+func (e fakeEntry) GetTicket() string                     { return "ticket-" + e.presence.userID }
+func (e fakeEntry) GetProperties() map[string]interface{} { return e.props }
+func (e fakeEntry) GetPartyId() string                    { return "" }
+func (e fakeEntry) GetCreateTime() int64                  { return 0 }
+
+func TestMatchmakerIgnoresTicketRating(t *testing.T) {
+	nk := &fakeNakama{metadata: map[string]string{
+		"strong": `{"elo": 1900}`,
+		"weak":   `{"elo": 900}`,
+	}}
+	entry := func(userID string, claimed float64) runtime.MatchmakerEntry {
+		return fakeEntry{fakePresence{userID, "s-" + userID}, map[string]interface{}{
+			ticketMode:   modeStrategy,
+			ticketRating: claimed,
+		}}
+	}
+	// Both claim the same rating; the strong player's account says otherwise.
+	entries := []runtime.MatchmakerEntry{entry("strong", 1000), entry("weak", 1000)}
+
+	ratings, err := entryRatings(context.Background(), nk, entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tickets := ticketsFromEntries(entries, ratings)
+	if tickets[0].Rating != 1900 || tickets[1].Rating != 900 {
+		t.Fatalf("ticket ratings = %v, %v; want the account ratings 1900, 900", tickets[0].Rating, tickets[1].Rating)
+	}
+	if got := ratingImbalance(balanceTeams(tickets, 2)); got != 1000 {
+		t.Fatalf("imbalance = %v, want 1000", got)
+	}
+}
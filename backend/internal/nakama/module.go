@@ -6,6 +6,8 @@ import (
 	"net/http"
 
 	"github.com/heroiclabs/nakama-common/runtime"
+
+	"github.com/delta/terrabound/backend/internal/game/maps"
 )
 
 func InitModule(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, initializer runtime.Initializer) error {
@@ -41,12 +43,68 @@ func InitModule(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runti
 		return err
 	}
 
+	mapRegistry, err := maps.Load()
+	if err != nil {
+		logger.Error("Failed to load maps: %v", err)
+		return err
+	}
+	logger.Info("Loaded maps: %v", mapRegistry.IDs())
+
+	if err := initializer.RegisterMatch("strategy_match", func(
+		ctx context.Context,
+		logger runtime.Logger,
+		db *sql.DB,
+		nk runtime.NakamaModule,
+	) (runtime.Match, error) {
+		return &StrategyMatch{maps: mapRegistry}, nil
+	}); err != nil {
+		logger.Error("Failed to register strategy_match: %v", err)
+		return err
+	}
+
 	if err := initializer.RegisterRpc("dynamic_match", func(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
 		return requestDynamicMatch(ctx, logger, db, nk, payload)
 	}); err != nil {
 		return err
 	}
-	
+
+	if err := initializer.RegisterRpc("tb_order_validate", validateOrderRPC); err != nil {
+		return err
+	}
+
+	if err := initializer.RegisterRpc("tb_admin_match", adminMatchRPC); err != nil {
+		return err
+	}
+
+	if err := initializer.RegisterRpc("tb_party_create", partyCreateRPC); err != nil {
+		return err
+	}
+
+	if err := initializer.RegisterRpc("tb_party_invite", partyInviteRPC); err != nil {
+		return err
+	}
+
+	if err := initializer.RegisterRpc("tb_party_accept", partyAcceptRPC); err != nil {
+		return err
+	}
+
+	if err := initializer.RegisterRpc("tb_party_leave", partyLeaveRPC); err != nil {
+		return err
+	}
+
+	if err := initializer.RegisterRpc("tb_party_queue", partyQueueRPC); err != nil {
+		return err
+	}
+
+	// Queued play goes through Nakama's matchmaker; dynamic_match stays as quick join.
+	if err := initializer.RegisterMatchmakerOverride(matchmakerOverride); err != nil {
+		return err
+	}
+
+	if err := initializer.RegisterMatchmakerMatched(matchmakerMatched); err != nil {
+		return err
+	}
+
 	logger.Info("=== Backend Ready - Waiting for Unity clients ===")
 
 	return nil
@@ -0,0 +1,69 @@
+package nakama
+
+// Every opcode any match sends or accepts is defined here, so the numbering
+// stays unique across match types and in step with the Unity client.
+
+// General opcodes, mirroring MatchOpcodes in the client's MessageTypes.cs.
+const (
+	opCodePlayerMove    int64 = 1 // player_move: movement intent, client -> server
+	opCodePlayerAction  int64 = 2 // player_action: reserved for in-match actions
+	opCodeGameStateSync int64 = 3 // game_state_sync: full snapshot, server -> client
+	opCodePlayerJoin    int64 = 4 // player_join: reserved; presence events carry joins
+	opCodePlayerLeave   int64 = 5 // player_leave: reserved; presence events carry leaves
+	opCodeWelcome       int64 = 6 // welcome: negotiated protocol, server -> client on join
+	opCodeShutdown      int64 = 7 // match_shutdown: the match is stopping, server -> client
+
+	// opCodeError tells a client its message was not understood.
+	opCodeError int64 = 9
+)
+
+// Strategy match opcodes. Client -> server first, then server -> client.
+const (
+	opCodeSubmitOrders int64 = 10
+	opCodeLockIn       int64 = 11
+
+	opCodeOrderResult int64 = 12
+	opCodePhaseChange int64 = 13
+	opCodeTurnResult  int64 = 14
+	opCodeWorldState  int64 = 15
+
+	opCodeConcede     int64 = 16
+	opCodeMatchResult int64 = 17
+)
+
+// Delta state sync opcodes. A client opts in by sending its first ack; until
+// then it keeps receiving full snapshots on opCodeGameStateSync.
+const (
+	opCodeStateAck   int64 = 18
+	opCodeStateDelta int64 = 19
+)
+
+// Operator notices, server -> client. See the tb_admin_match RPC.
+const (
+	opCodeServerMessage int64 = 20
+	opCodeMatchPaused   int64 = 21
+)
+
+// opCodeNames is used when logging.
+var opCodeNames = map[int64]string{
+	opCodePlayerMove:    "player_move",
+	opCodePlayerAction:  "player_action",
+	opCodeGameStateSync: "game_state_sync",
+	opCodePlayerJoin:    "player_join",
+	opCodePlayerLeave:   "player_leave",
+	opCodeWelcome:       "welcome",
+	opCodeShutdown:      "match_shutdown",
+	opCodeError:         "error",
+	opCodeSubmitOrders:  "submit_orders",
+	opCodeLockIn:        "lock_in",
+	opCodeOrderResult:   "order_result",
+	opCodePhaseChange:   "phase_change",
+	opCodeTurnResult:    "turn_result",
+	opCodeWorldState:    "world_state",
+	opCodeConcede:       "concede",
+	opCodeMatchResult:   "match_result",
+	opCodeStateAck:      "state_ack",
+	opCodeStateDelta:    "state_delta",
+	opCodeServerMessage: "server_message",
+	opCodeMatchPaused:   "match_paused",
+}
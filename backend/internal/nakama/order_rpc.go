@@ -0,0 +1,108 @@
+package nakama
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+
+	"github.com/delta/terrabound/backend/internal/constants"
+	"github.com/delta/terrabound/backend/internal/game"
+)
+
+// debugMatchID validates against game.SampleWorld instead of a live match.
+const debugMatchID = "debug"
+
+type orderValidateRequest struct {
+	MatchId  string        `json:"matchId"`
+	PlayerId string        `json:"playerId"`
+	Action   string        `json:"action"`
+	From     string        `json:"from,omitempty"`
+	Target   string        `json:"target"`
+	Units    int           `json:"units"`
+	Building game.Building `json:"building,omitempty"`
+}
+
+type orderValidateResponse struct {
+	Valid      bool             `json:"valid"`
+	Order      game.Order       `json:"order"`
+	Violations []game.Violation `json:"violations"`
+}
+
+// validateOrderRPC lets clients pre-check an order before committing it to a match.
+func validateOrderRPC(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	var req orderValidateRequest
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		return "", constants.ErrUnmarshalRequest
+	}
+	req.MatchId = strings.TrimSpace(req.MatchId)
+	if req.MatchId == "" || req.Target == "" {
+		return "", constants.ErrMissingParameter
+	}
+
+	// Session callers may only validate their own orders; server-to-server
+	// calls (http_key) name the player explicitly.
+	userID, _ := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	switch {
+	case userID != "" && req.PlayerId == "":
+		req.PlayerId = userID
+	case userID != "" && req.PlayerId != userID:
+		return "", constants.ErrNotAllowed
+	case req.PlayerId == "":
+		return "", constants.ErrMissingParameter
+	}
+
+	world, err := worldForMatch(ctx, nk, req.MatchId, game.PlayerID(req.PlayerId))
+	if err != nil {
+		return "", err
+	}
+
+	order, violations := game.ValidateOrder(world, game.Order{
+		PlayerID: game.PlayerID(req.PlayerId),
+		Action:   game.Action(strings.ToLower(strings.TrimSpace(req.Action))),
+		From:     game.RegionID(req.From),
+		Target:   game.RegionID(req.Target),
+		Units:    req.Units,
+		Building: game.Building(strings.ToLower(strings.TrimSpace(string(req.Building)))),
+	})
+	if violations == nil {
+		violations = []game.Violation{}
+	}
+
+	out, err := json.Marshal(orderValidateResponse{
+		Valid:      len(violations) == 0,
+		Order:      order,
+		Violations: violations,
+	})
+	if err != nil {
+		logger.Error("order validate: marshal failed: %v", err)
+		return "", constants.ErrMarshalResponse
+	}
+	return string(out), nil
+}
+
+// worldForMatch resolves the world an order should be checked against: the
+// board as player sees it, so validation gives nothing away that fog hides.
+func worldForMatch(ctx context.Context, nk runtime.NakamaModule, matchID string, player game.PlayerID) (*game.World, error) {
+	if matchID == debugMatchID {
+		return game.ViewFor(game.SampleWorld(), player), nil
+	}
+
+	sig, _ := json.Marshal(matchSignal{Op: signalOpWorld, UserID: string(player)})
+	out, err := nk.MatchSignal(ctx, matchID, string(sig))
+	if err != nil {
+		return nil, constants.ErrNotFound
+	}
+	// Match types without a territory world answer with an empty string.
+	if out == "" {
+		return nil, constants.ErrFailedPrecondition
+	}
+
+	var world game.World
+	if err := json.Unmarshal([]byte(out), &world); err != nil {
+		return nil, constants.ErrInternalError
+	}
+	return &world, nil
+}
@@ -0,0 +1,37 @@
+package nakama
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/delta/terrabound/backend/internal/game"
+)
+
+func TestOrderValidateBuild(t *testing.T) {
+	for _, tc := range []struct {
+		payload string
+		want    []string
+	}{
+		{`{"matchId":"debug","playerId":"user-1","action":"build","target":"capital-1","building":"market"}`, nil},
+		{`{"matchId":"debug","playerId":"user-1","action":"build","target":"capital-1","building":"moat"}`, []string{game.ViolationUnknownBuilding}},
+		{`{"matchId":"debug","playerId":"user-1","action":"build","target":"capital-1"}`, []string{game.ViolationUnknownBuilding}},
+	} {
+		out, err := validateOrderRPC(context.Background(), nopLogger{}, nil, &fakeNakama{}, tc.payload)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.payload, err)
+		}
+		var resp orderValidateResponse
+		if err := json.Unmarshal([]byte(out), &resp); err != nil {
+			t.Fatal(err)
+		}
+		var codes []string
+		for _, v := range resp.Violations {
+			codes = append(codes, v.Code)
+		}
+		if strings.Join(codes, ",") != strings.Join(tc.want, ",") {
+			t.Errorf("%s: violations %v, want %v", tc.payload, codes, tc.want)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package nakama
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"strconv"
+)
+
+// paramInt reads an integer match param. Params created from Go code keep their
+// native type while ones that went through JSON arrive as float64.
+func paramInt(params map[string]interface{}, key string, def int) int {
+	switch v := params[key].(type) {
+	case int:
+		return v
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	default:
+		return def
+	}
+}
+
+// paramFloat reads a numeric match param as a float32.
+func paramFloat(params map[string]interface{}, key string, def float32) float32 {
+	switch v := params[key].(type) {
+	case float32:
+		return v
+	case float64:
+		return float32(v)
+	case int:
+		return float32(v)
+	case int32:
+		return float32(v)
+	case int64:
+		return float32(v)
+	default:
+		return def
+	}
+}
+
+// paramSeed reads the "seed" param. Seeds above 2^53 do not survive JSON
+// numbers, so a decimal string is accepted as well. When no seed is given a
+// random one is drawn; either way the result is what the match must record.
+func paramSeed(params map[string]interface{}) uint64 {
+	switch v := params["seed"].(type) {
+	case uint64:
+		return v
+	case int:
+		return uint64(v)
+	case int64:
+		return uint64(v)
+	case float64:
+		return uint64(v)
+	case string:
+		if seed, err := strconv.ParseUint(v, 10, 64); err == nil {
+			return seed
+		}
+	}
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return binary.LittleEndian.Uint64(b[:])
+}
+
+// paramBool reads a boolean match param.
+func paramBool(params map[string]interface{}, key string, def bool) bool {
+	if v, ok := params[key].(bool); ok {
+		return v
+	}
+	return def
+}
+
+// paramString reads a string match param, falling back to def when missing or empty.
+func paramString(params map[string]interface{}, key string, def string) string {
+	if v, ok := params[key].(string); ok && v != "" {
+		return v
+	}
+	return def
+}
+
+// paramStrings reads a list of strings, which arrives as []interface{} once it
+// has been through JSON. A single string is read as a list of one.
+func paramStrings(params map[string]interface{}, key string) []string {
+	switch v := params[key].(type) {
+	case string:
+		if v != "" {
+			return []string{v}
+		}
+	case []string:
+		return v
+	case []interface{}:
+		var out []string
+		for _, s := range v {
+			if s, ok := s.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// paramStringMap reads a map of string values.
+func paramStringMap(params map[string]interface{}, key string) map[string]string {
+	switch v := params[key].(type) {
+	case map[string]string:
+		return v
+	case map[string]interface{}:
+		out := make(map[string]string, len(v))
+		for k, s := range v {
+			if s, ok := s.(string); ok {
+				out[k] = s
+			}
+		}
+		return out
+	}
+	return nil
+}
@@ -0,0 +1,463 @@
+package nakama
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+
+	"github.com/delta/terrabound/backend/internal/constants"
+)
+
+const (
+	// partyCollection holds one system-owned record per party, keyed by its id.
+	partyCollection = "parties"
+	// partyMembershipCollection holds, for each user in a party, which one.
+	partyMembershipCollection = "party_membership"
+	partyMembershipKey        = "current"
+	maxPartySize              = 4
+	// maxPartyInvites is how many invites a party keeps pending; sending
+	// another lets the oldest lapse.
+	maxPartyInvites = 8
+)
+
+// Notification codes sent to party members.
+const (
+	notifyPartyInvite = 100
+	// notifyPartyMatch carries the match the leader reserved the party seats in.
+	notifyPartyMatch = 101
+	// notifyPartyTicket carries the matchmaker ticket each member should add.
+	notifyPartyTicket = 102
+)
+
+// Runtime env keys for the party rating formula. A party is rated at
+//
+//	mean + maxWeight*(strongest-mean) + sizeBonus*(members-1)
+//
+// so a maxWeight of 0 rates it at its members' mean and 1 at its strongest.
+const (
+	envPartyMaxWeight = "TB_PARTY_RATING_MAX_WEIGHT"
+	envPartySizeBonus = "TB_PARTY_RATING_SIZE_BONUS"
+)
+
+var (
+	errInParty     = runtime.NewError("already in a party", constants.CodeAlreadyExists)
+	errNotInParty  = runtime.NewError("not in a party", constants.CodeFailedPrecondition)
+	errPartyFull   = runtime.NewError("party is full", constants.CodeResourceExhausted)
+	errPartyLeader = runtime.NewError("only the party leader may do that", constants.CodePermissionDenied)
+)
+
+// party is a group of players who queue together. Leader queues for all of them.
+type party struct {
+	Id        string   `json:"id"`
+	Leader    string   `json:"leader"`
+	Members   []string `json:"members"`
+	Invites   []string `json:"invites"`
+	CreatedAt int64    `json:"createdAt"`
+
+	// version guards the stored record against concurrent changes.
+	version string
+}
+
+type partyMembership struct {
+	PartyId string `json:"partyId"`
+}
+
+type partyRequest struct {
+	PartyId string `json:"partyId"`
+	UserId  string `json:"userId"`
+	// Queue only: where to play, and in how many teams; 0 plays
+	// free-for-all through dynamic matches, 2 or more goes through the
+	// matchmaker so the party can share a side.
+	Mode   string `json:"mode"`
+	Region string `json:"region"`
+	Teams  int    `json:"teams"`
+}
+
+// partyTicket is what every member passes to socket.AddMatchmaker.
+type partyTicket struct {
+	Query             string             `json:"query"`
+	StringProperties  map[string]string  `json:"stringProperties"`
+	NumericProperties map[string]float64 `json:"numericProperties"`
+}
+
+type partyQueueResponse struct {
+	PartyId string       `json:"partyId"`
+	Rating  int32        `json:"rating"`
+	Match   *rpcResponse `json:"match,omitempty"`
+	Ticket  *partyTicket `json:"ticket,omitempty"`
+}
+
+func sessionUser(ctx context.Context) (string, error) {
+	userID, _ := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if userID == "" {
+		return "", constants.ErrUserMissing
+	}
+	return userID, nil
+}
+
+func parsePartyRequest(payload string) (partyRequest, error) {
+	var req partyRequest
+	if payload == "" {
+		return req, nil
+	}
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		return req, constants.ErrUnmarshalRequest
+	}
+	req.PartyId = strings.TrimSpace(req.PartyId)
+	req.UserId = strings.TrimSpace(req.UserId)
+	return req, nil
+}
+
+func newPartyID() string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func readParty(ctx context.Context, nk runtime.NakamaModule, partyID string) (*party, error) {
+	objs, err := nk.StorageRead(ctx, []*runtime.StorageRead{{Collection: partyCollection, Key: partyID, UserID: ""}})
+	if err != nil {
+		return nil, constants.ErrStorageReadFailed
+	}
+	if len(objs) == 0 {
+		return nil, constants.ErrNotFound
+	}
+	var p party
+	if err := json.Unmarshal([]byte(objs[0].GetValue()), &p); err != nil {
+		return nil, constants.ErrStorageReadFailed
+	}
+	p.version = objs[0].GetVersion()
+	return &p, nil
+}
+
+// currentParty returns the party userID is in, or nil.
+func currentParty(ctx context.Context, nk runtime.NakamaModule, userID string) (*party, error) {
+	objs, err := nk.StorageRead(ctx, []*runtime.StorageRead{{Collection: partyMembershipCollection, Key: partyMembershipKey, UserID: userID}})
+	if err != nil {
+		return nil, constants.ErrStorageReadFailed
+	}
+	if len(objs) == 0 {
+		return nil, nil
+	}
+	var m partyMembership
+	if err := json.Unmarshal([]byte(objs[0].GetValue()), &m); err != nil {
+		return nil, constants.ErrStorageReadFailed
+	}
+	p, err := readParty(ctx, nk, m.PartyId)
+	if err == constants.ErrNotFound {
+		return nil, nil
+	}
+	return p, err
+}
+
+// partyWrite stores p only if nobody changed it since it was read.
+func partyWrite(p *party) *runtime.StorageWrite {
+	value, _ := json.Marshal(p)
+	version := p.version
+	if version == "" {
+		version = "*"
+	}
+	return &runtime.StorageWrite{
+		Collection:      partyCollection,
+		Key:             p.Id,
+		UserID:          "",
+		Value:           string(value),
+		Version:         version,
+		PermissionRead:  0,
+		PermissionWrite: 0,
+	}
+}
+
+// membershipWrite puts userID in partyID, failing if they are already in one.
+func membershipWrite(userID, partyID string) *runtime.StorageWrite {
+	value, _ := json.Marshal(partyMembership{PartyId: partyID})
+	return &runtime.StorageWrite{
+		Collection:      partyMembershipCollection,
+		Key:             partyMembershipKey,
+		UserID:          userID,
+		Value:           string(value),
+		Version:         "*",
+		PermissionRead:  1,
+		PermissionWrite: 0,
+	}
+}
+
+func partyReply(p *party) (string, error) {
+	out, err := json.Marshal(p)
+	if err != nil {
+		return "", constants.ErrMarshalResponse
+	}
+	return string(out), nil
+}
+
+// partyCreateRPC starts a party led by the caller.
+func partyCreateRPC(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, err := sessionUser(ctx)
+	if err != nil {
+		return "", err
+	}
+	if current, err := currentParty(ctx, nk, userID); err != nil {
+		return "", err
+	} else if current != nil {
+		return "", errInParty
+	}
+
+	p := &party{Id: newPartyID(), Leader: userID, Members: []string{userID}, Invites: []string{}, CreatedAt: time.Now().Unix()}
+	if _, err := nk.StorageWrite(ctx, []*runtime.StorageWrite{partyWrite(p), membershipWrite(userID, p.Id)}); err != nil {
+		// Most likely a party was made for them in the meantime.
+		logger.Warn("Failed to create party for %s: %v", userID, err)
+		return "", constants.ErrAborted
+	}
+	logger.Info("Party %s created by %s", p.Id, userID)
+	return partyReply(p)
+}
+
+// partyInviteRPC lets the leader invite UserId, who is sent a notification.
+func partyInviteRPC(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, err := sessionUser(ctx)
+	if err != nil {
+		return "", err
+	}
+	req, err := parsePartyRequest(payload)
+	if err != nil {
+		return "", err
+	}
+	if req.UserId == "" || req.UserId == userID {
+		return "", constants.ErrMissingParameter
+	}
+	p, err := currentParty(ctx, nk, userID)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case p == nil:
+		return "", errNotInParty
+	case p.Leader != userID:
+		return "", errPartyLeader
+	case len(p.Members) >= maxPartySize:
+		return "", errPartyFull
+	case slices.Contains(p.Members, req.UserId):
+		return "", errInParty
+	}
+
+	// Ids that are not user ids at all fail the lookup too.
+	if users, err := nk.UsersGetId(ctx, []string{req.UserId}, nil); err != nil || len(users) == 0 {
+		return "", constants.ErrNotFound
+	}
+
+	if !slices.Contains(p.Invites, req.UserId) {
+		p.Invites = append(p.Invites, req.UserId)
+		if len(p.Invites) > maxPartyInvites {
+			p.Invites = slices.Delete(p.Invites, 0, len(p.Invites)-maxPartyInvites)
+		}
+		if _, err := nk.StorageWrite(ctx, []*runtime.StorageWrite{partyWrite(p)}); err != nil {
+			return "", constants.ErrAborted
+		}
+	}
+	if err := nk.NotificationSend(ctx, req.UserId, "Party invite", map[string]interface{}{"partyId": p.Id, "leader": userID}, notifyPartyInvite, userID, true); err != nil {
+		logger.Warn("Failed to notify %s of invite to party %s: %v", req.UserId, p.Id, err)
+	}
+	return partyReply(p)
+}
+
+// partyAcceptRPC joins the caller to PartyId, which must have invited them.
+func partyAcceptRPC(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, err := sessionUser(ctx)
+	if err != nil {
+		return "", err
+	}
+	req, err := parsePartyRequest(payload)
+	if err != nil {
+		return "", err
+	}
+	if req.PartyId == "" {
+		return "", constants.ErrMissingParameter
+	}
+	p, err := readParty(ctx, nk, req.PartyId)
+	if err != nil {
+		return "", err
+	}
+	if !slices.Contains(p.Invites, userID) {
+		return "", constants.ErrNotAllowed
+	}
+	if len(p.Members) >= maxPartySize {
+		return "", errPartyFull
+	}
+
+	p.Invites = slices.DeleteFunc(p.Invites, func(id string) bool { return id == userID })
+	p.Members = append(p.Members, userID)
+	// Both or neither: the membership write fails if they joined another party.
+	if _, err := nk.StorageWrite(ctx, []*runtime.StorageWrite{partyWrite(p), membershipWrite(userID, p.Id)}); err != nil {
+		if current, _ := currentParty(ctx, nk, userID); current != nil {
+			return "", errInParty
+		}
+		return "", constants.ErrAborted
+	}
+	logger.Info("%s joined party %s", userID, p.Id)
+	return partyReply(p)
+}
+
+// partyLeaveRPC takes the caller out of their party. The longest-standing
+// member left takes over as leader; the last one out disbands it.
+func partyLeaveRPC(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, err := sessionUser(ctx)
+	if err != nil {
+		return "", err
+	}
+	p, err := currentParty(ctx, nk, userID)
+	if err != nil {
+		return "", err
+	}
+	if p == nil {
+		return "", errNotInParty
+	}
+
+	deletes := []*runtime.StorageDelete{{Collection: partyMembershipCollection, Key: partyMembershipKey, UserID: userID}}
+	var writes []*runtime.StorageWrite
+	p.Members = slices.DeleteFunc(p.Members, func(id string) bool { return id == userID })
+	if len(p.Members) == 0 {
+		deletes = append(deletes, &runtime.StorageDelete{Collection: partyCollection, Key: p.Id, UserID: "", Version: p.version})
+	} else {
+		if p.Leader == userID {
+			p.Leader = p.Members[0]
+		}
+		writes = append(writes, partyWrite(p))
+	}
+	if _, _, err := nk.MultiUpdate(ctx, nil, writes, deletes, nil, false); err != nil {
+		return "", constants.ErrAborted
+	}
+	logger.Info("%s left party %s", userID, p.Id)
+	return "{}", nil
+}
+
+// partyQueueRPC is the leader queueing the whole party. Free-for-all play
+// reserves seats for everyone in one dynamic match; team play hands every
+// member the same matchmaker ticket, which the matchmaker hooks only ever
+// match whole and on one side.
+func partyQueueRPC(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, err := sessionUser(ctx)
+	if err != nil {
+		return "", err
+	}
+	req, err := parsePartyRequest(payload)
+	if err != nil {
+		return "", err
+	}
+	if req.Mode == "" {
+		req.Mode = modeMovement
+	}
+	if _, ok := matchModules[req.Mode]; !ok || !validRegion(req.Region) || req.Teams < 0 {
+		return "", constants.ErrBadInput
+	}
+	p, err := currentParty(ctx, nk, userID)
+	if err != nil {
+		return "", err
+	}
+	if p == nil {
+		return "", errNotInParty
+	}
+	if p.Leader != userID {
+		return "", errPartyLeader
+	}
+
+	ratings := make([]int32, len(p.Members))
+	for i, member := range p.Members {
+		if ratings[i], err = readPlayerElo(ctx, nk, member); err != nil {
+			logger.Warn("elo read failed for %s: %v", member, err)
+			ratings[i] = 1000
+		}
+	}
+	resp := partyQueueResponse{PartyId: p.Id, Rating: partyRating(ctx, ratings)}
+
+	subject, code := "Party match found", notifyPartyMatch
+	if req.Teams >= 2 {
+		resp.Ticket = newPartyTicket(p, resp.Rating, req.Mode, req.Region, req.Teams)
+		subject, code = "Party queued", notifyPartyTicket
+	} else {
+		matchID, label, err := joinDynamicMatch(ctx, logger, nk, p.Members, resp.Rating, ratingWindowFromEnv(ctx), 0, req.Mode, req.Region)
+		if err != nil {
+			return "", err
+		}
+		match := newRPCResponse(matchID, label)
+		resp.Match = &match
+	}
+
+	out, err := json.Marshal(resp)
+	if err != nil {
+		return "", constants.ErrMarshalResponse
+	}
+	var content map[string]interface{}
+	_ = json.Unmarshal(out, &content)
+	for _, member := range p.Members {
+		if member == userID {
+			continue
+		}
+		if err := nk.NotificationSend(ctx, member, subject, content, code, userID, false); err != nil {
+			logger.Warn("Failed to notify %s that party %s queued: %v", member, p.Id, err)
+		}
+	}
+	logger.Info("Party %s queued for %s by %s at rating %d", p.Id, req.Mode, userID, resp.Rating)
+	return string(out), nil
+}
+
+// partyRating aggregates member ratings with the formula configured in the
+// runtime env; by default halfway between the mean and the strongest.
+func partyRating(ctx context.Context, ratings []int32) int32 {
+	if len(ratings) == 0 {
+		return 1000
+	}
+	env, _ := ctx.Value(runtime.RUNTIME_CTX_ENV).(map[string]string)
+	maxWeight := envFloat(env, envPartyMaxWeight, 0.5)
+	sizeBonus := envFloat(env, envPartySizeBonus, 0)
+
+	var sum, strongest float64
+	for i, r := range ratings {
+		sum += float64(r)
+		if i == 0 || float64(r) > strongest {
+			strongest = float64(r)
+		}
+	}
+	mean := sum / float64(len(ratings))
+	return int32(math.Round(mean + maxWeight*(strongest-mean) + sizeBonus*float64(len(ratings)-1)))
+}
+
+func envFloat(env map[string]string, key string, def float64) float64 {
+	if v, err := strconv.ParseFloat(strings.TrimSpace(env[key]), 64); err == nil {
+		return v
+	}
+	return def
+}
+
+// newPartyTicket builds the matchmaker ticket every member of p queues with.
+// They all carry the party's rating, so the party is placed as one.
+func newPartyTicket(p *party, rating int32, mode, region string, teams int) *partyTicket {
+	lo, hi := ratingBand(rating, matchmakerBandMargin)
+	query := fmt.Sprintf("+properties.mode:%s +properties.teams:>=%d +properties.teams:<=%d properties.rating:>=%d properties.rating:<=%d",
+		mode, teams, teams, lo, hi)
+	if region != "" {
+		query += fmt.Sprintf(" +properties.region:%s", region)
+	}
+	return &partyTicket{
+		Query: query,
+		StringProperties: map[string]string{
+			ticketMode:   mode,
+			ticketRegion: region,
+			ticketParty:  p.Id,
+		},
+		NumericProperties: map[string]float64{
+			ticketRating:    float64(rating),
+			ticketTeams:     float64(teams),
+			ticketPartySize: float64(len(p.Members)),
+		},
+	}
+}
@@ -0,0 +1,152 @@
+package nakama
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+func asUser(userID string) context.Context {
+	return context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, userID)
+}
+
+// partyCall runs rpc as userID and decodes the party it returns.
+func partyCall(t *testing.T, nk *fakeNakama, rpc func(context.Context, runtime.Logger, *sql.DB, runtime.NakamaModule, string) (string, error), userID, payload string) (*party, error) {
+	t.Helper()
+	out, err := rpc(asUser(userID), nopLogger{}, nil, nk, payload)
+	if err != nil {
+		return nil, err
+	}
+	var p party
+	if err := json.Unmarshal([]byte(out), &p); err != nil {
+		t.Fatal(err)
+	}
+	return &p, nil
+}
+
+func TestPartyRating(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		env     map[string]string
+		ratings []int32
+		want    int32
+	}{
+		{"default halfway to the strongest", nil, []int32{1000, 1400}, 1300},
+		{"mean", map[string]string{envPartyMaxWeight: "0"}, []int32{1000, 1400}, 1200},
+		{"strongest plus size bonus", map[string]string{envPartyMaxWeight: "1", envPartySizeBonus: "25"}, []int32{900, 1000, 1500}, 1550},
+		{"unparsable env uses the default", map[string]string{envPartyMaxWeight: "lots"}, []int32{1000, 1400}, 1300},
+		{"solo", nil, []int32{1234}, 1234},
+		{"empty", nil, nil, 1000},
+	} {
+		ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_ENV, tc.env)
+		if got := partyRating(ctx, tc.ratings); got != tc.want {
+			t.Errorf("%s: rating %d, want %d", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestPartyInvite(t *testing.T) {
+	nk := &fakeNakama{missing: map[string]bool{"ghost": true}}
+	p, err := partyCall(t, nk, partyCreateRPC, "lead", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	invite := fmt.Sprintf(`{"partyId":%q,"userId":%%q}`, p.Id)
+
+	if _, err := partyCall(t, nk, partyInviteRPC, "lead", fmt.Sprintf(invite, "ghost")); err == nil {
+		t.Error("invited a user that does not exist")
+	}
+	for i := range maxPartyInvites + 1 {
+		if p, err = partyCall(t, nk, partyInviteRPC, "lead", fmt.Sprintf(invite, fmt.Sprintf("guest-%d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(p.Invites) != maxPartyInvites || p.Invites[0] != "guest-1" {
+		t.Errorf("invites %v, want the latest %d", p.Invites, maxPartyInvites)
+	}
+	if _, err := partyCall(t, nk, partyAcceptRPC, "guest-0", fmt.Sprintf(`{"partyId":%q}`, p.Id)); err == nil {
+		t.Error("accepted an invite that lapsed")
+	}
+	if len(nk.notified) != maxPartyInvites+1 {
+		t.Errorf("sent %d invite notifications, want %d", len(nk.notified), maxPartyInvites+1)
+	}
+}
+
+func TestPartyAcceptWhileInAnotherParty(t *testing.T) {
+	nk := &fakeNakama{}
+	first, _ := partyCall(t, nk, partyCreateRPC, "a", "")
+	if _, err := partyCall(t, nk, partyInviteRPC, "a", `{"userId":"b"}`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := partyCall(t, nk, partyCreateRPC, "b", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := partyCall(t, nk, partyAcceptRPC, "b", fmt.Sprintf(`{"partyId":%q}`, first.Id)); err != errInParty {
+		t.Fatalf("accept from inside another party: %v, want %v", err, errInParty)
+	}
+	// The membership write failing must leave the party as it was.
+	p, err := readParty(context.Background(), nk, first.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Members) != 1 || len(p.Invites) != 1 {
+		t.Errorf("party after the failed accept: members %v, invites %v", p.Members, p.Invites)
+	}
+}
+
+func TestPartyLeave(t *testing.T) {
+	nk := &fakeNakama{}
+	p, _ := partyCall(t, nk, partyCreateRPC, "a", "")
+	for _, id := range []string{"b", "c"} {
+		if _, err := partyCall(t, nk, partyInviteRPC, "a", fmt.Sprintf(`{"userId":%q}`, id)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := partyCall(t, nk, partyAcceptRPC, id, fmt.Sprintf(`{"partyId":%q}`, p.Id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	leave := func(userID string) {
+		t.Helper()
+		if _, err := partyLeaveRPC(asUser(userID), nopLogger{}, nil, nk, ""); err != nil {
+			t.Fatalf("%s leaving: %v", userID, err)
+		}
+		if current, _ := currentParty(context.Background(), nk, userID); current != nil {
+			t.Fatalf("%s still in party %s after leaving", userID, current.Id)
+		}
+	}
+
+	leave("a")
+	p, err := readParty(context.Background(), nk, p.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Leader != "b" || len(p.Members) != 2 {
+		t.Errorf("after the leader left: leader %s, members %v; want b to lead b and c", p.Leader, p.Members)
+	}
+	if _, err := partyCall(t, nk, partyInviteRPC, "c", `{"userId":"d"}`); err != errPartyLeader {
+		t.Errorf("invite from a member: %v, want %v", err, errPartyLeader)
+	}
+
+	leave("b")
+	leave("c")
+	if _, err := readParty(context.Background(), nk, p.Id); err == nil {
+		t.Error("party still stored after everyone left")
+	}
+}
+
+func TestNewPartyTicket(t *testing.T) {
+	p := &party{Id: "p1", Members: []string{"a", "b", "c"}}
+	ticket := newPartyTicket(p, 1300, modeStrategy, "eu", 2)
+	if want := "+properties.mode:strategy +properties.teams:>=2 +properties.teams:<=2 properties.rating:>=1100 properties.rating:<=1500 +properties.region:eu"; ticket.Query != want {
+		t.Errorf("query %q, want %q", ticket.Query, want)
+	}
+	if ticket.StringProperties[ticketParty] != "p1" || ticket.NumericProperties[ticketPartySize] != 3 || ticket.NumericProperties[ticketRating] != 1300 {
+		t.Errorf("properties %v %v", ticket.StringProperties, ticket.NumericProperties)
+	}
+}
@@ -0,0 +1,110 @@
+package nakama
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"time"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const (
+	// queueCollection holds each player's queue ticket under queueTicketKey.
+	queueCollection = "match_queue"
+	queueTicketKey  = "ticket"
+	// queueTicketSeconds is how long a ticket outlives the request that last
+	// used it. A player who asks again within it keeps their place in line.
+	queueTicketSeconds = 600
+)
+
+// Runtime env keys for the rating window curve. A player who has waited t
+// seconds is matched within
+//
+//	base + perSecond * t^exponent
+//
+// of their rating, up to max.
+const (
+	envWindowBase      = "TB_RATING_WINDOW_BASE"
+	envWindowPerSecond = "TB_RATING_WINDOW_PER_SECOND"
+	envWindowExponent  = "TB_RATING_WINDOW_EXPONENT"
+	envWindowMax       = "TB_RATING_WINDOW_MAX"
+)
+
+// queueTicket records when a player started looking for a match. It is kept
+// while they have only found empty matches, so the longer they go without
+// company the wider their search.
+type queueTicket struct {
+	Mode       string `json:"mode"`
+	Region     string `json:"region"`
+	EnqueuedAt int64  `json:"enqueuedAt"`
+	LastSeenAt int64  `json:"lastSeenAt"`
+}
+
+type ratingWindow struct {
+	Base      float64
+	PerSecond float64
+	Exponent  float64
+	Max       float64
+}
+
+func ratingWindowFromEnv(ctx context.Context) ratingWindow {
+	env, _ := ctx.Value(runtime.RUNTIME_CTX_ENV).(map[string]string)
+	return ratingWindow{
+		Base:      envFloat(env, envWindowBase, 200),
+		PerSecond: envFloat(env, envWindowPerSecond, 10),
+		Exponent:  envFloat(env, envWindowExponent, 1),
+		Max:       envFloat(env, envWindowMax, 800),
+	}
+}
+
+// At is how far from their rating a player who has waited that long may be matched.
+func (w ratingWindow) At(waited time.Duration) int32 {
+	t := math.Max(waited.Seconds(), 0)
+	return int32(math.Min(w.Base+w.PerSecond*math.Pow(t, w.Exponent), math.Max(w.Max, w.Base)))
+}
+
+// Widest is the window a player gets once the curve tops out.
+func (w ratingWindow) Widest() int32 {
+	return int32(math.Max(w.Max, w.Base))
+}
+
+// loadQueueTicket returns userID's ticket for mode and region, starting a
+// new one if they have none or it has gone stale, and marks it used now.
+func loadQueueTicket(ctx context.Context, nk runtime.NakamaModule, userID, mode, region string, now time.Time) (*queueTicket, error) {
+	objs, err := nk.StorageRead(ctx, []*runtime.StorageRead{{Collection: queueCollection, Key: queueTicketKey, UserID: userID}})
+	if err != nil {
+		return nil, err
+	}
+	var ticket queueTicket
+	if len(objs) == 0 || json.Unmarshal([]byte(objs[0].GetValue()), &ticket) != nil ||
+		ticket.Mode != mode || ticket.Region != region || now.Unix()-ticket.LastSeenAt > queueTicketSeconds {
+		ticket = queueTicket{Mode: mode, Region: region, EnqueuedAt: now.Unix()}
+	}
+	ticket.LastSeenAt = now.Unix()
+	return &ticket, nil
+}
+
+func (t *queueTicket) Waited(now time.Time) time.Duration {
+	return time.Duration(now.Unix()-t.EnqueuedAt) * time.Second
+}
+
+func writeQueueTicket(ctx context.Context, nk runtime.NakamaModule, userID string, ticket *queueTicket) error {
+	value, err := json.Marshal(ticket)
+	if err != nil {
+		return err
+	}
+	_, err = nk.StorageWrite(ctx, []*runtime.StorageWrite{{
+		Collection:      queueCollection,
+		Key:             queueTicketKey,
+		UserID:          userID,
+		Value:           string(value),
+		PermissionRead:  1,
+		PermissionWrite: 0,
+	}})
+	return err
+}
+
+func deleteQueueTicket(ctx context.Context, nk runtime.NakamaModule, userID string) error {
+	return nk.StorageDelete(ctx, []*runtime.StorageDelete{{Collection: queueCollection, Key: queueTicketKey, UserID: userID}})
+}
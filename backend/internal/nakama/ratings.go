@@ -0,0 +1,213 @@
+package nakama
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+
+	"github.com/delta/terrabound/backend/internal/rating"
+)
+
+// Account metadata keys holding a player's rating. elo is the rating itself,
+// kept under its old name so matchmaking reads it as before.
+const (
+	metaElo          = "elo"
+	metaDeviation    = "ratingDeviation"
+	metaVolatility   = "ratingVolatility"
+	metaRatedMatches = "ratedMatches"
+	metaProvisional  = "provisional"
+)
+
+// ratingHistoryCollection holds one record per player per rated match, keyed
+// by match id and owned by the player.
+const ratingHistoryCollection = "rating_history"
+
+// ratingGuardCollection holds one record per rated player whose version
+// changes with every rating update. Account metadata has no version of its
+// own, so this is what stops two updates for the same player from both
+// starting from the old rating.
+const (
+	ratingGuardCollection = "rating_guard"
+	ratingGuardKey        = "current"
+)
+
+// ratingUpdateAttempts is how often updateRatings starts over after another
+// update for one of its players got in first.
+const ratingUpdateAttempts = 3
+
+type ratingHistoryEntry struct {
+	MatchId     string        `json:"matchId"`
+	Mode        string        `json:"mode"`
+	Place       int           `json:"place"`
+	Players     int           `json:"players"`
+	Before      rating.Rating `json:"before"`
+	After       rating.Rating `json:"after"`
+	Provisional bool          `json:"provisional"`
+	At          int64         `json:"at"`
+}
+
+// ratingFromMetadata reads a player's rating. Accounts that have never been
+// rated start provisional; an elo set by hand is kept but just as uncertain.
+func ratingFromMetadata(meta map[string]interface{}) rating.Rating {
+	r := rating.New()
+	if v, ok := meta[metaElo].(float64); ok {
+		r.Rating = v
+	}
+	if v, ok := meta[metaDeviation].(float64); ok && v > 0 {
+		r.Deviation = v
+	}
+	if v, ok := meta[metaVolatility].(float64); ok && v > 0 {
+		r.Volatility = v
+	}
+	return r
+}
+
+// readPlayerRatings reads the rating of each of userIDs from their account.
+// Accounts that no longer exist are left out.
+func readPlayerRatings(ctx context.Context, nk runtime.NakamaModule, userIDs []string) (map[string]float64, error) {
+	users, err := nk.UsersGetId(ctx, userIDs, nil)
+	if err != nil {
+		return nil, fmt.Errorf("users get failed: %v", err)
+	}
+	ratings := make(map[string]float64, len(users))
+	for _, u := range users {
+		meta := make(map[string]interface{})
+		if err := json.Unmarshal([]byte(u.GetMetadata()), &meta); err != nil {
+			meta = make(map[string]interface{})
+		}
+		ratings[u.GetId()] = ratingFromMetadata(meta).Rating
+	}
+	return ratings, nil
+}
+
+// updateRatings applies a match's standings to everyone in them. Every
+// account's new rating and its history entry are written in one
+// transaction, so a match is either rated for all its players or none.
+//
+// AccountUpdateId would write the metadata one account at a time and could
+// not include the history, so MultiUpdate is used with the same account
+// updates. Neither checks what the metadata was when it was read; the
+// rating guard records do, and a match that loses a race for one of its
+// players is rated again from the new ratings.
+func updateRatings(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, matchID, mode string, standings []rating.Standing) error {
+	sides := make(map[string]bool)
+	for _, s := range standings {
+		side := s.Team
+		if side == "" {
+			side = s.ID
+		}
+		sides[side] = true
+	}
+	if len(sides) < 2 {
+		return nil
+	}
+	sort.Slice(standings, func(i, j int) bool { return standings[i].ID < standings[j].ID })
+
+	var err error
+	for range ratingUpdateAttempts {
+		if err = rateStandings(ctx, logger, nk, matchID, mode, standings); err == nil {
+			return nil
+		}
+		logger.Warn("Rating match %s failed, retrying: %v", matchID, err)
+	}
+	return err
+}
+
+// rateStandings is one attempt at updateRatings.
+func rateStandings(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, matchID, mode string, standings []rating.Standing) error {
+	ids := make([]string, len(standings))
+	reads := make([]*runtime.StorageRead, len(standings))
+	for i, s := range standings {
+		ids[i] = s.ID
+		reads[i] = &runtime.StorageRead{Collection: ratingGuardCollection, Key: ratingGuardKey, UserID: s.ID}
+	}
+	// The guards are read before the accounts, so any update that lands in
+	// between changes a guard and fails this attempt.
+	guards, err := nk.StorageRead(ctx, reads)
+	if err != nil {
+		return fmt.Errorf("storage read failed: %v", err)
+	}
+	versions := make(map[string]string, len(guards))
+	for _, g := range guards {
+		versions[g.GetUserId()] = g.GetVersion()
+	}
+	users, err := nk.UsersGetId(ctx, ids, nil)
+	if err != nil {
+		return fmt.Errorf("users get failed: %v", err)
+	}
+	metas := make(map[string]map[string]interface{}, len(users))
+	before := make(map[string]rating.Rating, len(users))
+	for _, u := range users {
+		meta := make(map[string]interface{})
+		if err := json.Unmarshal([]byte(u.GetMetadata()), &meta); err != nil {
+			meta = make(map[string]interface{})
+		}
+		metas[u.GetId()] = meta
+		before[u.GetId()] = ratingFromMetadata(meta)
+	}
+	// Only accounts that still exist are rated.
+	rated := standings[:0:0]
+	for _, s := range standings {
+		if _, ok := metas[s.ID]; ok {
+			rated = append(rated, s)
+		}
+	}
+	after := rating.Placement(before, rated)
+
+	now := time.Now().Unix()
+	updates := make([]*runtime.AccountUpdate, 0, len(rated))
+	writes := make([]*runtime.StorageWrite, 0, 2*len(rated))
+	for _, s := range rated {
+		meta, r := metas[s.ID], after[s.ID]
+		matches, _ := meta[metaRatedMatches].(float64)
+		meta[metaElo] = r.Rating
+		meta[metaDeviation] = r.Deviation
+		meta[metaVolatility] = r.Volatility
+		meta[metaRatedMatches] = matches + 1
+		meta[metaProvisional] = r.Provisional()
+		updates = append(updates, &runtime.AccountUpdate{UserID: s.ID, Metadata: meta})
+
+		value, _ := json.Marshal(ratingHistoryEntry{
+			MatchId:     matchID,
+			Mode:        mode,
+			Place:       s.Place,
+			Players:     len(rated),
+			Before:      before[s.ID],
+			After:       r,
+			Provisional: r.Provisional(),
+			At:          now,
+		})
+		writes = append(writes, &runtime.StorageWrite{
+			Collection:      ratingHistoryCollection,
+			Key:             matchID,
+			UserID:          s.ID,
+			Value:           string(value),
+			PermissionRead:  2,
+			PermissionWrite: 0,
+		})
+
+		version, ok := versions[s.ID]
+		if !ok {
+			version = "*"
+		}
+		guard, _ := json.Marshal(map[string]interface{}{"matchId": matchID})
+		writes = append(writes, &runtime.StorageWrite{
+			Collection:      ratingGuardCollection,
+			Key:             ratingGuardKey,
+			UserID:          s.ID,
+			Value:           string(guard),
+			Version:         version,
+			PermissionRead:  0,
+			PermissionWrite: 0,
+		})
+	}
+	if _, _, err := nk.MultiUpdate(ctx, updates, writes, nil, nil, false); err != nil {
+		return err
+	}
+	logger.Info("Rated match %s for %d players", matchID, len(rated))
+	return nil
+}
@@ -0,0 +1,89 @@
+package nakama
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+
+	"github.com/delta/terrabound/backend/internal/game"
+	"github.com/delta/terrabound/backend/internal/rating"
+)
+
+// ratedMatches reads how many rated matches userID's account has played.
+func ratedMatches(t *testing.T, nk *fakeNakama, userID string) float64 {
+	t.Helper()
+	meta := make(map[string]interface{})
+	if err := json.Unmarshal([]byte(nk.metadata[userID]), &meta); err != nil {
+		t.Fatalf("%s: %v", userID, err)
+	}
+	n, _ := meta[metaRatedMatches].(float64)
+	return n
+}
+
+// historyOwners lists who has a rating history entry for matchID.
+func historyOwners(nk *fakeNakama, matchID string) []string {
+	var owners []string
+	prefix := ratingHistoryCollection + "/" + matchID + "/"
+	for key := range nk.storage {
+		if owner, ok := strings.CutPrefix(key, prefix); ok {
+			owners = append(owners, owner)
+		}
+	}
+	sort.Strings(owners)
+	return owners
+}
+
+func TestUpdateRatingsKeepsConcurrentUpdates(t *testing.T) {
+	nk := &fakeNakama{}
+	ctx := context.Background()
+	nk.beforeUpdate = func() {
+		// Another match finishes for "a" between this one reading the
+		// ratings and writing them back.
+		nk.beforeUpdate = nil
+		if err := updateRatings(ctx, nopLogger{}, nk, "m2", modeStrategy, []rating.Standing{{ID: "a", Place: 2}, {ID: "c", Place: 1}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := updateRatings(ctx, nopLogger{}, nk, "m1", modeStrategy, []rating.Standing{{ID: "a", Place: 1}, {ID: "b", Place: 2}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := ratedMatches(t, nk, "a"); got != 2 {
+		t.Errorf("a has %v rated matches, want 2", got)
+	}
+	for match, want := range map[string]string{"m1": "a,b", "m2": "a,c"} {
+		if got := strings.Join(historyOwners(nk, match), ","); got != want {
+			t.Errorf("%s history for %s, want %s", match, got, want)
+		}
+	}
+}
+
+func TestStrategyMatchRatesHumansOnly(t *testing.T) {
+	m, s := newTestStrategyMatch(t, map[string]interface{}{"maxPlayers": 3})
+	nk := &fakeNakama{}
+	s.MatchID = "rated"
+	m.MatchJoin(context.Background(), nopLogger{}, nil, nk, &fakeMatch{}, 1, s, []runtime.Presence{fakePresence{"p1", "s1"}, fakePresence{"p2", "s2"}})
+	if !s.start(nopLogger{}, &fakeMatch{}, 2) || len(s.Bots) != 1 {
+		t.Fatalf("match did not start with one bot: %d bots", len(s.Bots))
+	}
+
+	// p2 left and a bot finished the game for them.
+	s.Bots["p2"] = s.BotStrategy
+	s.rate(context.Background(), nopLogger{}, nk, game.Declare(s.World, []game.PlayerID{"p1"}, s.Turn))
+
+	if got := strings.Join(historyOwners(nk, "rated"), ","); got != "p1,p2" {
+		t.Errorf("history written for %s, want p1,p2", got)
+	}
+	for id, p := range s.World.Players {
+		if _, ok := nk.metadata[string(id)]; ok && p.Bot {
+			t.Errorf("bot %s was rated", id)
+		}
+	}
+	if ratedMatches(t, nk, "p1") != 1 || ratedMatches(t, nk, "p2") != 1 {
+		t.Error("players not rated")
+	}
+}
@@ -0,0 +1,86 @@
+package nakama
+
+import "sort"
+
+// disconnectPolicy says what happens to a seat whose player did not come back
+// within the grace period.
+type disconnectPolicy string
+
+const (
+	// policyForfeit gives the seat up: the player is removed from the match.
+	policyForfeit disconnectPolicy = "forfeit"
+	// policyBot hands the seat to a server bot, where the match has them.
+	policyBot disconnectPolicy = "bot"
+)
+
+func parseDisconnectPolicy(v string, def disconnectPolicy) disconnectPolicy {
+	switch p := disconnectPolicy(v); p {
+	case policyForfeit, policyBot:
+		return p
+	default:
+		return def
+	}
+}
+
+// reconnectGrace keeps disconnected players' seats reserved until a deadline
+// tick, so a dropped connection does not cost them their progress.
+type reconnectGrace struct {
+	Ticks     int64
+	Policy    disconnectPolicy
+	Deadlines map[string]int64
+}
+
+func newReconnectGrace(seconds, tickRate int, policy disconnectPolicy) *reconnectGrace {
+	return &reconnectGrace{
+		Ticks:     int64(seconds * tickRate),
+		Policy:    policy,
+		Deadlines: make(map[string]int64),
+	}
+}
+
+// Hold reserves userID's seat from tick. With no grace configured the hold
+// expires on the next tick, so the policy still runs from the match loop.
+func (g *reconnectGrace) Hold(userID string, tick int64) {
+	g.Deadlines[userID] = tick + max(g.Ticks, 0)
+}
+
+// Drop gives up userID's seat at once, as for a banned player, so the policy
+// runs on the next tick.
+func (g *reconnectGrace) Drop(userID string, tick int64) {
+	g.Deadlines[userID] = tick
+}
+
+// Extend pushes every deadline back by ticks, for time the match spent paused.
+func (g *reconnectGrace) Extend(ticks int64) {
+	for userID := range g.Deadlines {
+		g.Deadlines[userID] += ticks
+	}
+}
+
+// Holding reports whether userID has a seat waiting for them.
+func (g *reconnectGrace) Holding(userID string) bool {
+	_, ok := g.Deadlines[userID]
+	return ok
+}
+
+// Return releases the hold for a player who came back, reporting whether there was one.
+func (g *reconnectGrace) Return(userID string) bool {
+	if !g.Holding(userID) {
+		return false
+	}
+	delete(g.Deadlines, userID)
+	return true
+}
+
+// Expired removes and returns, in a stable order, every hold whose deadline has passed.
+func (g *reconnectGrace) Expired(tick int64) []string {
+	var out []string
+	for userID, deadline := range g.Deadlines {
+		if tick >= deadline {
+			out = append(out, userID)
+			delete(g.Deadlines, userID)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,140 @@
+package nakama
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+
+	"github.com/delta/terrabound/backend/internal/game"
+)
+
+func TestReconnectGrace(t *testing.T) {
+	g := newReconnectGrace(2, 10, policyForfeit)
+	g.Hold("a", 100)
+	g.Hold("b", 105)
+	if got := g.Expired(119); len(got) != 0 {
+		t.Fatalf("expired %v before any deadline", got)
+	}
+
+	// Ten ticks paused push both deadlines back.
+	g.Extend(10)
+	if got := g.Expired(120); len(got) != 0 {
+		t.Fatalf("expired %v although the pause extended the grace", got)
+	}
+	if !g.Return("b") || g.Return("b") {
+		t.Error("b's hold not released exactly once")
+	}
+	if got := g.Expired(130); !reflect.DeepEqual(got, []string{"a"}) {
+		t.Errorf("expired %v, want a", got)
+	}
+	if g.Holding("a") {
+		t.Error("a still held after expiring")
+	}
+
+	g.Hold("c", 200)
+	g.Drop("c", 201)
+	if got := g.Expired(201); !reflect.DeepEqual(got, []string{"c"}) {
+		t.Errorf("expired %v, want the dropped c at once", got)
+	}
+
+	none := newReconnectGrace(0, 10, policyForfeit)
+	none.Hold("a", 50)
+	if got := none.Expired(50); !reflect.DeepEqual(got, []string{"a"}) {
+		t.Errorf("with no grace expired %v, want a on the same tick", got)
+	}
+}
+
+func TestParseDisconnectPolicy(t *testing.T) {
+	for in, want := range map[string]disconnectPolicy{"bot": policyBot, "forfeit": policyForfeit, "": policyBot, "spectate": policyBot} {
+		if got := parseDisconnectPolicy(in, policyBot); got != want {
+			t.Errorf("%q: %s, want %s", in, got, want)
+		}
+	}
+}
+
+// startedStrategyMatch seats p1, p2 and p3 and starts the match with a one
+// second reconnect grace.
+func startedStrategyMatch(t *testing.T, policy disconnectPolicy) (*StrategyMatch, *StrategyMatchState, *fakeMatch) {
+	t.Helper()
+	m, s := newTestStrategyMatch(t, map[string]interface{}{
+		"maxPlayers":            3,
+		"fillWithBots":          false,
+		"disconnectPolicy":      string(policy),
+		"reconnectGraceSeconds": 1,
+	})
+	dispatcher := &fakeMatch{}
+	m.MatchJoin(context.Background(), nopLogger{}, nil, &fakeNakama{}, dispatcher, 1, s, []runtime.Presence{
+		fakePresence{"p1", "s1"}, fakePresence{"p2", "s2"}, fakePresence{"p3", "s3"},
+	})
+	if s.Phase != game.PhasePlanning {
+		t.Fatalf("phase %s with every seat taken, want %s", s.Phase, game.PhasePlanning)
+	}
+	return m, s, dispatcher
+}
+
+func TestStrategyMatchDisconnectPolicy(t *testing.T) {
+	for _, policy := range []disconnectPolicy{policyBot, policyForfeit} {
+		t.Run(string(policy), func(t *testing.T) {
+			m, s, dispatcher := startedStrategyMatch(t, policy)
+			ctx, nk := context.Background(), &fakeNakama{}
+			m.MatchLeave(ctx, nopLogger{}, nil, nk, dispatcher, 10, s, []runtime.Presence{fakePresence{"p3", "s3"}})
+
+			m.MatchLoop(ctx, nopLogger{}, nil, nk, dispatcher, 10+strategyTickRate-1, s, nil)
+			if !s.Grace.Holding("p3") || len(s.Bots) != 0 || s.World.Players["p3"].Eliminated {
+				t.Fatal("disconnect policy applied before the grace ran out")
+			}
+
+			if m.MatchLoop(ctx, nopLogger{}, nil, nk, dispatcher, 10+strategyTickRate, s, nil) == nil {
+				t.Fatal("match ended with two players left")
+			}
+			if s.Grace.Holding("p3") {
+				t.Error("p3 still held after the grace ran out")
+			}
+			_, bot := s.Bots["p3"]
+			eliminated := s.World.Players["p3"].Eliminated
+			switch policy {
+			case policyBot:
+				if !bot || eliminated {
+					t.Errorf("bot policy: bot %v, eliminated %v; want a bot in the seat", bot, eliminated)
+				}
+			case policyForfeit:
+				if bot || !eliminated || s.EliminatedOn["p3"] != s.Turn {
+					t.Errorf("forfeit policy: bot %v, eliminated %v; want p3 out", bot, eliminated)
+				}
+			}
+		})
+	}
+}
+
+func TestStrategyMatchRejoinResyncs(t *testing.T) {
+	m, s, dispatcher := startedStrategyMatch(t, policyBot)
+	ctx, nk := context.Background(), &fakeNakama{}
+	m.MatchLeave(ctx, nopLogger{}, nil, nk, dispatcher, 10, s, []runtime.Presence{fakePresence{"p3", "s3"}})
+	m.MatchLoop(ctx, nopLogger{}, nil, nk, dispatcher, 10+strategyTickRate, s, nil)
+	if _, ok := s.Bots["p3"]; !ok {
+		t.Fatal("no bot took over for p3")
+	}
+
+	dispatcher.sent = nil
+	back := fakePresence{"p3", "s3-again"}
+	m.MatchJoin(ctx, nopLogger{}, nil, nk, dispatcher, 30, s, []runtime.Presence{back})
+	if _, ok := s.Bots["p3"]; ok || s.Locked["p3"] || len(s.Orders["p3"]) != 0 {
+		t.Error("the bot kept the seat or its orders after p3 came back")
+	}
+	if s.Presences["p3"] != runtime.Presence(back) {
+		t.Error("p3's new presence not tracked")
+	}
+
+	var got []int64
+	for _, msg := range dispatcher.sent {
+		if len(msg.to) == 1 && msg.to[0].GetSessionId() == "s3-again" {
+			got = append(got, msg.opCode)
+		}
+	}
+	want := []int64{opCodeWelcome, opCodePhaseChange, opCodeOrderResult, opCodeWorldState}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("p3 was sent opcodes %v on rejoining, want %v", got, want)
+	}
+}
@@ -0,0 +1,104 @@
+package nakama
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// signalOpReserve asks a match to hold a seat for UserID, or for everyone in
+// Party. Signals are handled one at a time alongside joins, so two callers can
+// never be given the same last seat. The reply's State is the match label after the reservation.
+const signalOpReserve = "reserve"
+
+// reservedForParam names the users to reserve seats for at MatchInit, so a
+// match created for someone cannot fill up before they get to it.
+const reservedForParam = "reservedFor"
+
+// seatReservations holds seats for players the dynamic_match RPC or the
+// matchmaker has sent to a match but who have not joined yet. A reservation
+// lapses after Ticks.
+type seatReservations struct {
+	Ticks   int64
+	Expires map[string]int64
+}
+
+func newSeatReservations(params map[string]interface{}, tickRate int) *seatReservations {
+	r := &seatReservations{
+		Ticks:   int64(paramInt(params, "reservationSeconds", 15) * tickRate),
+		Expires: make(map[string]int64),
+	}
+	for _, userID := range paramStrings(params, reservedForParam) {
+		r.Reserve(userID, 0)
+	}
+	return r
+}
+
+// Reserve holds a seat for userID from tick, renewing any they already have.
+func (r *seatReservations) Reserve(userID string, tick int64) {
+	r.Expires[userID] = tick + r.Ticks
+}
+
+// ReserveAll holds a seat from tick for each of userIDs that admit lets in.
+// If any is turned away nobody is, and admit's reason is returned.
+func (r *seatReservations) ReserveAll(userIDs []string, tick int64, admit func(userID string) string) string {
+	prior := make(map[string]int64)
+	for _, userID := range userIDs {
+		if expires, ok := r.Expires[userID]; ok {
+			prior[userID] = expires
+		}
+	}
+	for _, userID := range userIDs {
+		if reason := admit(userID); reason != "" {
+			for _, userID := range userIDs {
+				if expires, ok := prior[userID]; ok {
+					r.Expires[userID] = expires
+				} else {
+					delete(r.Expires, userID)
+				}
+			}
+			return reason
+		}
+		r.Reserve(userID, tick)
+	}
+	return ""
+}
+
+// Claim uses up userID's reservation as they take their seat.
+func (r *seatReservations) Claim(userID string) {
+	delete(r.Expires, userID)
+}
+
+func (r *seatReservations) Count() int {
+	return len(r.Expires)
+}
+
+// Others is how many seats are held for anyone but userID, who may use their own.
+func (r *seatReservations) Others(userID string) int {
+	if _, ok := r.Expires[userID]; ok {
+		return len(r.Expires) - 1
+	}
+	return len(r.Expires)
+}
+
+// Expired removes and returns, in a stable order, every reservation that has lapsed.
+func (r *seatReservations) Expired(tick int64) []string {
+	var out []string
+	for userID, expires := range r.Expires {
+		if tick >= expires {
+			out = append(out, userID)
+			delete(r.Expires, userID)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// reserveReply encodes the answer to a reserve signal.
+func reserveReply(reason string, label matchLabel) string {
+	reply := signalReply{OK: reason == "", Error: reason}
+	if reply.OK {
+		reply.State = json.RawMessage(label.String())
+	}
+	out, _ := json.Marshal(reply)
+	return string(out)
+}
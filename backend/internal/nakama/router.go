@@ -0,0 +1,124 @@
+package nakama
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/delta/terrabound/backend/internal/matchpb"
+)
+
+// Error codes sent on opCodeError.
+const (
+	errorUnknownOpCode = "unknown_opcode"
+	errorBadPayload    = "bad_payload"
+	// errorPaused answers input sent while an admin has the match paused.
+	errorPaused = "match_paused"
+)
+
+type errorMessage struct {
+	Code   string `json:"code"`
+	OpCode int64  `json:"opCode"`
+}
+
+// loopContext is the part of a MatchLoop call that message handlers need.
+type loopContext struct {
+	ctx        context.Context
+	logger     runtime.Logger
+	nk         runtime.NakamaModule
+	dispatcher runtime.MatchDispatcher
+	tick       int64
+	// protocols is what each client negotiated; clients not in it speak JSON.
+	protocols map[string]clientProtocol
+}
+
+func (c *loopContext) encodingOf(userID string) wireEncoding {
+	if p, ok := c.protocols[userID]; ok {
+		return p.Encoding
+	}
+	return encodingJSON
+}
+
+// replyError tells the sender of msg, in their encoding, that it was not understood.
+func (c *loopContext) replyError(msg runtime.MatchData, code string) {
+	data, err := encode(c.encodingOf(msg.GetUserId()), errorMessage{Code: code, OpCode: msg.GetOpCode()}, func() proto.Message {
+		return &matchpb.Error{Code: code, OpCode: msg.GetOpCode()}
+	})
+	if err != nil {
+		c.logger.Error("Failed to marshal opcode %d: %v", opCodeError, err)
+		return
+	}
+	c.dispatcher.BroadcastMessage(opCodeError, data, []runtime.Presence{msg}, nil, true)
+}
+
+// refuse answers every message with code instead of handling it.
+func (c *loopContext) refuse(messages []runtime.MatchData, code string) {
+	for _, msg := range messages {
+		c.replyError(msg, code)
+	}
+}
+
+// opHandler handles one message. It returns false once the match has ended
+// and no further messages should be handled.
+type opHandler[S any] func(s S, c *loopContext, msg runtime.MatchData) bool
+
+// opRouter sends each incoming message to the handler registered for its
+// opcode. Anything else is answered with an unknown_opcode error.
+type opRouter[S any] struct {
+	handlers map[int64]opHandler[S]
+}
+
+func newOpRouter[S any]() *opRouter[S] {
+	return &opRouter[S]{handlers: make(map[int64]opHandler[S])}
+}
+
+func (r *opRouter[S]) handle(opCode int64, h opHandler[S]) {
+	if _, dup := r.handlers[opCode]; dup {
+		panic("nakama: opcode registered twice: " + opCodeNames[opCode])
+	}
+	r.handlers[opCode] = h
+}
+
+// handleDecoded registers h for opCode with the payload decoded into M from
+// the sender's encoding first. Payloads that do not decode are answered with a
+// bad_payload error.
+func handleDecoded[S, M any](r *opRouter[S], opCode int64, decode func(wireEncoding, []byte) (M, error), h func(s S, c *loopContext, msg runtime.MatchData, in M) bool) {
+	r.handle(opCode, func(s S, c *loopContext, msg runtime.MatchData) bool {
+		in, err := decode(c.encodingOf(msg.GetUserId()), msg.GetData())
+		if err != nil {
+			c.logger.Warn("Failed to parse %s from %s: %v", opCodeNames[opCode], msg.GetUserId(), err)
+			c.replyError(msg, errorBadPayload)
+			return true
+		}
+		return h(s, c, msg, in)
+	})
+}
+
+// handleJSON registers h for a message that only has a JSON form.
+func handleJSON[S, M any](r *opRouter[S], opCode int64, h func(s S, c *loopContext, msg runtime.MatchData, in M) bool) {
+	handleDecoded(r, opCode, decodeJSON[M], h)
+}
+
+func decodeJSON[M any](_ wireEncoding, data []byte) (M, error) {
+	var in M
+	err := json.Unmarshal(data, &in)
+	return in, err
+}
+
+// dispatch handles messages in order. It returns false if a handler ended the match.
+func (r *opRouter[S]) dispatch(s S, c *loopContext, messages []runtime.MatchData) bool {
+	for _, msg := range messages {
+		h, ok := r.handlers[msg.GetOpCode()]
+		if !ok {
+			c.logger.Warn("Unknown opcode %d from %s", msg.GetOpCode(), msg.GetUserId())
+			c.replyError(msg, errorUnknownOpCode)
+			continue
+		}
+		if !h(s, c, msg) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,136 @@
+package nakama
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/delta/terrabound/backend/internal/matchpb"
+)
+
+// routeLog records which handlers ran, in order.
+type routeLog struct {
+	handled []string
+}
+
+type routeInput struct {
+	Name string `json:"name"`
+}
+
+func newTestRouter() *opRouter[*routeLog] {
+	r := newOpRouter[*routeLog]()
+	r.handle(opCodeLockIn, func(s *routeLog, c *loopContext, msg runtime.MatchData) bool {
+		s.handled = append(s.handled, "lock_in:"+msg.GetUserId())
+		return true
+	})
+	handleJSON(r, opCodeSubmitOrders, func(s *routeLog, c *loopContext, msg runtime.MatchData, in routeInput) bool {
+		s.handled = append(s.handled, "orders:"+in.Name)
+		return true
+	})
+	r.handle(opCodeConcede, func(s *routeLog, c *loopContext, msg runtime.MatchData) bool {
+		s.handled = append(s.handled, "concede")
+		return false
+	})
+	return r
+}
+
+func TestOpRouterDispatch(t *testing.T) {
+	r := newTestRouter()
+	dispatcher := &fakeMatch{}
+	c := &loopContext{ctx: context.Background(), logger: nopLogger{}, dispatcher: dispatcher}
+	s := &routeLog{}
+
+	ok := r.dispatch(s, c, []runtime.MatchData{
+		fakeMessage{fakePresence{"a", "sa"}, opCodeSubmitOrders, []byte(`{"name":"first"}`)},
+		fakeMessage{fakePresence{"b", "sb"}, opCodeLockIn, nil},
+		fakeMessage{fakePresence{"a", "sa"}, 99, nil},
+		fakeMessage{fakePresence{"b", "sb"}, opCodeSubmitOrders, []byte(`{"name":`)},
+		fakeMessage{fakePresence{"a", "sa"}, opCodeSubmitOrders, []byte(`{"name":"second"}`)},
+	})
+	if !ok {
+		t.Fatal("dispatch reported the match ended")
+	}
+	if want := []string{"orders:first", "lock_in:b", "orders:second"}; !reflect.DeepEqual(s.handled, want) {
+		t.Errorf("handled %v, want %v", s.handled, want)
+	}
+
+	var errs []string
+	for _, m := range dispatcher.sent {
+		var e errorMessage
+		if m.opCode != opCodeError || json.Unmarshal([]byte(m.data), &e) != nil {
+			t.Fatalf("unexpected message %+v", m)
+		}
+		errs = append(errs, m.to[0].GetUserId()+":"+e.Code)
+	}
+	if want := []string{"a:" + errorUnknownOpCode, "b:" + errorBadPayload}; !reflect.DeepEqual(errs, want) {
+		t.Errorf("errors %v, want %v", errs, want)
+	}
+}
+
+func TestOpRouterStopsWhenMatchEnds(t *testing.T) {
+	r := newTestRouter()
+	c := &loopContext{ctx: context.Background(), logger: nopLogger{}, dispatcher: &fakeMatch{}}
+	s := &routeLog{}
+
+	if r.dispatch(s, c, []runtime.MatchData{
+		fakeMessage{fakePresence{"a", "sa"}, opCodeConcede, nil},
+		fakeMessage{fakePresence{"b", "sb"}, opCodeLockIn, nil},
+	}) {
+		t.Error("dispatch carried on after the match ended")
+	}
+	if want := []string{"concede"}; !reflect.DeepEqual(s.handled, want) {
+		t.Errorf("handled %v, want %v", s.handled, want)
+	}
+}
+
+func TestOpRouterErrorsInClientEncoding(t *testing.T) {
+	dispatcher := &fakeMatch{}
+	c := &loopContext{
+		ctx:        context.Background(),
+		logger:     nopLogger{},
+		dispatcher: dispatcher,
+		protocols:  map[string]clientProtocol{"a": {Version: protocolVersion, Encoding: encodingProtobuf}},
+	}
+	newTestRouter().dispatch(&routeLog{}, c, []runtime.MatchData{fakeMessage{fakePresence{"a", "sa"}, 99, nil}})
+
+	if len(dispatcher.sent) != 1 {
+		t.Fatalf("sent %d messages, want 1", len(dispatcher.sent))
+	}
+	var e matchpb.Error
+	if err := proto.Unmarshal([]byte(dispatcher.sent[0].data), &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Code != errorUnknownOpCode || e.OpCode != 99 {
+		t.Errorf("error %+v, want unknown_opcode for 99", &e)
+	}
+}
+
+func TestOpRouterRejectsDuplicateOpCodes(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("registering an opcode twice did not panic")
+		}
+	}()
+	r := newTestRouter()
+	r.handle(opCodeLockIn, func(*routeLog, *loopContext, runtime.MatchData) bool { return true })
+}
+
+// routedOpCodes lists the opcodes r has handlers for, in order.
+func routedOpCodes[S any](r *opRouter[S]) []int64 {
+	return slices.Sorted(maps.Keys(r.handlers))
+}
+
+func TestMatchRoutes(t *testing.T) {
+	if got, want := routedOpCodes(movementRoutes), []int64{opCodePlayerMove, opCodeStateAck}; !reflect.DeepEqual(got, want) {
+		t.Errorf("movement match routes %v, want %v", got, want)
+	}
+	if got, want := routedOpCodes(strategyRoutes), []int64{opCodeSubmitOrders, opCodeLockIn, opCodeConcede}; !reflect.DeepEqual(got, want) {
+		t.Errorf("strategy match routes %v, want %v", got, want)
+	}
+}
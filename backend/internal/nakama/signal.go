@@ -0,0 +1,178 @@
+package nakama
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/delta/terrabound/backend/internal/matchpb"
+)
+
+// Signal ops. signalOpWorld is answered by strategy matches only, with the
+// board as UserID sees it through the fog of war. The rest
+// are operator controls every match type handles; they arrive through the
+// tb_admin_match RPC, which has already checked the caller.
+const (
+	signalOpWorld = "world"
+
+	signalOpPause     = "pause"
+	signalOpResume    = "resume"
+	signalOpKick      = "kick"
+	signalOpEnd       = "end"
+	signalOpTickRate  = "tick_rate"
+	signalOpBroadcast = "broadcast"
+	signalOpDump      = "dump"
+)
+
+type matchSignal struct {
+	Op string `json:"op"`
+
+	// UserID is the player to kick; Ban also keeps them out for good.
+	UserID string `json:"userId,omitempty"`
+	Ban    bool   `json:"ban,omitempty"`
+	// Winners are declared by end. Match types without winners ignore them.
+	Winners []string `json:"winners,omitempty"`
+	// TickRate is how often per second tick_rate has the match do its work.
+	// It must divide the rate the match was created with.
+	TickRate int    `json:"tickRate,omitempty"`
+	Message  string `json:"message,omitempty"`
+	// Party has reserve hold a seat for every one of these players, or for none.
+	Party []string `json:"party,omitempty"`
+}
+
+// reservees is who a reserve signal asks seats for.
+func (sig matchSignal) reservees() []string {
+	if len(sig.Party) > 0 {
+		return sig.Party
+	}
+	return []string{sig.UserID}
+}
+
+// signalReply answers every admin op. State is only set by dump.
+type signalReply struct {
+	OK    bool            `json:"ok"`
+	Error string          `json:"error,omitempty"`
+	State json.RawMessage `json:"state,omitempty"`
+}
+
+type serverMessage struct {
+	Text string `json:"text"`
+}
+
+type matchPausedMessage struct {
+	Paused bool `json:"paused"`
+}
+
+// adminControls is the part of a match's state operators change.
+type adminControls struct {
+	Paused   bool
+	PausedAt int64
+	// Divisor runs the match loop's work on every Divisor-th tick only.
+	// Nakama fixes the real tick rate at MatchInit, so this is how the
+	// tick_rate op lowers a running match's update rate. Game time still
+	// follows the clock: movement steps cover the skipped ticks and strategy
+	// phases end when they would have, only checked less often.
+	Divisor int
+	// End is set by the end op. Only MatchLoop can stop a match, so the
+	// next tick acts on it.
+	End *adminEnd
+}
+
+type adminEnd struct {
+	Winners []string
+}
+
+// Slowed reports whether tick falls between the ticks a slowed match still
+// runs. Messages are handled on every tick so none are lost.
+func (a *adminControls) Slowed(tick int64) bool {
+	return a.Divisor > 1 && tick%int64(a.Divisor) != 0
+}
+
+// StepTicks is how many real ticks each tick that runs stands for.
+func (a *adminControls) StepTicks() int {
+	return max(a.Divisor, 1)
+}
+
+// adminTarget is what a match type exposes for the admin ops.
+type adminTarget interface {
+	controls() *adminControls
+	// audience is who notices go to and the encodings they speak.
+	audience() (map[string]runtime.Presence, map[string]clientProtocol)
+	baseTickRate() int
+	// kick removes userID, and with ban keeps them out. Their MatchLeave
+	// follows from the dispatcher.
+	kick(logger runtime.Logger, dispatcher runtime.MatchDispatcher, tick int64, userID string, ban bool) error
+	// checkWinners rejects winners that are not in the match.
+	checkWinners(winners []string) error
+	// extendDeadlines pushes every timer back by ticks after a pause.
+	extendDeadlines(ticks int64)
+}
+
+// handleAdminSignal runs one admin op against t and encodes the reply, or
+// returns ok false for an op that is not an admin op.
+func handleAdminSignal(logger runtime.Logger, dispatcher runtime.MatchDispatcher, tick int64, t adminTarget, sig matchSignal) (string, bool) {
+	a := t.controls()
+	presences, protocols := t.audience()
+	reply := signalReply{OK: true}
+
+	switch sig.Op {
+	case signalOpPause, signalOpResume:
+		paused := sig.Op == signalOpPause
+		if a.Paused == paused {
+			reply = signalReply{Error: "match is already in that state"}
+			break
+		}
+		if paused {
+			a.Paused, a.PausedAt = true, tick
+		} else {
+			a.Paused = false
+			t.extendDeadlines(tick - a.PausedAt)
+		}
+		notifyAll(logger, dispatcher, presences, protocols, opCodeMatchPaused, matchPausedMessage{Paused: paused}, func() proto.Message {
+			return &matchpb.MatchPaused{Paused: paused}
+		})
+	case signalOpKick:
+		if err := t.kick(logger, dispatcher, tick, sig.UserID, sig.Ban); err != nil {
+			reply = signalReply{Error: err.Error()}
+		}
+	case signalOpEnd:
+		if err := t.checkWinners(sig.Winners); err != nil {
+			reply = signalReply{Error: err.Error()}
+			break
+		}
+		a.End = &adminEnd{Winners: sig.Winners}
+	case signalOpTickRate:
+		base := t.baseTickRate()
+		if sig.TickRate < 1 || sig.TickRate > base || base%sig.TickRate != 0 {
+			reply = signalReply{Error: fmt.Sprintf("tick rate must divide %d", base)}
+			break
+		}
+		a.Divisor = base / sig.TickRate
+	case signalOpBroadcast:
+		if sig.Message == "" {
+			reply = signalReply{Error: "message is empty"}
+			break
+		}
+		notifyAll(logger, dispatcher, presences, protocols, opCodeServerMessage, serverMessage{Text: sig.Message}, func() proto.Message {
+			return &matchpb.ServerMessage{Text: sig.Message}
+		})
+	case signalOpDump:
+		state, err := json.Marshal(t)
+		if err != nil {
+			reply = signalReply{Error: fmt.Sprintf("marshal state: %v", err)}
+			break
+		}
+		reply.State = state
+	default:
+		return "", false
+	}
+
+	out, err := json.Marshal(reply)
+	if err != nil {
+		logger.Error("Failed to marshal signal reply: %v", err)
+		return "", true
+	}
+	return string(out), true
+}
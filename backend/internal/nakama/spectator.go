@@ -0,0 +1,233 @@
+package nakama
+
+import (
+	"strconv"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+// Join metadata a spectator sends. delaySeconds holds their stream back,
+// and perspective picks the player whose view they get. Only matches created
+// with spectatorFullView let spectators go without one and see everything.
+const (
+	metaSpectate    = "spectate"
+	metaDelay       = "delaySeconds"
+	metaPerspective = "perspective"
+)
+
+// maxSpectatorDelaySeconds bounds how much of the stream a match buffers.
+const maxSpectatorDelaySeconds = 300
+
+// defaultSpectatorDelaySeconds is the least delay spectators get unless the
+// match says otherwise, so a live match cannot be watched to help a player.
+const defaultSpectatorDelaySeconds = 60
+
+// pendingSpectatorSeconds is how long an admitted spectator has to finish
+// joining before their slot is given back.
+const pendingSpectatorSeconds = 10
+
+const (
+	rejectNoSpectators    = "match does not allow spectators"
+	rejectSpectatorsFull  = "no spectator slots left"
+	rejectSeatedSpectator = "players cannot spectate their own match"
+	rejectPerspective     = "perspective is not a player in this match"
+	rejectNoPerspective   = "spectators must pick a player's perspective"
+	rejectDelay           = "malformed spectator delay"
+)
+
+// spectating reports whether a join asks to watch rather than play.
+func spectating(metadata map[string]string) bool {
+	v, _ := strconv.ParseBool(metadata[metaSpectate])
+	return v
+}
+
+type spectator struct {
+	// Presence is nil until MatchJoin.
+	Presence   runtime.Presence
+	DelayTicks int64
+	// Expires is the tick an admitted spectator must have joined by.
+	Expires int64
+	// Perspective is the player whose view is streamed, or empty for a
+	// fog-free stream where the match allows one.
+	Perspective string
+}
+
+type delayedMessage struct {
+	Due    int64
+	UserID string
+	OpCode int64
+	Data   []byte
+}
+
+// spectatorFeed keeps a match's spectators apart from its players and holds
+// each one's stream back by their delay. Spectators never take a seat and
+// nothing they send is handled.
+type spectatorFeed struct {
+	Allowed bool
+	// FullView lets spectators watch without fog of war.
+	FullView      bool
+	Max           int
+	MinDelayTicks int64
+	TickRate      int
+	// Spectators are those who have joined, by user id. Pending are those
+	// admitted but not yet joined, by session id, so that a join attempt
+	// which never completes cannot make a later join from the same account
+	// a spectator.
+	Spectators map[string]*spectator
+	Pending    map[string]*spectator
+	Queue      []delayedMessage
+	// Now is the latest tick the match has seen, for stamping sends made
+	// outside the loop.
+	Now int64
+}
+
+// newSpectatorFeed reads spectators, spectatorFullView, maxSpectators and
+// spectatorDelaySeconds (the least delay any spectator gets) from the match
+// params. Spectating is off unless a match is created with it.
+func newSpectatorFeed(params map[string]interface{}, tickRate int) *spectatorFeed {
+	delay := max(paramInt(params, "spectatorDelaySeconds", defaultSpectatorDelaySeconds), 0)
+	return &spectatorFeed{
+		Allowed:       paramBool(params, "spectators", false),
+		FullView:      paramBool(params, "spectatorFullView", false),
+		Max:           paramInt(params, "maxSpectators", 16),
+		MinDelayTicks: int64(min(delay, maxSpectatorDelaySeconds) * tickRate),
+		TickRate:      tickRate,
+		Spectators:    make(map[string]*spectator),
+		Pending:       make(map[string]*spectator),
+	}
+}
+
+// Admit checks a spectate request and holds a slot for the session making it
+// until it joins or pendingSpectatorSeconds pass. seated says whether the
+// user is playing in the match, and isPlayer whether a perspective names
+// someone who is. It returns the rejection reason, or "".
+func (f *spectatorFeed) Admit(presence runtime.Presence, metadata map[string]string, seated bool, isPlayer func(string) bool) string {
+	if !f.Allowed {
+		return rejectNoSpectators
+	}
+	if seated {
+		return rejectSeatedSpectator
+	}
+	if _, ok := f.Spectators[presence.GetUserId()]; !ok && len(f.Spectators)+len(f.Pending) >= f.Max {
+		return rejectSpectatorsFull
+	}
+	sp := &spectator{
+		DelayTicks:  f.MinDelayTicks,
+		Expires:     f.Now + int64(pendingSpectatorSeconds*f.TickRate),
+		Perspective: metadata[metaPerspective],
+	}
+	if v, ok := metadata[metaDelay]; ok {
+		seconds, err := strconv.Atoi(v)
+		if err != nil || seconds < 0 {
+			return rejectDelay
+		}
+		sp.DelayTicks = max(sp.DelayTicks, int64(min(seconds, maxSpectatorDelaySeconds)*f.TickRate))
+	}
+	switch {
+	case sp.Perspective == "" && !f.FullView:
+		return rejectNoPerspective
+	case sp.Perspective != "" && !isPlayer(sp.Perspective):
+		return rejectPerspective
+	}
+	f.Pending[presence.GetSessionId()] = sp
+	return ""
+}
+
+// Join turns the session admitted as a spectator into one and returns them,
+// or nil if p was admitted to play.
+func (f *spectatorFeed) Join(p runtime.Presence, tick int64) *spectator {
+	sp, ok := f.Pending[p.GetSessionId()]
+	if !ok {
+		return nil
+	}
+	delete(f.Pending, p.GetSessionId())
+	sp.Presence = p
+	f.Spectators[p.GetUserId()] = sp
+	f.Now = tick
+	return sp
+}
+
+// Leave forgets a spectator, reporting whether p was one.
+func (f *spectatorFeed) Leave(p runtime.Presence) bool {
+	sp, ok := f.Spectators[p.GetUserId()]
+	if !ok || sp.Presence.GetSessionId() != p.GetSessionId() {
+		return false
+	}
+	delete(f.Spectators, p.GetUserId())
+	return true
+}
+
+// Watching returns the spectators that have joined.
+func (f *spectatorFeed) Watching() map[string]*spectator {
+	return f.Spectators
+}
+
+// Presences returns the presences of spectators that have joined.
+func (f *spectatorFeed) Presences() map[string]runtime.Presence {
+	out := make(map[string]runtime.Presence, len(f.Spectators))
+	for userID, sp := range f.Spectators {
+		out[userID] = sp.Presence
+	}
+	return out
+}
+
+// FromPlayers drops whatever spectators sent.
+func (f *spectatorFeed) FromPlayers(messages []runtime.MatchData) []runtime.MatchData {
+	if len(f.Spectators) == 0 {
+		return messages
+	}
+	out := messages[:0:0]
+	for _, msg := range messages {
+		if _, ok := f.Spectators[msg.GetUserId()]; !ok {
+			out = append(out, msg)
+		}
+	}
+	return out
+}
+
+// Send streams data to sp once their delay has passed.
+func (f *spectatorFeed) Send(dispatcher runtime.MatchDispatcher, sp *spectator, opCode int64, data []byte) {
+	if sp.DelayTicks <= 0 {
+		dispatcher.BroadcastMessage(opCode, data, []runtime.Presence{sp.Presence}, nil, true)
+		return
+	}
+	f.Queue = append(f.Queue, delayedMessage{
+		Due:    f.Now + sp.DelayTicks,
+		UserID: sp.Presence.GetUserId(),
+		OpCode: opCode,
+		Data:   data,
+	})
+}
+
+// Flush sends everything that has come due by tick, in the order it was
+// queued, and gives back the slots of spectators who never joined. The loop
+// calls it every tick.
+func (f *spectatorFeed) Flush(dispatcher runtime.MatchDispatcher, tick int64) {
+	f.Now = tick
+	for sessionID, sp := range f.Pending {
+		if sp.Expires <= tick {
+			delete(f.Pending, sessionID)
+		}
+	}
+	f.flush(dispatcher, func(m delayedMessage) bool { return m.Due <= tick })
+}
+
+// FlushAll sends everything still held back, for a match that is ending.
+func (f *spectatorFeed) FlushAll(dispatcher runtime.MatchDispatcher) {
+	f.flush(dispatcher, func(delayedMessage) bool { return true })
+}
+
+func (f *spectatorFeed) flush(dispatcher runtime.MatchDispatcher, due func(delayedMessage) bool) {
+	kept := f.Queue[:0]
+	for _, m := range f.Queue {
+		if !due(m) {
+			kept = append(kept, m)
+			continue
+		}
+		// Spectators who left since are skipped.
+		if sp, ok := f.Spectators[m.UserID]; ok {
+			dispatcher.BroadcastMessage(m.OpCode, m.Data, []runtime.Presence{sp.Presence}, nil, true)
+		}
+	}
+	f.Queue = kept
+}
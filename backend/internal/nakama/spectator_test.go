@@ -0,0 +1,67 @@
+package nakama
+
+import (
+	"testing"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+type fakePresence struct {
+	userID, sessionID string
+}
+
+func (p fakePresence) GetHidden() bool                   { return false }
+func (p fakePresence) GetPersistence() bool              { return false }
+func (p fakePresence) GetUsername() string               { return p.userID }
+func (p fakePresence) GetStatus() string                 { return "" }
+func (p fakePresence) GetReason() runtime.PresenceReason { return runtime.PresenceReasonUnknown }
+func (p fakePresence) GetUserId() string                 { return p.userID }
+func (p fakePresence) GetSessionId() string              { return p.sessionID }
+func (p fakePresence) GetNodeId() string                 { return "node" }
+
+func TestSpectatorFeedDefaults(t *testing.T) {
+	feed := newSpectatorFeed(map[string]interface{}{}, 10)
+	if feed.Allowed || feed.FullView {
+		t.Fatalf("spectating must be opt-in and fogged by default, got allowed=%v fullView=%v", feed.Allowed, feed.FullView)
+	}
+	if feed.MinDelayTicks <= 0 {
+		t.Fatalf("live matches need a spectator delay by default, got %d ticks", feed.MinDelayTicks)
+	}
+
+	feed = newSpectatorFeed(map[string]interface{}{"spectators": true}, 10)
+	isPlayer := func(id string) bool { return id == "p1" }
+	if reason := feed.Admit(fakePresence{"u1", "s1"}, map[string]string{}, false, isPlayer); reason != rejectNoPerspective {
+		t.Fatalf("spectating without a perspective: got %q, want %q", reason, rejectNoPerspective)
+	}
+	if reason := feed.Admit(fakePresence{"u1", "s1"}, map[string]string{metaPerspective: "p1"}, false, isPlayer); reason != "" {
+		t.Fatalf("spectating through a player: rejected with %q", reason)
+	}
+}
+
+func TestAbandonedSpectateDoesNotLinger(t *testing.T) {
+	feed := newSpectatorFeed(map[string]interface{}{"spectators": true, "maxSpectators": 1}, 10)
+	isPlayer := func(string) bool { return true }
+	meta := map[string]string{metaPerspective: "p1"}
+
+	if reason := feed.Admit(fakePresence{"u1", "s1"}, meta, false, isPlayer); reason != "" {
+		t.Fatalf("admit: %q", reason)
+	}
+	// The same account joins to play from another session.
+	if sp := feed.Join(fakePresence{"u1", "s2"}, 1); sp != nil {
+		t.Fatal("a player's join was taken for a spectator's")
+	}
+	if msgs := feed.FromPlayers([]runtime.MatchData{nil}); len(msgs) != 1 {
+		t.Fatal("messages were dropped for a spectator who never joined")
+	}
+
+	if reason := feed.Admit(fakePresence{"u2", "s3"}, meta, false, isPlayer); reason != rejectSpectatorsFull {
+		t.Fatalf("slot held by a pending spectator: got %q", reason)
+	}
+	feed.Flush(nil, int64(pendingSpectatorSeconds*10))
+	if reason := feed.Admit(fakePresence{"u2", "s3"}, meta, false, isPlayer); reason != "" {
+		t.Fatalf("slot not given back after the join lapsed: %q", reason)
+	}
+	if sp := feed.Join(fakePresence{"u2", "s3"}, 2); sp == nil || len(feed.Watching()) != 1 {
+		t.Fatal("admitted spectator did not join")
+	}
+}
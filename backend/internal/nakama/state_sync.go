@@ -0,0 +1,129 @@
+package nakama
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+)
+
+// syncHistory is how many unacknowledged snapshots are kept per client. A
+// client that falls further behind than this is treated as desynced and gets
+// a full snapshot.
+const syncHistory = 32
+
+// snapshot is one client's view of the match, keyed by entity id, with every
+// entity already encoded so unchanged ones can be spotted with bytes.Equal.
+// Entities are in the client's wire encoding, so only JSON clients' snapshots
+// are valid json.RawMessage.
+type snapshot map[string]json.RawMessage
+
+// stateDeltaMessage carries either a full snapshot or the changes since
+// BaseTick. Clients apply Changed and Removed to the snapshot they acked at
+// BaseTick and then ack Tick.
+type stateDeltaMessage struct {
+	Tick     int64                      `json:"tick"`
+	BaseTick int64                      `json:"baseTick,omitempty"`
+	Full     bool                       `json:"full,omitempty"`
+	Changed  map[string]json.RawMessage `json:"changed,omitempty"`
+	Removed  []string                   `json:"removed,omitempty"`
+}
+
+// stateAckMessage acknowledges the snapshot a client now holds. Tick 0 or
+// Resync asks for a fresh full snapshot.
+type stateAckMessage struct {
+	Tick   int64 `json:"tick"`
+	Resync bool  `json:"resync,omitempty"`
+}
+
+type clientSync struct {
+	// sent holds every snapshot sent since the baseline, by tick.
+	sent     map[int64]snapshot
+	base     snapshot
+	baseTick int64
+}
+
+// stateSync tracks, per client, the last snapshot it acknowledged and builds
+// deltas against it. It knows nothing about what the entities are, so any
+// match type can use it.
+type stateSync struct {
+	clients map[string]*clientSync
+}
+
+func newStateSync() *stateSync {
+	return &stateSync{clients: make(map[string]*clientSync)}
+}
+
+// Enabled reports whether userID has opted in to delta sync.
+func (s *stateSync) Enabled(userID string) bool {
+	_, ok := s.clients[userID]
+	return ok
+}
+
+func (s *stateSync) Ack(userID string, ack stateAckMessage) {
+	c, ok := s.clients[userID]
+	if !ok {
+		c = &clientSync{sent: make(map[int64]snapshot)}
+		s.clients[userID] = c
+	}
+	if ack.Resync || ack.Tick == 0 {
+		c.base, c.baseTick = nil, 0
+		c.sent = make(map[int64]snapshot)
+		return
+	}
+	snap, ok := c.sent[ack.Tick]
+	if !ok || ack.Tick <= c.baseTick {
+		// Late or duplicate ack; the newer baseline stands.
+		return
+	}
+	c.base, c.baseTick = snap, ack.Tick
+	for tick := range c.sent {
+		if tick <= ack.Tick {
+			delete(c.sent, tick)
+		}
+	}
+}
+
+func (s *stateSync) Forget(userID string) {
+	delete(s.clients, userID)
+}
+
+// Delta returns the message to send userID for this tick's snapshot, or nil
+// when nothing has changed since their baseline.
+func (s *stateSync) Delta(userID string, tick int64, snap snapshot) *stateDeltaMessage {
+	c, ok := s.clients[userID]
+	if !ok {
+		return nil
+	}
+	if len(c.sent) >= syncHistory {
+		// The client has stopped acking; start again from a full snapshot.
+		c.base, c.baseTick = nil, 0
+		c.sent = make(map[int64]snapshot)
+	}
+
+	msg := &stateDeltaMessage{Tick: tick, Changed: make(map[string]json.RawMessage)}
+	if c.base == nil {
+		msg.Full = true
+		for id, raw := range snap {
+			msg.Changed[id] = raw
+		}
+	} else {
+		msg.BaseTick = c.baseTick
+		for id, raw := range snap {
+			if old, ok := c.base[id]; !ok || !bytes.Equal(old, raw) {
+				msg.Changed[id] = raw
+			}
+		}
+		for id := range c.base {
+			if _, ok := snap[id]; !ok {
+				msg.Removed = append(msg.Removed, id)
+			}
+		}
+		sort.Strings(msg.Removed)
+		if len(msg.Changed) == 0 && len(msg.Removed) == 0 {
+			return nil
+		}
+	}
+
+	c.sent[tick] = snap
+	return msg
+}
@@ -0,0 +1,117 @@
+package nakama
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+// snap builds a snapshot from id, encoded entity pairs.
+func snap(entities ...string) snapshot {
+	s := make(snapshot)
+	for i := 0; i+1 < len(entities); i += 2 {
+		s[entities[i]] = json.RawMessage(entities[i+1])
+	}
+	return s
+}
+
+// changedIDs lists the entities msg carries, sorted.
+func changedIDs(msg *stateDeltaMessage) []string {
+	var ids []string
+	for id := range msg.Changed {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestStateSyncAckedDelta(t *testing.T) {
+	s := newStateSync()
+	if s.Delta("u", 1, snap("a", "1")) != nil {
+		t.Fatal("delta sent to a client that never opted in")
+	}
+	s.Ack("u", stateAckMessage{})
+
+	first := s.Delta("u", 1, snap("a", "1", "b", "1"))
+	if first == nil || !first.Full || len(first.Changed) != 2 {
+		t.Fatalf("first message %+v, want a full snapshot", first)
+	}
+	s.Ack("u", stateAckMessage{Tick: 1})
+
+	msg := s.Delta("u", 2, snap("a", "2", "c", "1"))
+	if msg == nil || msg.Full || msg.BaseTick != 1 {
+		t.Fatalf("second message %+v, want a delta on tick 1", msg)
+	}
+	if got := changedIDs(msg); !reflect.DeepEqual(got, []string{"a", "c"}) {
+		t.Errorf("changed %v, want a and c", got)
+	}
+	if !reflect.DeepEqual(msg.Removed, []string{"b"}) {
+		t.Errorf("removed %v, want b", msg.Removed)
+	}
+
+	s.Ack("u", stateAckMessage{Tick: 2})
+	if msg := s.Delta("u", 3, snap("a", "2", "c", "1")); msg != nil {
+		t.Errorf("sent %+v when nothing changed", msg)
+	}
+}
+
+func TestStateSyncAckGap(t *testing.T) {
+	s := newStateSync()
+	s.Ack("u", stateAckMessage{})
+	s.Delta("u", 1, snap("a", "1"))
+	s.Ack("u", stateAckMessage{Tick: 1})
+	s.Delta("u", 2, snap("a", "2"))
+	s.Delta("u", 3, snap("a", "3"))
+
+	// Tick 2's ack never arrived; tick 3's did and becomes the baseline.
+	s.Ack("u", stateAckMessage{Tick: 3})
+	// Late, duplicate and unknown acks leave it there.
+	s.Ack("u", stateAckMessage{Tick: 2})
+	s.Ack("u", stateAckMessage{Tick: 3})
+	s.Ack("u", stateAckMessage{Tick: 9})
+
+	msg := s.Delta("u", 4, snap("a", "4"))
+	if msg == nil || msg.Full || msg.BaseTick != 3 {
+		t.Fatalf("message %+v, want a delta on tick 3", msg)
+	}
+	if string(msg.Changed["a"]) != "4" {
+		t.Errorf("changed %v", msg.Changed)
+	}
+}
+
+func TestStateSyncFallsBackToFull(t *testing.T) {
+	s := newStateSync()
+	s.Ack("u", stateAckMessage{})
+	s.Delta("u", 1, snap("a", "1", "b", "1"))
+	s.Ack("u", stateAckMessage{Tick: 1})
+
+	// The client stops acking; once syncHistory snapshots are outstanding
+	// the baseline can no longer be trusted.
+	tick := int64(2)
+	for ; tick < 2+syncHistory; tick++ {
+		if msg := s.Delta("u", tick, snap("a", "1", "b", "1", "t", strconv.FormatInt(tick, 10))); msg == nil || msg.Full {
+			t.Fatalf("tick %d: message %+v, want a delta", tick, msg)
+		}
+	}
+	msg := s.Delta("u", tick, snap("a", "1", "b", "1"))
+	if msg == nil || !msg.Full || len(msg.Changed) != 2 {
+		t.Fatalf("message %+v, want a full snapshot", msg)
+	}
+	// An ack for a snapshot sent before the fallback is no use any more.
+	s.Ack("u", stateAckMessage{Tick: 2})
+	if msg := s.Delta("u", tick+1, snap("a", "1", "b", "1")); msg == nil || !msg.Full {
+		t.Errorf("message %+v after a stale ack, want a full snapshot", msg)
+	}
+
+	s.Ack("u", stateAckMessage{Tick: tick + 1})
+	s.Ack("u", stateAckMessage{Resync: true})
+	if msg := s.Delta("u", tick+2, snap("a", "1", "b", "1")); msg == nil || !msg.Full {
+		t.Errorf("message %+v after a resync, want a full snapshot", msg)
+	}
+	s.Forget("u")
+	if s.Enabled("u") {
+		t.Error("forgotten client still synced")
+	}
+}
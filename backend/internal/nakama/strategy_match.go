@@ -0,0 +1,922 @@
+package nakama
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+
+	"github.com/delta/terrabound/backend/internal/game"
+	"github.com/delta/terrabound/backend/internal/rating"
+)
+
+const strategyTickRate = 10
+
+// botSeatPrefix starts the ids of seats filled by bots rather than players.
+const botSeatPrefix = "bot-"
+
+type submitOrdersMessage struct {
+	Orders []game.Order `json:"orders"`
+}
+
+type orderResultMessage struct {
+	Turn     int                  `json:"turn"`
+	Accepted []game.Order         `json:"accepted"`
+	Rejected []game.RejectedOrder `json:"rejected"`
+}
+
+type phaseChangeMessage struct {
+	Phase      game.Phase `json:"phase"`
+	Turn       int        `json:"turn"`
+	EndsAtTick int64      `json:"endsAtTick"`
+}
+
+type StrategyMatchState struct {
+	MatchID string
+	World   *game.World
+	Engine  *game.Engine
+	// Seed drives every battle; together with History it replays the match.
+	Seed    uint64
+	History []game.TurnResult
+	Victory game.VictoryConditions
+	Outcome *game.Outcome
+	// EliminatedOn is the turn each player went out, however it happened.
+	EliminatedOn map[game.PlayerID]int
+
+	Phase      game.Phase
+	Turn       int
+	PhaseEnds  int64
+	Orders     map[game.PlayerID][]game.Order
+	Locked     map[game.PlayerID]bool
+	Presences  map[string]runtime.Presence
+	Spectators *spectatorFeed
+	MinPlayers int
+	Settings   matchSettings
+	Label      labelPublisher
+	// MapID names the registered map in play, or "generated".
+	MapID string
+	// Teams puts players on a side before they join, from the "teams" param.
+	Teams map[string]game.FactionID
+
+	// Bots are the seats the server plays, keyed by player. A human whose
+	// reconnect grace runs out may be added here until they come back.
+	Bots         map[game.PlayerID]game.Strategy
+	BotStrategy  game.Strategy
+	FillWithBots bool
+	Grace        *reconnectGrace
+	Reservations *seatReservations
+	Lifetime     matchLifetime
+	// Shutdown is why the match is stopping without an outcome, once it is.
+	Shutdown string
+	Admin    adminControls
+
+	// LobbyEnds is when empty seats are handed to bots; zero until someone joins.
+	LobbyEnds     int64
+	LobbyTicks    int64
+	PlanningTicks int64
+	LockInTicks   int64
+}
+
+// StrategyMatch runs simultaneous turns: everyone plans, orders lock, then the
+// game engine resolves them all at once and the outcome is broadcast.
+type StrategyMatch struct {
+	maps *game.MapRegistry
+}
+
+func (m *StrategyMatch) MatchInit(
+	ctx context.Context,
+	logger runtime.Logger,
+	db *sql.DB,
+	nk runtime.NakamaModule,
+	params map[string]interface{},
+) (interface{}, int, string) {
+
+	seed := paramSeed(params)
+	matchID, _ := ctx.Value(runtime.RUNTIME_CTX_MATCH_ID).(string)
+
+	victory := game.DefaultVictoryConditions()
+	victory.CapitalCapture = paramBool(params, "capitalCapture", victory.CapitalCapture)
+	victory.LastStanding = paramBool(params, "lastStanding", victory.LastStanding)
+	victory.TurnLimit = paramInt(params, "turnLimit", victory.TurnLimit)
+	victory.AllowConcession = paramBool(params, "allowConcession", victory.AllowConcession)
+
+	mapID := paramString(params, "mapId", "generated")
+	world, err := m.loadWorld(params, seed)
+	if err != nil {
+		logger.Warn("Map setup failed, using the sample map: %v", err)
+		world, mapID = game.SampleMap(), "sample"
+	}
+
+	difficulty := game.Difficulty(paramString(params, "botDifficulty", string(game.DifficultyNormal)))
+	bots, err := game.NewStrategy(difficulty)
+	if err != nil {
+		logger.Warn("%v, bots will play on %s", err, game.DifficultyNormal)
+		bots, _ = game.NewStrategy(game.DifficultyNormal)
+	}
+
+	// A player whose team cannot be set up plays for themselves.
+	teams := make(map[string]game.FactionID)
+	for userID, team := range paramStringMap(params, "teams") {
+		id := game.FactionID(team)
+		if team == "" {
+			logger.Warn("Ignoring empty team for %s", userID)
+			continue
+		}
+		if _, ok := world.Factions[id]; !ok {
+			if err := world.AddFaction(game.Faction{ID: id, Name: team}); err != nil {
+				logger.Warn("Ignoring team %q for %s: %v", team, userID, err)
+				continue
+			}
+		}
+		teams[userID] = id
+	}
+
+	policy := parseDisconnectPolicy(paramString(params, "disconnectPolicy", ""), policyBot)
+	grace := newReconnectGrace(paramInt(params, "reconnectGraceSeconds", 60), strategyTickRate, policy)
+
+	state := &StrategyMatchState{
+		MatchID:       matchID,
+		Settings:      newMatchSettings(ctx, params),
+		MapID:         mapID,
+		Teams:         teams,
+		Victory:       victory,
+		World:         world,
+		Engine:        game.NewEngine(seed),
+		Seed:          seed,
+		Phase:         game.PhaseLobby,
+		Orders:        make(map[game.PlayerID][]game.Order),
+		Locked:        make(map[game.PlayerID]bool),
+		EliminatedOn:  make(map[game.PlayerID]int),
+		Presences:     make(map[string]runtime.Presence),
+		Spectators:    newSpectatorFeed(params, strategyTickRate),
+		MinPlayers:    paramInt(params, "minPlayers", 2),
+		Bots:          make(map[game.PlayerID]game.Strategy),
+		BotStrategy:   bots,
+		FillWithBots:  paramBool(params, "fillWithBots", true),
+		Grace:         grace,
+		Reservations:  newSeatReservations(params, strategyTickRate),
+		Lifetime:      newMatchLifetime(params, strategyTickRate, 120, 0),
+		LobbyTicks:    int64(paramInt(params, "lobbySeconds", 30) * strategyTickRate),
+		PlanningTicks: int64(paramInt(params, "planningSeconds", 30) * strategyTickRate),
+		LockInTicks:   int64(paramInt(params, "lockInSeconds", 2) * strategyTickRate),
+	}
+
+	label := state.label().String()
+	state.Label.last = label
+
+	logger.Info("Strategy match initialized with seed %d.", seed)
+	return state, strategyTickRate, label
+}
+
+func (m *StrategyMatch) MatchJoinAttempt(
+	ctx context.Context,
+	logger runtime.Logger,
+	db *sql.DB,
+	nk runtime.NakamaModule,
+	dispatcher runtime.MatchDispatcher,
+	tick int64,
+	state interface{},
+	presence runtime.Presence,
+	metadata map[string]string,
+) (interface{}, bool, string) {
+
+	s := state.(*StrategyMatchState)
+
+	if s.Shutdown != "" {
+		return s, false, rejectClosing
+	}
+	// Strategy messages only have a JSON form so far.
+	_, reason := negotiate(metadata, encodingJSON)
+	if reason == "" && spectating(metadata) {
+		// Eliminated players may stay on to watch the rest of the game.
+		player := s.World.Player(game.PlayerID(presence.GetUserId()))
+		reason = rejectBanned
+		if !s.Settings.Banned[presence.GetUserId()] {
+			reason = s.Spectators.Admit(presence, metadata, player != nil && !player.Eliminated, func(id string) bool {
+				return s.World.Player(game.PlayerID(id)) != nil
+			})
+		}
+		if reason == "" {
+			return s, true, ""
+		}
+		logger.Info("Rejected spectator %s: %s", presence.GetUserId(), reason)
+		return s, false, reason
+	}
+	if reason == "" {
+		reason = s.admitPlayer(ctx, logger, nk, presence.GetUserId())
+	}
+	if reason != "" {
+		logger.Info("Rejected join from %s: %s", presence.GetUserId(), reason)
+		return s, false, reason
+	}
+	return s, true, ""
+}
+
+// admitPlayer checks whether userID may take a seat, counting seats reserved
+// for others as taken. Players returning to their own seat are always let
+// back in unless banned. It returns the rejection reason, or "".
+func (s *StrategyMatchState) admitPlayer(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, userID string) string {
+	if s.Shutdown != "" {
+		return rejectClosing
+	}
+	returning := s.World.Player(game.PlayerID(userID)) != nil
+	seated := len(s.World.Players) + s.Reservations.Others(userID)
+	if reason := s.Settings.admit(ctx, logger, nk, userID, seated, returning); reason != "" || returning {
+		return reason
+	}
+	if s.Phase != game.PhaseLobby {
+		return rejectStarted
+	}
+	if seated >= s.capacity() {
+		return rejectFull
+	}
+	return ""
+}
+
+func (m *StrategyMatch) MatchJoin(
+	ctx context.Context,
+	logger runtime.Logger,
+	db *sql.DB,
+	nk runtime.NakamaModule,
+	dispatcher runtime.MatchDispatcher,
+	tick int64,
+	state interface{},
+	joins []runtime.Presence,
+) interface{} {
+
+	s := state.(*StrategyMatchState)
+
+	for _, p := range joins {
+		s.sendTo(logger, dispatcher, p, opCodeWelcome, welcomeMessage{ProtocolVersion: protocolVersion, Encoding: encodingJSON, Tick: tick})
+		if sp := s.Spectators.Join(p, tick); sp != nil {
+			s.sendSpectator(logger, dispatcher, sp, opCodePhaseChange, phaseChangeMessage{
+				Phase:      s.Phase,
+				Turn:       s.Turn,
+				EndsAtTick: s.PhaseEnds,
+			})
+			logger.Info("Spectator joined: %s", p.GetUserId())
+			continue
+		}
+		s.Presences[p.GetUserId()] = p
+		id := game.PlayerID(p.GetUserId())
+		if s.World.Player(id) != nil {
+			s.Grace.Return(p.GetUserId())
+			if _, ok := s.Bots[id]; ok {
+				// The player plans this turn themselves, whatever the bot had in.
+				delete(s.Bots, id)
+				delete(s.Orders, id)
+				delete(s.Locked, id)
+				logger.Info("Player %s took their seat back from the bot", id)
+			}
+			s.resync(logger, dispatcher, p)
+			continue
+		}
+		s.Reservations.Claim(p.GetUserId())
+		spawn, err := s.World.Seat(game.Player{ID: id, Name: p.GetUsername(), Faction: s.Teams[p.GetUserId()]})
+		if err != nil {
+			logger.Warn("Could not seat %s: %v", p.GetUserId(), err)
+			continue
+		}
+		logger.Info("Player %s seated at %s", p.GetUserId(), spawn)
+	}
+
+	if s.Phase == game.PhaseLobby {
+		if s.LobbyEnds == 0 && len(s.World.Players) > 0 {
+			s.LobbyEnds = tick + s.LobbyTicks
+		}
+		if len(s.World.Players) >= s.capacity() {
+			s.start(logger, dispatcher, tick)
+		}
+	}
+	s.broadcastWorld(logger, dispatcher)
+	s.Label.publish(logger, dispatcher, s.label())
+
+	return s
+}
+
+func (m *StrategyMatch) MatchLeave(
+	ctx context.Context,
+	logger runtime.Logger,
+	db *sql.DB,
+	nk runtime.NakamaModule,
+	dispatcher runtime.MatchDispatcher,
+	tick int64,
+	state interface{},
+	leaves []runtime.Presence,
+) interface{} {
+
+	s := state.(*StrategyMatchState)
+
+	for _, p := range leaves {
+		if s.Spectators.Leave(p) {
+			logger.Info("Spectator left: %s", p.GetUserId())
+			continue
+		}
+		delete(s.Presences, p.GetUserId())
+		id := game.PlayerID(p.GetUserId())
+		// Before turn one the seat is simply given back; afterwards it is
+		// held for the grace period and then the disconnect policy applies.
+		switch {
+		case s.Phase == game.PhaseLobby:
+			s.World.Unseat(id)
+		case s.World.Player(id) == nil:
+		case s.Settings.Banned[p.GetUserId()]:
+			s.Grace.Drop(p.GetUserId(), tick)
+		default:
+			s.Grace.Hold(p.GetUserId(), tick)
+		}
+		logger.Info("Player left: %s", p.GetUserId())
+	}
+	if s.Phase == game.PhaseLobby && len(s.World.Players) == 0 {
+		s.LobbyEnds = 0
+	}
+	s.Label.publish(logger, dispatcher, s.label())
+
+	return s
+}
+
+func (m *StrategyMatch) MatchLoop(
+	ctx context.Context,
+	logger runtime.Logger,
+	db *sql.DB,
+	nk runtime.NakamaModule,
+	dispatcher runtime.MatchDispatcher,
+	tick int64,
+	state interface{},
+	messages []runtime.MatchData,
+) interface{} {
+
+	s := state.(*StrategyMatchState)
+
+	if s.Admin.End != nil {
+		winners := make([]game.PlayerID, len(s.Admin.End.Winners))
+		for i, id := range s.Admin.End.Winners {
+			winners[i] = game.PlayerID(id)
+		}
+		return s.end(ctx, logger, nk, dispatcher, game.Declare(s.World, winners, s.Turn))
+	}
+	// Bots play on for players who left, so the match only counts as empty
+	// once nobody is left to finish it.
+	if reason := s.Lifetime.Check(tick, len(s.Presences)+s.activeBots()); reason != "" {
+		s.stop(ctx, logger, nk, dispatcher, reason, 0)
+		return nil
+	}
+	s.Spectators.Flush(dispatcher, tick)
+	c := &loopContext{ctx: ctx, logger: logger, nk: nk, dispatcher: dispatcher, tick: tick}
+	messages = s.Spectators.FromPlayers(messages)
+	// A paused match refuses its inputs; its timers are pushed back on resume.
+	if s.Admin.Paused {
+		c.refuse(messages, errorPaused)
+		return s
+	}
+
+	for _, userID := range s.Grace.Expired(tick) {
+		if outcome := s.abandon(logger, dispatcher, game.PlayerID(userID)); outcome != nil {
+			return s.end(ctx, logger, nk, dispatcher, outcome)
+		}
+	}
+	if lapsed := s.Reservations.Expired(tick); len(lapsed) > 0 {
+		logger.Info("Seat reservations lapsed: %v", lapsed)
+		s.Label.publish(logger, dispatcher, s.label())
+	}
+
+	if !strategyRoutes.dispatch(s, c, messages) {
+		return nil
+	}
+	// Phase deadlines are real ticks, so a lower tick rate only checks them
+	// less often; it does not lengthen the phases.
+	if s.Admin.Slowed(tick) {
+		return s
+	}
+
+	switch s.Phase {
+	case game.PhaseLobby:
+		if tick%strategyTickRate == 0 && s.Settings.widenBand(time.Now()) {
+			s.Label.publish(logger, dispatcher, s.label())
+		}
+		if s.LobbyEnds > 0 && tick >= s.LobbyEnds && s.start(logger, dispatcher, tick) {
+			s.broadcastWorld(logger, dispatcher)
+		}
+	case game.PhasePlanning:
+		if tick >= s.PhaseEnds || s.allLocked() {
+			s.enterPhase(logger, dispatcher, tick, game.PhaseLockIn)
+		}
+	case game.PhaseLockIn:
+		if tick >= s.PhaseEnds {
+			s.enterPhase(logger, dispatcher, tick, game.PhaseResolution)
+			s.resolveTurn(logger, dispatcher)
+			if outcome := s.Victory.Check(s.World, s.Turn); outcome != nil {
+				return s.end(ctx, logger, nk, dispatcher, outcome)
+			}
+			s.Turn++
+			s.enterPhase(logger, dispatcher, tick, game.PhasePlanning)
+		}
+	}
+
+	return s
+}
+
+func (m *StrategyMatch) MatchSignal(
+	ctx context.Context,
+	logger runtime.Logger,
+	db *sql.DB,
+	nk runtime.NakamaModule,
+	dispatcher runtime.MatchDispatcher,
+	tick int64,
+	state interface{},
+	data string,
+) (interface{}, string) {
+
+	s := state.(*StrategyMatchState)
+
+	var sig matchSignal
+	if err := json.Unmarshal([]byte(data), &sig); err != nil {
+		logger.Warn("Ignoring malformed match signal: %v", err)
+		return s, ""
+	}
+
+	if sig.Op == signalOpWorld {
+		out, err := json.Marshal(game.ViewFor(s.World, game.PlayerID(sig.UserID)))
+		if err != nil {
+			logger.Error("Failed to marshal world: %v", err)
+			return s, ""
+		}
+		return s, string(out)
+	}
+	if sig.Op == signalOpReserve {
+		reason := s.Reservations.ReserveAll(sig.reservees(), tick, func(userID string) string {
+			return s.admitPlayer(ctx, logger, nk, userID)
+		})
+		if reason == "" {
+			s.Label.publish(logger, dispatcher, s.label())
+		}
+		return s, reserveReply(reason, s.label())
+	}
+	if out, ok := handleAdminSignal(logger, dispatcher, tick, s, sig); ok {
+		logger.Info("Admin signal %q applied to match %s: %s", sig.Op, s.MatchID, out)
+		return s, out
+	}
+	logger.Warn("Ignoring unknown match signal %q", sig.Op)
+	return s, ""
+}
+
+func (s *StrategyMatchState) controls() *adminControls { return &s.Admin }
+
+func (s *StrategyMatchState) audience() (map[string]runtime.Presence, map[string]clientProtocol) {
+	presences := s.Spectators.Presences()
+	for userID, p := range s.Presences {
+		presences[userID] = p
+	}
+	return presences, nil
+}
+
+func (s *StrategyMatchState) baseTickRate() int { return strategyTickRate }
+
+// kick disconnects a player. Unless they are banned they may come back to
+// their seat like after any other disconnect.
+func (s *StrategyMatchState) kick(logger runtime.Logger, dispatcher runtime.MatchDispatcher, tick int64, userID string, ban bool) error {
+	presence, connected := s.Presences[userID]
+	if !connected && s.World.Player(game.PlayerID(userID)) == nil {
+		return fmt.Errorf("%s is not in this match", userID)
+	}
+	if ban {
+		s.Settings.Banned[userID] = true
+	}
+	logger.Info("Kicking %s from match %s (ban: %v)", userID, s.MatchID, ban)
+	if !connected {
+		if ban && s.Grace.Holding(userID) {
+			s.Grace.Drop(userID, tick)
+		}
+		return nil
+	}
+	return dispatcher.MatchKick([]runtime.Presence{presence})
+}
+
+func (s *StrategyMatchState) checkWinners(winners []string) error {
+	for _, id := range winners {
+		if s.World.Player(game.PlayerID(id)) == nil {
+			return fmt.Errorf("%s is not in this match", id)
+		}
+	}
+	return nil
+}
+
+func (s *StrategyMatchState) extendDeadlines(ticks int64) {
+	if s.PhaseEnds > 0 {
+		s.PhaseEnds += ticks
+	}
+	if s.LobbyEnds > 0 {
+		s.LobbyEnds += ticks
+	}
+	s.Grace.Extend(ticks)
+}
+
+func (m *StrategyMatch) MatchTerminate(
+	ctx context.Context,
+	logger runtime.Logger,
+	db *sql.DB,
+	nk runtime.NakamaModule,
+	dispatcher runtime.MatchDispatcher,
+	tick int64,
+	state interface{},
+	graceSeconds int,
+) interface{} {
+	s := state.(*StrategyMatchState)
+	s.stop(ctx, logger, nk, dispatcher, shutdownServer, graceSeconds)
+	return s
+}
+
+// end announces the outcome and records it. It returns nil so MatchLoop can hand it straight back to
+// Nakama, which stops the match.
+func (s *StrategyMatchState) end(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, dispatcher runtime.MatchDispatcher, outcome *game.Outcome) interface{} {
+	s.Outcome = outcome
+	logger.Info("Match %s over on turn %d (%s), winners: %v", s.MatchID, outcome.Turn, outcome.Reason, outcome.Winners)
+
+	s.broadcast(logger, dispatcher, opCodeMatchResult, outcome)
+	s.Spectators.FlushAll(dispatcher)
+
+	if err := writeMatchResult(ctx, nk, &matchResult{
+		MatchId: s.MatchID,
+		Outcome: outcome,
+		Seed:    s.Seed,
+		Turns:   s.History,
+	}); err != nil {
+		logger.Error("Failed to persist result of match %s: %v", s.MatchID, err)
+	}
+	s.rate(ctx, logger, nk, outcome)
+	return nil
+}
+
+// rate updates the ratings of the people who played, placed by how they
+// finished. Bots are left out, and a match an operator ended without naming
+// winners is not rated.
+func (s *StrategyMatchState) rate(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, outcome *game.Outcome) {
+	if outcome.Reason == game.VictoryDeclared && len(outcome.Winners) == 0 {
+		return
+	}
+	var standings []rating.Standing
+	for id, place := range game.Standings(s.World, outcome, s.EliminatedOn) {
+		// Players a bot took over for are rated; they left the game.
+		if s.World.Players[id].Bot {
+			continue
+		}
+		standings = append(standings, rating.Standing{ID: string(id), Place: place, Team: string(s.World.Players[id].Faction)})
+	}
+	if err := updateRatings(ctx, logger, nk, s.MatchID, modeStrategy, standings); err != nil {
+		logger.Error("Failed to update ratings after match %s: %v", s.MatchID, err)
+	}
+}
+
+// stop ends the match without an outcome: it closes the match to new
+// players, warns everyone it is going away in graceSeconds and records the
+// board as it was left. Should the game still finish within the grace period,
+// end overwrites the record with the real result.
+func (s *StrategyMatchState) stop(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, dispatcher runtime.MatchDispatcher, reason string, graceSeconds int) {
+	s.Shutdown = reason
+	logger.Info("Match %s shutting down on turn %d: %s", s.MatchID, s.Turn, reason)
+	s.Label.publish(logger, dispatcher, s.label())
+	s.Spectators.FlushAll(dispatcher)
+	presences, _ := s.audience()
+	announceShutdown(logger, dispatcher, presences, nil, reason, graceSeconds)
+
+	if err := writeMatchResult(ctx, nk, &matchResult{
+		MatchId:  s.MatchID,
+		Shutdown: reason,
+		World:    s.World,
+		Seed:     s.Seed,
+		Turns:    s.History,
+	}); err != nil {
+		logger.Error("Failed to persist final state of match %s: %v", s.MatchID, err)
+	}
+}
+
+// loadWorld builds the board: the registered map named by "mapId" if there
+// is one, otherwise a map generated from the "map*" params and the match seed.
+func (m *StrategyMatch) loadWorld(params map[string]interface{}, seed uint64) (*game.World, error) {
+	if id := paramString(params, "mapId", ""); id != "" {
+		if m.maps == nil {
+			return nil, game.ErrUnknownMap
+		}
+		def, err := m.maps.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		return def.World(), nil
+	}
+
+	return game.GenerateMap(game.MapParams{
+		Seed:        game.DeriveSeed(seed, "map"),
+		Players:     paramInt(params, "maxPlayers", 0),
+		Regions:     paramInt(params, "mapRegions", 0),
+		Continents:  paramInt(params, "mapContinents", 0),
+		Chokepoints: paramInt(params, "mapChokepoints", 0),
+		Resources:   game.ResourceLevel(paramString(params, "mapResources", "")),
+	})
+}
+
+var strategyRoutes = newStrategyRoutes()
+
+func newStrategyRoutes() *opRouter[*StrategyMatchState] {
+	r := newOpRouter[*StrategyMatchState]()
+	handleJSON(r, opCodeSubmitOrders, (*StrategyMatchState).handleSubmitOrders)
+	r.handle(opCodeLockIn, (*StrategyMatchState).handleLockIn)
+	r.handle(opCodeConcede, (*StrategyMatchState).handleConcede)
+	return r
+}
+
+// activePlayer is the sender of msg if they are a player still in a started match.
+func (s *StrategyMatchState) activePlayer(msg runtime.MatchData) *game.Player {
+	player := s.World.Player(game.PlayerID(msg.GetUserId()))
+	if player == nil || player.Eliminated || s.Phase == game.PhaseLobby {
+		return nil
+	}
+	return player
+}
+
+func (s *StrategyMatchState) handleSubmitOrders(c *loopContext, msg runtime.MatchData, in submitOrdersMessage) bool {
+	player := s.activePlayer(msg)
+	if player == nil || s.Phase != game.PhasePlanning {
+		return true
+	}
+	for i := range in.Orders {
+		in.Orders[i].PlayerID = player.ID
+	}
+	accepted, rejected := game.ValidateOrders(s.World, in.Orders)
+	s.Orders[player.ID] = accepted
+	s.sendTo(c.logger, c.dispatcher, msg, opCodeOrderResult, orderResultMessage{
+		Turn:     s.Turn,
+		Accepted: accepted,
+		Rejected: rejected,
+	})
+	return true
+}
+
+func (s *StrategyMatchState) handleLockIn(c *loopContext, msg runtime.MatchData) bool {
+	if player := s.activePlayer(msg); player != nil && s.Phase == game.PhasePlanning {
+		s.Locked[player.ID] = true
+	}
+	return true
+}
+
+func (s *StrategyMatchState) handleConcede(c *loopContext, msg runtime.MatchData) bool {
+	player := s.activePlayer(msg)
+	if player == nil {
+		return true
+	}
+	outcome, ok := s.Victory.Concede(s.World, player.ID, s.Turn)
+	if !ok {
+		return true
+	}
+	c.logger.Info("Player %s conceded", player.ID)
+	s.EliminatedOn[player.ID] = s.Turn
+	if outcome != nil {
+		s.end(c.ctx, c.logger, c.nk, c.dispatcher, outcome)
+		return false
+	}
+	s.broadcastWorld(c.logger, c.dispatcher)
+	return true
+}
+
+// start fills empty seats with bots if allowed and begins turn one, provided
+// enough players are seated. Otherwise the lobby keeps waiting.
+func (s *StrategyMatchState) start(logger runtime.Logger, dispatcher runtime.MatchDispatcher, tick int64) bool {
+	if s.FillWithBots && s.BotStrategy != nil {
+		for n := 1; len(s.World.Players) < s.capacity(); n++ {
+			id := game.PlayerID(fmt.Sprintf("%s%d", botSeatPrefix, n))
+			if s.World.Player(id) != nil {
+				continue
+			}
+			spawn, err := s.World.Seat(game.Player{ID: id, Name: fmt.Sprintf("Bot %d", n), Bot: true})
+			if err != nil {
+				logger.Warn("Could not seat %s: %v", id, err)
+				break
+			}
+			s.Bots[id] = s.BotStrategy
+			logger.Info("Bot %s seated at %s", id, spawn)
+		}
+	}
+	if len(s.World.Players) < s.MinPlayers {
+		return false
+	}
+
+	s.World.ReleaseSpawns()
+	s.Turn = 1
+	s.enterPhase(logger, dispatcher, tick, game.PhasePlanning)
+	return true
+}
+
+// planBot asks a bot for its orders from its own fogged view, validates them
+// exactly as a human's would be and locks it in. Each bot draws from its own
+// stream of the match seed so a replay makes the same choices.
+func (s *StrategyMatchState) planBot(logger runtime.Logger, id game.PlayerID) {
+	strategy, ok := s.Bots[id]
+	player := s.World.Player(id)
+	if !ok || player == nil || player.Eliminated {
+		return
+	}
+	rng := game.NewRNG(game.DeriveSeed(s.Seed, "bot", string(id), strconv.Itoa(s.Turn)))
+	accepted, rejected := game.ValidateOrders(s.World, strategy.Plan(game.ViewFor(s.World, id), id, rng))
+	if len(rejected) > 0 {
+		logger.Debug("Bot %s had %d orders rejected on turn %d", id, len(rejected), s.Turn)
+	}
+	s.Orders[id] = accepted
+	s.Locked[id] = true
+}
+
+// abandon applies the disconnect policy to a player whose grace ran out: a
+// bot takes the seat, or the player forfeits, which may end the match.
+func (s *StrategyMatchState) abandon(logger runtime.Logger, dispatcher runtime.MatchDispatcher, id game.PlayerID) *game.Outcome {
+	if s.Grace.Policy == policyBot && s.BotStrategy != nil {
+		s.Bots[id] = s.BotStrategy
+		if s.Phase == game.PhasePlanning && len(s.Orders[id]) == 0 {
+			s.planBot(logger, id)
+		}
+		logger.Info("Bot took over for %s", id)
+		return nil
+	}
+
+	outcome, ok := s.Victory.Forfeit(s.World, id, s.Turn)
+	if ok {
+		logger.Info("Player %s forfeited after not reconnecting", id)
+		s.EliminatedOn[id] = s.Turn
+		s.broadcastWorld(logger, dispatcher)
+	}
+	return outcome
+}
+
+// capacity is how many seats the match offers: one per spawn, capped by the
+// maxPlayers param when set.
+func (s *StrategyMatchState) capacity() int {
+	if s.Settings.MaxPlayers > 0 {
+		return min(len(s.World.Spawns), s.Settings.MaxPlayers)
+	}
+	return len(s.World.Spawns)
+}
+
+// label describes the match for MatchList. Seats are only open in the lobby.
+func (s *StrategyMatchState) label() matchLabel {
+	label := s.Settings.label(modeStrategy, string(s.Phase), len(s.World.Players))
+	label.MaxPlayers = s.capacity()
+	label.Open = max(label.MaxPlayers-len(s.World.Players)-s.Reservations.Count(), 0)
+	if s.Phase != game.PhaseLobby {
+		label.Open = 0
+	}
+	if s.Shutdown != "" {
+		label.Phase = labelPhaseClosing
+		label.Open = 0
+	}
+	label.Map = s.MapID
+	label.Spectators = len(s.Spectators.Watching())
+	return label
+}
+
+// activeBots is how many bots are still in the game.
+func (s *StrategyMatchState) activeBots() int {
+	n := 0
+	for id := range s.Bots {
+		if p := s.World.Player(id); p != nil && !p.Eliminated {
+			n++
+		}
+	}
+	return n
+}
+
+// allLocked reports whether every active player has locked in early.
+func (s *StrategyMatchState) allLocked() bool {
+	active := 0
+	for _, id := range s.World.PlayerIDs() {
+		if s.World.Players[id].Eliminated {
+			continue
+		}
+		active++
+		if !s.Locked[id] {
+			return false
+		}
+	}
+	return active > 0
+}
+
+func (s *StrategyMatchState) enterPhase(logger runtime.Logger, dispatcher runtime.MatchDispatcher, tick int64, phase game.Phase) {
+	s.Phase = phase
+	switch phase {
+	case game.PhasePlanning:
+		s.PhaseEnds = tick + s.PlanningTicks
+		s.Orders = make(map[game.PlayerID][]game.Order)
+		s.Locked = make(map[game.PlayerID]bool)
+		for _, id := range s.World.PlayerIDs() {
+			s.planBot(logger, id)
+		}
+	case game.PhaseLockIn:
+		s.PhaseEnds = tick + s.LockInTicks
+	default:
+		s.PhaseEnds = tick
+	}
+	s.Label.publish(logger, dispatcher, s.label())
+	s.broadcast(logger, dispatcher, opCodePhaseChange, phaseChangeMessage{
+		Phase:      s.Phase,
+		Turn:       s.Turn,
+		EndsAtTick: s.PhaseEnds,
+	})
+}
+
+// resolveTurn feeds every locked order into the engine in player order.
+func (s *StrategyMatchState) resolveTurn(logger runtime.Logger, dispatcher runtime.MatchDispatcher) {
+	var orders []game.Order
+	for _, id := range s.World.PlayerIDs() {
+		orders = append(orders, s.Orders[id]...)
+	}
+
+	result := s.Engine.Resolve(s.World, s.Turn, orders)
+	s.History = append(s.History, result)
+	for _, id := range result.Eliminated {
+		s.EliminatedOn[id] = s.Turn
+	}
+	logger.Info("Turn %d resolved: %d orders, %d battles", s.Turn, len(result.Executed), len(result.Battles))
+
+	for userID, p := range s.Presences {
+		id := game.PlayerID(userID)
+		visible := game.VisibleRegions(s.World, id)
+		s.sendTo(logger, dispatcher, p, opCodeTurnResult, game.ViewOfTurn(s.World, result, id, visible))
+	}
+	for _, sp := range s.Spectators.Watching() {
+		view := result
+		if sp.Perspective != "" {
+			id := game.PlayerID(sp.Perspective)
+			view = game.ViewOfTurn(s.World, result, id, game.VisibleRegions(s.World, id))
+		}
+		s.sendSpectator(logger, dispatcher, sp, opCodeTurnResult, view)
+	}
+	s.broadcastWorld(logger, dispatcher)
+}
+
+// resync brings a returning player up to date: the phase they are in and the
+// orders they already have in for this turn. MatchJoin sends the world itself.
+func (s *StrategyMatchState) resync(logger runtime.Logger, dispatcher runtime.MatchDispatcher, p runtime.Presence) {
+	s.sendTo(logger, dispatcher, p, opCodePhaseChange, phaseChangeMessage{
+		Phase:      s.Phase,
+		Turn:       s.Turn,
+		EndsAtTick: s.PhaseEnds,
+	})
+	if s.Phase == game.PhasePlanning || s.Phase == game.PhaseLockIn {
+		s.sendTo(logger, dispatcher, p, opCodeOrderResult, orderResultMessage{
+			Turn:     s.Turn,
+			Accepted: s.Orders[game.PlayerID(p.GetUserId())],
+		})
+	}
+}
+
+// broadcastWorld sends each connected player the world filtered by their own
+// fog of war, and each spectator the whole world or their chosen player's view.
+func (s *StrategyMatchState) broadcastWorld(logger runtime.Logger, dispatcher runtime.MatchDispatcher) {
+	for userID, p := range s.Presences {
+		s.sendTo(logger, dispatcher, p, opCodeWorldState, game.ViewFor(s.World, game.PlayerID(userID)))
+	}
+	for _, sp := range s.Spectators.Watching() {
+		view := s.World
+		if sp.Perspective != "" {
+			view = game.ViewFor(s.World, game.PlayerID(sp.Perspective))
+		}
+		s.sendSpectator(logger, dispatcher, sp, opCodeWorldState, view)
+	}
+}
+
+// broadcast sends v to every player now and to spectators after their delay.
+func (s *StrategyMatchState) broadcast(logger runtime.Logger, dispatcher runtime.MatchDispatcher, opCode int64, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		logger.Error("Failed to marshal opcode %d: %v", opCode, err)
+		return
+	}
+	players := make([]runtime.Presence, 0, len(s.Presences))
+	for _, p := range s.Presences {
+		players = append(players, p)
+	}
+	if len(players) > 0 {
+		dispatcher.BroadcastMessage(opCode, data, players, nil, true)
+	}
+	for _, sp := range s.Spectators.Watching() {
+		s.Spectators.Send(dispatcher, sp, opCode, data)
+	}
+}
+
+func (s *StrategyMatchState) sendSpectator(logger runtime.Logger, dispatcher runtime.MatchDispatcher, sp *spectator, opCode int64, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		logger.Error("Failed to marshal opcode %d: %v", opCode, err)
+		return
+	}
+	s.Spectators.Send(dispatcher, sp, opCode, data)
+}
+
+func (s *StrategyMatchState) sendTo(logger runtime.Logger, dispatcher runtime.MatchDispatcher, to runtime.Presence, opCode int64, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		logger.Error("Failed to marshal opcode %d: %v", opCode, err)
+		return
+	}
+	dispatcher.BroadcastMessage(opCode, data, []runtime.Presence{to}, nil, true)
+}
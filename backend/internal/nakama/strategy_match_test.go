@@ -0,0 +1,69 @@
+package nakama
+
+import (
+	"context"
+	"testing"
+
+	"github.com/delta/terrabound/backend/internal/game"
+	"github.com/delta/terrabound/backend/internal/game/maps"
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+// newTestStrategyMatch starts a strategy match on the four-seat crossroads map.
+func newTestStrategyMatch(t *testing.T, params map[string]interface{}) (*StrategyMatch, *StrategyMatchState) {
+	t.Helper()
+	registry, err := maps.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	params["mapId"] = "crossroads"
+	m := &StrategyMatch{maps: registry}
+	state, _, _ := m.MatchInit(context.Background(), nopLogger{}, nil, &fakeNakama{}, params)
+	s := state.(*StrategyMatchState)
+	if len(s.World.Spawns) != 4 {
+		t.Fatalf("crossroads has %d spawns, want 4", len(s.World.Spawns))
+	}
+	return m, s
+}
+
+func TestStrategyMatchStartsAtMaxPlayers(t *testing.T) {
+	m, s := newTestStrategyMatch(t, map[string]interface{}{"maxPlayers": 2, "fillWithBots": false})
+	nk, dispatcher := &fakeNakama{}, &fakeMatch{}
+	join := func(userID string) string {
+		p := fakePresence{userID, userID + "-session"}
+		_, ok, reason := m.MatchJoinAttempt(context.Background(), nopLogger{}, nil, nk, dispatcher, 1, s, p, map[string]string{})
+		if ok {
+			m.MatchJoin(context.Background(), nopLogger{}, nil, nk, dispatcher, 1, s, []runtime.Presence{p})
+		}
+		return reason
+	}
+
+	if reason := join("p1"); reason != "" || s.Phase != game.PhaseLobby {
+		t.Fatalf("first join: rejected %q, phase %s", reason, s.Phase)
+	}
+	if got := s.label().Open; got != 1 {
+		t.Errorf("open seats with one of two taken = %d, want 1", got)
+	}
+	if reason := join("p2"); reason != "" {
+		t.Fatalf("second join rejected: %q", reason)
+	}
+	if s.Phase != game.PhasePlanning {
+		t.Fatalf("phase with every seat taken = %s, want %s", s.Phase, game.PhasePlanning)
+	}
+	if reason := join("p3"); reason == "" {
+		t.Error("a third player joined a two-seat match")
+	}
+}
+
+func TestStrategyMatchBotsFillToMaxPlayers(t *testing.T) {
+	m, s := newTestStrategyMatch(t, map[string]interface{}{"maxPlayers": 3})
+	p := fakePresence{"p1", "p1-session"}
+	m.MatchJoin(context.Background(), nopLogger{}, nil, &fakeNakama{}, &fakeMatch{}, 1, s, []runtime.Presence{p})
+
+	if !s.start(nopLogger{}, &fakeMatch{}, 2) {
+		t.Fatal("match with bots did not start")
+	}
+	if len(s.World.Players) != 3 || len(s.Bots) != 2 {
+		t.Errorf("seated %d players and %d bots, want 3 and 2", len(s.World.Players), len(s.Bots))
+	}
+}
@@ -0,0 +1,121 @@
+// Package rating implements Glicko-2 (Glickman, "Example of the Glicko-2
+// system", 2013) on the familiar Elo-like scale, with every match one rating
+// period.
+package rating
+
+import "math"
+
+// glickoScale converts between the display scale and Glicko-2's internal one.
+const glickoScale = 173.7178
+
+const (
+	// DefaultRating is where new accounts start, matching the old Elo default.
+	DefaultRating = 1000
+	// MaxDeviation is a new account's deviation: nothing is known about them.
+	MaxDeviation = 350
+	// DefaultVolatility is the expected fluctuation of a new account.
+	DefaultVolatility = 0.06
+	// ProvisionalDeviation is the deviation above which a rating is still
+	// provisional: the player has not played enough for it to be trusted.
+	ProvisionalDeviation = 110
+	// Tau constrains how fast volatility changes; 0.3 to 1.2 is sensible.
+	Tau = 0.5
+
+	convergence = 0.000001
+)
+
+type Rating struct {
+	Rating     float64 `json:"rating"`
+	Deviation  float64 `json:"deviation"`
+	Volatility float64 `json:"volatility"`
+}
+
+// New is the provisional rating every account starts with.
+func New() Rating {
+	return Rating{Rating: DefaultRating, Deviation: MaxDeviation, Volatility: DefaultVolatility}
+}
+
+func (r Rating) Provisional() bool {
+	return r.Deviation > ProvisionalDeviation
+}
+
+// Result is one game against Opponent: Score is 1 for a win, 0.5 a draw, 0 a loss.
+type Result struct {
+	Opponent Rating
+	Score    float64
+}
+
+// Update returns r after a rating period with results, computed against the
+// opponents' ratings from before the period. With no results only the
+// deviation grows.
+func Update(r Rating, results []Result) Rating {
+	mu := (r.Rating - DefaultRating) / glickoScale
+	phi := r.Deviation / glickoScale
+	sigma := r.Volatility
+
+	if len(results) == 0 {
+		return Rating{
+			Rating:     r.Rating,
+			Deviation:  math.Min(math.Sqrt(phi*phi+sigma*sigma)*glickoScale, MaxDeviation),
+			Volatility: sigma,
+		}
+	}
+
+	// Step 3 and 4: estimated variance and improvement.
+	var vInv, sum float64
+	for _, res := range results {
+		muJ := (res.Opponent.Rating - DefaultRating) / glickoScale
+		gJ := g(res.Opponent.Deviation / glickoScale)
+		e := 1 / (1 + math.Exp(-gJ*(mu-muJ)))
+		vInv += gJ * gJ * e * (1 - e)
+		sum += gJ * (res.Score - e)
+	}
+	v := 1 / vInv
+	delta := v * sum
+
+	// Step 5: new volatility, by the Illinois algorithm.
+	a := math.Log(sigma * sigma)
+	f := func(x float64) float64 {
+		ex := math.Exp(x)
+		d := phi*phi + v + ex
+		return ex*(delta*delta-phi*phi-v-ex)/(2*d*d) - (x-a)/(Tau*Tau)
+	}
+	A := a
+	var B float64
+	if delta*delta > phi*phi+v {
+		B = math.Log(delta*delta - phi*phi - v)
+	} else {
+		k := 1.0
+		for f(a-k*Tau) < 0 {
+			k++
+		}
+		B = a - k*Tau
+	}
+	fA, fB := f(A), f(B)
+	for math.Abs(B-A) > convergence {
+		C := A + (A-B)*fA/(fB-fA)
+		fC := f(C)
+		if fC*fB <= 0 {
+			A, fA = B, fB
+		} else {
+			fA /= 2
+		}
+		B, fB = C, fC
+	}
+	sigma = math.Exp(A / 2)
+
+	// Step 6 to 8: new deviation and rating.
+	phiStar := math.Sqrt(phi*phi + sigma*sigma)
+	phi = 1 / math.Sqrt(1/(phiStar*phiStar)+1/v)
+	mu += phi * phi * sum
+
+	return Rating{
+		Rating:     mu*glickoScale + DefaultRating,
+		Deviation:  math.Min(phi*glickoScale, MaxDeviation),
+		Volatility: sigma,
+	}
+}
+
+func g(phi float64) float64 {
+	return 1 / math.Sqrt(1+3*phi*phi/(math.Pi*math.Pi))
+}
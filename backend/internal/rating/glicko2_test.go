@@ -0,0 +1,38 @@
+package rating
+
+import (
+	"math"
+	"testing"
+)
+
+// TestUpdateGlickmanExample checks the worked example from Glickman's paper.
+func TestUpdateGlickmanExample(t *testing.T) {
+	got := Update(Rating{Rating: 1500, Deviation: 200, Volatility: 0.06}, []Result{
+		{Opponent: Rating{Rating: 1400, Deviation: 30}, Score: 1},
+		{Opponent: Rating{Rating: 1550, Deviation: 100}, Score: 0},
+		{Opponent: Rating{Rating: 1700, Deviation: 300}, Score: 0},
+	})
+	want := Rating{Rating: 1464.06, Deviation: 151.52, Volatility: 0.05999}
+	if math.Abs(got.Rating-want.Rating) > 0.01 || math.Abs(got.Deviation-want.Deviation) > 0.01 || math.Abs(got.Volatility-want.Volatility) > 0.00001 {
+		t.Errorf("Update = %+v, want %+v", got, want)
+	}
+}
+
+func TestPlacementTeammatesNotRatedAgainstEachOther(t *testing.T) {
+	ratings := map[string]Rating{"a": New(), "b": New(), "c": New(), "d": New()}
+	got := Placement(ratings, []Standing{
+		{ID: "a", Place: 1, Team: "red"},
+		{ID: "b", Place: 1, Team: "red"},
+		{ID: "c", Place: 2, Team: "blue"},
+		{ID: "d", Place: 2, Team: "blue"},
+	})
+	if got["a"] != got["b"] || got["c"] != got["d"] {
+		t.Errorf("teammates rated apart: %+v", got)
+	}
+	if got["a"].Rating <= DefaultRating || got["c"].Rating >= DefaultRating {
+		t.Errorf("winners %v and losers %v moved the wrong way", got["a"].Rating, got["c"].Rating)
+	}
+	if got["a"].Deviation >= MaxDeviation {
+		t.Errorf("deviation did not shrink: %v", got["a"].Deviation)
+	}
+}
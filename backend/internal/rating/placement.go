@@ -0,0 +1,35 @@
+package rating
+
+// Standing is where a player finished; 1 is first and ties share a place.
+// Players on the same Team are not rated against each other; an empty Team
+// is a side of one.
+type Standing struct {
+	ID    string
+	Place int
+	Team  string
+}
+
+// Placement rates a multiplayer match as games between every pair of
+// players on different sides: the better placed one wins, equal places draw.
+// ratings must hold everyone in standings; the result holds their new ratings.
+func Placement(ratings map[string]Rating, standings []Standing) map[string]Rating {
+	out := make(map[string]Rating, len(standings))
+	for _, s := range standings {
+		var results []Result
+		for _, o := range standings {
+			if o.ID == s.ID || s.Team != "" && o.Team == s.Team {
+				continue
+			}
+			score := 0.5
+			switch {
+			case s.Place < o.Place:
+				score = 1
+			case s.Place > o.Place:
+				score = 0
+			}
+			results = append(results, Result{Opponent: ratings[o.ID], Score: score})
+		}
+		out[s.ID] = Update(ratings[s.ID], results)
+	}
+	return out
+}
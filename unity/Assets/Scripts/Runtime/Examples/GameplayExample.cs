@@ -118,14 +118,14 @@ namespace Terrabound.Runtime.Examples
                 return;
             }
 
-            // Register PlayerMove opcode
-            _protocol.RegisterOpcodeExplicit(MatchOpcodes.PlayerMove,1, OnPlayerMove);
+            // Register PlayerMove opcode (we only send it; the server never echoes it)
+            _protocol.RegisterOpcodeExplicit(MatchOpcodes.PlayerMove, MatchOpcodeValues.PlayerMove, _ => { });
 
             // Register PlayerAction opcode
             // _protocol.RegisterOpcode(MatchOpcodes.PlayerAction, OnPlayerAction);
 
             // Register GameStateSync opcode
-            // _protocol.RegisterOpcode(MatchOpcodes.GameStateSync, OnGameStateSync);
+            _protocol.RegisterOpcodeExplicit(MatchOpcodes.GameStateSync, MatchOpcodeValues.GameStateSync, OnGameStateSync);
 
             // Register presence events
             _protocol.OnMatchPresence += OnMatchPresenceEvent;
@@ -137,10 +137,9 @@ namespace Terrabound.Runtime.Examples
 
         #region Opcode Handlers (Receiving Messages)
 
-            // Nakama backend broadcasts a full snapshot:
-            // { "<userId>": {"user_id":"<userId>","x":0,"y":0}, ... }
-            // So opcode=1 is a state-sync, not a wrapped PlayerMoveState.
-        private void OnPlayerMove(IMatchState state)
+        // Nakama backend broadcasts a full snapshot on game_state_sync:
+        // { "<userId>": {"user_id":"<userId>","x":0,"y":0}, ... }
+        private void OnGameStateSync(IMatchState state)
         {
             if (state == null) return;
 
@@ -36,6 +36,22 @@ namespace Terrabound.Runtime.Protocol
         // Add more opcodes as needed
     }
 
+    // Numeric opcodes the Nakama backend uses for the keys above.
+    // Keep in sync with backend/internal/nakama/opcodes.go.
+    public static class MatchOpcodeValues
+    {
+        public const long PlayerMove = 1;
+        public const long PlayerAction = 2;
+        public const long GameStateSync = 3;
+        public const long PlayerJoin = 4;
+        public const long PlayerLeave = 5;
+        public const long Welcome = 6;
+        public const long Shutdown = 7;
+        public const long Error = 9;
+        public const long ServerMessage = 20;
+        public const long MatchPaused = 21;
+    }
+
     // Result data returned from joining a match.
     public class MatchJoinResult
     {